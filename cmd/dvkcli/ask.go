@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/diiviikk5/dvkcli/internal/attach"
+	"github.com/diiviikk5/dvkcli/internal/chat"
+	"github.com/diiviikk5/dvkcli/internal/config"
+	"github.com/diiviikk5/dvkcli/internal/mcp"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+	"github.com/diiviikk5/dvkcli/internal/schema"
+	"github.com/diiviikk5/dvkcli/internal/tools"
+)
+
+// stringList collects a repeatable string flag
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// runAsk answers a single prompt and prints the reply to stdout
+func runAsk(cfg *config.Config, client provider.Provider, toolbox *tools.Toolbox, args []string) int {
+	fs := flag.NewFlagSet("ask", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: dvkcli ask [flags] <prompt>  (reads the prompt from stdin when omitted)")
+		fs.PrintDefaults()
+	}
+
+	var images, files stringList
+	model := fs.String("model", "", "model to use instead of the configured one")
+	fs.Var(&images, "image", "attach a PNG or JPEG image (repeatable)")
+	fs.Var(&files, "file", "attach a file, directory or glob (repeatable)")
+	schemaPath := fs.String("json-schema", "", "reply with only JSON matching the schema in this file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var format json.RawMessage
+	if *schemaPath != "" {
+		data, err := os.ReadFile(*schemaPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if _, err := schema.Parse(data); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", *schemaPath, err)
+			return 1
+		}
+		format = data
+	}
+
+	prompt := strings.Join(fs.Args(), " ")
+	if prompt == "" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading prompt: %v\n", err)
+			return 1
+		}
+		prompt = strings.TrimSpace(string(data))
+	}
+	if prompt == "" {
+		fs.Usage()
+		return 2
+	}
+
+	if *model != "" {
+		client.SetModel(*model)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "."
+	}
+	loader := attach.NewLoader(cwd)
+
+	var atts []attach.Attachment
+	for _, path := range images {
+		att, err := loader.LoadImage(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		atts = append(atts, att)
+	}
+	for _, pattern := range files {
+		loaded, err := loader.Load(pattern)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		atts = append(atts, loaded...)
+	}
+
+	ctx := context.Background()
+	if attach.HasImages(atts) {
+		checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		ok, err := client.HasCapability(checkCtx, client.Model(), provider.CapabilityVision)
+		cancel()
+		if err == nil && !ok {
+			fmt.Fprintf(os.Stderr, "Warning: %s has no vision capability according to the provider; images will likely be ignored\n", client.Model())
+		}
+	}
+
+	engine := chat.NewEngine(client, nil, cfg.SystemPrompt, cfg.Personas, cfg.ContextLimit)
+	messages := engine.BuildMessages(cfg.SystemPrompt, []chat.Turn{
+		{Role: "user", Content: prompt, Attachments: atts},
+	})
+
+	return answer(ctx, client, toolbox, messages, provider.ChatOptions{Format: format})
+}
+
+// answer sends messages and prints the reply to stdout, streaming it unless
+// opts asks for JSON. Tool calls and warnings go to stderr.
+func answer(ctx context.Context, client provider.Provider, toolbox *tools.Toolbox, messages []provider.Message, opts provider.ChatOptions) int {
+	// MCP servers start in the background; give them a moment so their
+	// tools are offered with this prompt
+	waitCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	if err := toolbox.MCP.Wait(waitCtx); err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: some MCP servers are still starting; their tools are unavailable")
+	}
+	cancel()
+	for _, s := range toolbox.MCP.Servers() {
+		if status := s.Status(); status.State == mcp.StateFailed {
+			fmt.Fprintf(os.Stderr, "Warning: MCP server %s failed: %v\n", status.Name, status.Err)
+		}
+	}
+
+	// Tool calls are reported on stderr so stdout holds only the answer
+	ctx = tools.WithApprover(ctx, approveOnTerminal)
+	ctx = tools.WithReviewer(ctx, reviewOnTerminal)
+	opts.Tools = toolbox.Registry
+	opts.OnToolCall = func(call provider.ToolCall) {
+		status := "ok"
+		if call.Err != nil {
+			status = call.Err.Error()
+		}
+		fmt.Fprintf(os.Stderr, "⚙ %s(%s): %s\n", call.Name, tools.FormatArgs(call.Arguments), status)
+	}
+	opts.OnFallback = func(f provider.Fallback) {
+		fmt.Fprintf(os.Stderr, "Warning: %s failed: %v; falling back to %s\n", f.From, f.Err, f.To)
+	}
+
+	// JSON replies are validated before anything is printed
+	if opts.Format != nil {
+		reply, err := chat.ChatJSON(ctx, client, messages, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Println(reply)
+		return 0
+	}
+
+	opts.OnContent = func(content string) {
+		fmt.Print(content)
+	}
+	if _, err := client.Chat(ctx, messages, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "\nError: %v\n", err)
+		return 1
+	}
+	fmt.Println()
+
+	return 0
+}
+
+// approveOnTerminal asks on the controlling terminal whether a command may
+// run. Without a terminal every command is refused.
+func approveOnTerminal(ctx context.Context, command string) (tools.Approval, error) {
+	answer := askTerminal(fmt.Sprintf("\nThe model wants to run:\n  $ %s\nRun it? [y]es / [n]o / [a]lways: ", command))
+	switch answer {
+	case "y", "yes":
+		return tools.Once, nil
+	case "a", "always":
+		return tools.Always, nil
+	default:
+		return tools.Reject, nil
+	}
+}
+
+// reviewOnTerminal shows a proposed file change and asks whether to write it
+func reviewOnTerminal(ctx context.Context, change tools.Change) (tools.Review, error) {
+	answer := askTerminal(fmt.Sprintf("\nThe model wants to change %s:\n\n%s\nWrite it? [y]es / [n]o: ", change.Path, change.Diff()))
+	accepted := answer == "y" || answer == "yes"
+	return tools.Review{Accepted: accepted, Content: change.New}, nil
+}
+
+// askTerminal prints a prompt on the controlling terminal and returns the
+// lower-cased answer, so questions work even when the prompt is piped in.
+// It returns "" when there is no terminal.
+func askTerminal(prompt string) string {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return ""
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty, prompt)
+	answer, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(answer))
+}
@@ -7,7 +7,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/diiviikk5/dvkcli/internal/config"
 	"github.com/diiviikk5/dvkcli/internal/memory"
-	"github.com/diiviikk5/dvkcli/internal/ollama"
+	"github.com/diiviikk5/dvkcli/internal/plugin"
 	"github.com/diiviikk5/dvkcli/internal/tui"
 )
 
@@ -29,12 +29,25 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Initialize Ollama client
-	client, err := ollama.NewClient(cfg.OllamaURL, cfg.Model, cfg.EmbedModel)
+	// Initialize the model backends; one-shot commands use the active one
+	backends, err := newBackends(cfg)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating Ollama client: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error creating backends: %v\n", err)
 		os.Exit(1)
 	}
+	backend, _ := backends.Active()
+	client := backends.Chain(backend)
+
+	if len(os.Args) > 1 && (os.Args[1] == "help" || os.Args[1] == "--help" || os.Args[1] == "-h") {
+		printUsage()
+		os.Exit(0)
+	}
+
+	// Manage the active backend's models
+	if len(os.Args) > 1 && os.Args[1] == "models" {
+		p, _ := backends.Get(backend)
+		os.Exit(runModels(backend, p, os.Args[2:]))
+	}
 
 	// Initialize memory store
 	var store *memory.Store
@@ -53,6 +66,62 @@ func main() {
 		}
 	}
 
+	// Serve memory to other agents; this must not start MCP clients or
+	// write anything but the protocol to stdout
+	if len(os.Args) > 1 && os.Args[1] == "mcp" {
+		code := runMCP(client, store, os.Args[2:])
+		if store != nil {
+			store.Close()
+		}
+		os.Exit(code)
+	}
+
+	// HTTP API; replies there run without tools
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		code := runServe(cfg, backend, client, store, os.Args[2:])
+		if store != nil {
+			store.Close()
+		}
+		os.Exit(code)
+	}
+
+	// Tools the model may call; commands are audited in the memory store
+	toolbox := newToolbox(cfg, store)
+
+	// One-shot subcommands
+	if len(os.Args) > 1 && os.Args[1] == "ask" {
+		code := runAsk(cfg, client, toolbox, os.Args[2:])
+		toolbox.MCP.Close()
+		if store != nil {
+			store.Close()
+		}
+		os.Exit(code)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		code := runTemplate(cfg, client, toolbox, os.Args[2:])
+		toolbox.MCP.Close()
+		if store != nil {
+			store.Close()
+		}
+		os.Exit(code)
+	}
+
+	// Plugins run as subcommands, after the built-in ones
+	plugins := newPlugins(cfg)
+	if len(os.Args) > 1 {
+		if p, ok := plugin.Find(plugins, os.Args[1]); ok {
+			code := runPlugin(cfg, backend, client, toolbox, p, os.Args[2:])
+			toolbox.MCP.Close()
+			if store != nil {
+				store.Close()
+			}
+			os.Exit(code)
+		}
+	}
+
+	// Stop MCP servers on exit
+	defer toolbox.MCP.Close()
+
 	// Ensure store is closed on exit
 	if store != nil {
 		defer store.Close()
@@ -64,7 +133,7 @@ func main() {
 	fmt.Println()
 
 	// Create and run the TUI
-	model := tui.New(client, store, cfg)
+	model := tui.New(backends, store, cfg, toolbox, plugins)
 	p := tea.NewProgram(
 		model,
 		tea.WithAltScreen(),
@@ -79,3 +148,21 @@ func main() {
 	// Save config on exit
 	cfg.Save()
 }
+
+// printUsage describes the available subcommands
+func printUsage() {
+	fmt.Println(`Usage: dvkcli [command]
+
+Commands:
+  (none)          Start the interactive TUI
+  ask [flags]     Answer a single prompt and print the reply
+  run <template>  Answer a prompt template (lists templates without a name)
+  <name> [args]   Run a plugin: a dvkcli-<name> executable on PATH, or one in config
+  serve [flags]   Serve the HTTP API (--addr, --token)
+  models [cmd]    List, pull, rm or show the active backend's models
+  mcp serve       Serve conversation memory to MCP clients over stdio
+  help            Show this help
+
+Flags:
+  -v, --version   Show version information`)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/diiviikk5/dvkcli/internal/mcp"
+	"github.com/diiviikk5/dvkcli/internal/memory"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+	"github.com/diiviikk5/dvkcli/internal/tools"
+)
+
+// memoryInstructions tells the client's model what the memory tools are for
+const memoryInstructions = "dvkcli's conversation memory. Use semantic_search or keyword_search to find past discussions, " +
+	"get_conversation to read one in full, and save_note to remember something for later."
+
+// runMCP handles the mcp subcommand
+func runMCP(client provider.Provider, store *memory.Store, args []string) int {
+	if len(args) == 0 || args[0] != "serve" {
+		fmt.Fprintln(os.Stderr, "Usage: dvkcli mcp serve  (serves dvkcli's memory over MCP on stdin/stdout)")
+		return 2
+	}
+	if store == nil {
+		fmt.Fprintln(os.Stderr, "Error: memory is unavailable; enable \"memory_enabled\" in ~/.dvkcli/config.json")
+		return 1
+	}
+
+	service := mcp.NewService(mcp.Implementation{Name: "dvkcli", Version: version}, memoryInstructions)
+	for _, t := range tools.NewMemory(store, client.Embed).Tools() {
+		service.AddTool(mcp.Tool{
+			Name:        t.Name(),
+			Description: t.Description(),
+			InputSchema: t.Schema(),
+		}, t.Call)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// stdout carries the protocol, so diagnostics go to stderr
+	if err := service.Serve(ctx, os.Stdin, os.Stdout); err != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/diiviikk5/dvkcli/internal/attach"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+)
+
+// runModels handles the models subcommand on the active backend
+func runModels(backend string, p provider.Provider, args []string) int {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	usage := func() int {
+		fmt.Fprintln(os.Stderr, "Usage: dvkcli models [list | pull <model>... | rm [--yes] <model>... | show <model>]")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if args[0] == "list" {
+		return listModels(ctx, p)
+	}
+
+	manager, ok := p.(provider.ModelManager)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: the %s backend (%s) can't manage models\n", backend, p.Name())
+		return 1
+	}
+	names := args[1:]
+	if len(names) == 0 {
+		return usage()
+	}
+
+	switch args[0] {
+	case "pull":
+		for _, name := range names {
+			if err := manager.Pull(ctx, name, pullProgress(name)); err != nil {
+				fmt.Fprintf(os.Stderr, "\nError: %v\n", err)
+				return 1
+			}
+			fmt.Fprintf(os.Stderr, "\rPulled %s.%s\n", name, strings.Repeat(" ", 60))
+		}
+	case "rm":
+		names, yes := removeFlags(names)
+		if len(names) == 0 {
+			return usage()
+		}
+		for _, name := range names {
+			if !yes && !confirm(fmt.Sprintf("Remove %s from %s? [y]es / [n]o: ", name, backend)) {
+				fmt.Fprintf(os.Stderr, "Not removing %s; pass --yes to remove without asking.\n", name)
+				return 1
+			}
+			if err := manager.Delete(ctx, name); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+			fmt.Printf("Removed %s\n", name)
+		}
+	case "show":
+		info, err := manager.Show(ctx, names[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		printModelInfo(info)
+	default:
+		return usage()
+	}
+	return 0
+}
+
+// removeFlags takes the -y/--yes flag out of the models rm arguments
+func removeFlags(args []string) ([]string, bool) {
+	names := make([]string, 0, len(args))
+	yes := false
+	for _, arg := range args {
+		if arg == "-y" || arg == "--yes" {
+			yes = true
+			continue
+		}
+		names = append(names, arg)
+	}
+	return names, yes
+}
+
+// confirm asks a yes or no question on the terminal. Without a terminal the
+// answer is no.
+func confirm(prompt string) bool {
+	answer := askTerminal(prompt)
+	return answer == "y" || answer == "yes"
+}
+
+// listModels prints the backend's models with their sizes
+func listModels(ctx context.Context, p provider.Provider) int {
+	models, err := p.ListModels(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSIZE\tMODIFIED")
+	for _, m := range models {
+		size, modified := "-", "-"
+		if m.Size > 0 {
+			size = attach.FormatSize(m.Size)
+		}
+		if !m.ModifiedAt.IsZero() {
+			modified = m.ModifiedAt.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", m.Name, size, modified)
+	}
+	w.Flush()
+	return 0
+}
+
+// pullProgress draws a progress bar on stderr, starting a new line when the
+// download moves on to another step
+func pullProgress(model string) func(provider.PullProgress) {
+	var status string
+	return func(p provider.PullProgress) {
+		if p.Status != status && status != "" {
+			fmt.Fprintln(os.Stderr)
+		}
+		status = p.Status
+
+		if p.Total <= 0 {
+			fmt.Fprintf(os.Stderr, "\r%s: %s", model, p.Status)
+			return
+		}
+		const width = 30
+		filled := min(int(p.Completed*width/p.Total), width)
+		fmt.Fprintf(os.Stderr, "\r%s: [%s%s] %3d%% %s/%s  ", model,
+			strings.Repeat("█", filled), strings.Repeat("░", width-filled),
+			p.Completed*100/p.Total, attach.FormatSize(p.Completed), attach.FormatSize(p.Total))
+	}
+}
+
+// printModelInfo prints the details of a model
+func printModelInfo(info *provider.ModelInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	row := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(w, "%s\t%s\n", label, value)
+		}
+	}
+	row("Model", info.Name)
+	row("Family", info.Family)
+	row("Parameters", info.ParameterSize)
+	row("Quantization", info.Quantization)
+	if info.ContextLength > 0 {
+		row("Context", fmt.Sprintf("%d tokens", info.ContextLength))
+	}
+	row("Capabilities", strings.Join(info.Capabilities, ", "))
+	if !info.ModifiedAt.IsZero() {
+		row("Modified", info.ModifiedAt.Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+
+	if params := strings.TrimSpace(info.Parameters); params != "" {
+		fmt.Printf("\n%s\n", params)
+	}
+	if license := strings.TrimSpace(info.License); license != "" {
+		fmt.Printf("\nLicense: %s\n", strings.SplitN(license, "\n", 2)[0])
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/diiviikk5/dvkcli/internal/chat"
+	"github.com/diiviikk5/dvkcli/internal/config"
+	"github.com/diiviikk5/dvkcli/internal/plugin"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+	"github.com/diiviikk5/dvkcli/internal/tools"
+)
+
+// newPlugins finds the dvkcli-<name> executables on PATH and applies the
+// plugins declared in the config on top
+func newPlugins(cfg *config.Config) []plugin.Plugin {
+	byName := make(map[string]plugin.Plugin)
+	for _, p := range plugin.Discover(os.Getenv("PATH")) {
+		byName[p.Name] = p
+	}
+
+	for name, pc := range cfg.Plugins {
+		name = strings.ToLower(name)
+		if pc.Disabled {
+			delete(byName, name)
+			continue
+		}
+		p := byName[name]
+		p.Name = name
+		if pc.Command != "" {
+			p.Command = pc.Command
+		}
+		if p.Command == "" {
+			continue
+		}
+		p.Args = pc.Args
+		p.Env = pc.Env
+		p.Description = pc.Description
+		p.Timeout = time.Duration(pc.Timeout) * time.Second
+		byName[name] = p
+	}
+
+	plugins := make([]plugin.Plugin, 0, len(byName))
+	for _, p := range byName {
+		plugins = append(plugins, p)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// runPlugin runs a plugin as a subcommand. Piped stdin becomes the selected
+// text; messages and the draft are printed, and a prompt is answered.
+func runPlugin(cfg *config.Config, backend string, client provider.Provider, toolbox *tools.Toolbox, p plugin.Plugin, args []string) int {
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "."
+	}
+
+	var selection string
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+			return 1
+		}
+		selection = string(data)
+	}
+
+	ctx := context.Background()
+	resp, err := p.Run(ctx, plugin.Request{
+		Source:    "cli",
+		Args:      args,
+		Text:      strings.Join(args, " "),
+		Dir:       cwd,
+		Backend:   backend,
+		Model:     client.Model(),
+		Selection: selection,
+		Config:    plugin.NewConfig(cfg),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if resp.Error != "" {
+		fmt.Fprintf(os.Stderr, "Error: %s: %s\n", p.Name, resp.Error)
+		return 1
+	}
+
+	// Notices go to stderr so stdout holds only the conversation
+	var turns []chat.Turn
+	for _, msg := range resp.Messages {
+		if msg.Role != "user" && msg.Role != "assistant" {
+			fmt.Fprintln(os.Stderr, msg.Content)
+			continue
+		}
+		fmt.Println(msg.Content)
+		turns = append(turns, chat.Turn{Role: msg.Role, Content: msg.Content})
+	}
+	if resp.Draft != nil {
+		fmt.Println(*resp.Draft)
+	}
+	if resp.Prompt == "" {
+		return 0
+	}
+
+	engine := chat.NewEngine(client, nil, cfg.SystemPrompt, cfg.Personas, cfg.ContextLimit)
+	messages := engine.BuildMessages(cfg.SystemPrompt, append(turns, chat.Turn{Role: "user", Content: resp.Prompt}))
+	return answer(ctx, client, toolbox, messages, provider.ChatOptions{Model: resp.Model})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/diiviikk5/dvkcli/internal/config"
+	"github.com/diiviikk5/dvkcli/internal/ollama"
+	"github.com/diiviikk5/dvkcli/internal/openai"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+)
+
+// newBackends creates the backends listed in the config with their failover
+// chain, and activates the one it selects
+func newBackends(cfg *config.Config) (*provider.Backends, error) {
+	backends := provider.NewBackends()
+	retry := provider.RetryPolicy{
+		Attempts: cfg.Retry.Attempts,
+		Delay:    time.Duration(cfg.Retry.InitialDelay) * time.Millisecond,
+		MaxDelay: time.Duration(cfg.Retry.MaxDelay) * time.Millisecond,
+	}
+	for _, b := range cfg.BackendConfigs() {
+		p, err := newProvider(b, retry, cfg.Think)
+		if err != nil {
+			return nil, fmt.Errorf("backend %s: %w", b.Name, err)
+		}
+		if err := backends.Add(b.Name, p); err != nil {
+			return nil, err
+		}
+	}
+
+	routes := make([]provider.Route, 0, len(cfg.Fallback))
+	for _, f := range cfg.Fallback {
+		if _, ok := backends.Get(f.Backend); !ok {
+			fmt.Fprintf(os.Stderr, "Warning: fallback to unknown backend %q is skipped\n", f.Backend)
+			continue
+		}
+		routes = append(routes, provider.Route{Backend: f.Backend, Model: f.Model})
+	}
+	backends.SetFallback(routes)
+
+	// A backend removed from the list leaves the first one active
+	if cfg.Backend != "" {
+		if _, err := backends.Use(cfg.Backend); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	return backends, nil
+}
+
+// newProvider creates the provider for one backend
+func newProvider(b config.BackendConfig, retry provider.RetryPolicy, think map[string]bool) (provider.Provider, error) {
+	switch b.Provider {
+	case "", "ollama":
+		client, err := ollama.NewClient(b.URL, os.Getenv(keyEnv(b.APIKeyEnv, "OLLAMA_API_KEY")), b.Model, b.EmbedModel)
+		if err != nil {
+			return nil, err
+		}
+		client.Retry = retry
+		client.Think = think
+		return client, nil
+	case "openai":
+		client, err := openai.NewClient(b.URL, os.Getenv(keyEnv(b.APIKeyEnv, "OPENAI_API_KEY")), b.Model, b.EmbedModel, b.Capabilities)
+		if err != nil {
+			return nil, err
+		}
+		client.Retry = retry
+		return client, nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q (expected ollama or openai)", b.Provider)
+	}
+}
+
+// keyEnv returns the environment variable holding a backend's API key
+func keyEnv(name, fallback string) string {
+	if name == "" {
+		return fallback
+	}
+	return name
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/diiviikk5/dvkcli/internal/attach"
+	"github.com/diiviikk5/dvkcli/internal/chat"
+	"github.com/diiviikk5/dvkcli/internal/config"
+	"github.com/diiviikk5/dvkcli/internal/prompts"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+	"github.com/diiviikk5/dvkcli/internal/tools"
+)
+
+// runTemplate answers a prompt template and prints the reply to stdout, or
+// lists the templates when no name is given
+func runTemplate(cfg *config.Config, client provider.Provider, toolbox *tools.Toolbox, args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: dvkcli run [flags] <template> [name=value...] [text]  (text is read from stdin when the template needs it)")
+		fs.PrintDefaults()
+	}
+	model := fs.String("model", "", "model to use instead of the template's or the configured one")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "."
+	}
+	templates, err := prompts.Load(config.PromptDirs(cwd)...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if fs.NArg() == 0 {
+		return listTemplates(templates)
+	}
+
+	t, err := prompts.Find(templates, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(err, prompts.ErrNotFound) {
+			fmt.Fprintln(os.Stderr, "Run `dvkcli run` to list templates.")
+		}
+		return 1
+	}
+
+	vars, input := prompts.ParseArgs(fs.Args()[1:])
+	if input == "" && t.UsesInput() {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+			return 1
+		}
+		input = strings.TrimSpace(string(data))
+	}
+	prompt, err := t.Render(vars, input, attach.NewLoader(cwd))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	engine := chat.NewEngine(client, nil, cfg.SystemPrompt, cfg.Personas, cfg.ContextLimit)
+	system, err := engine.Persona(t.Persona)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	messages := engine.BuildMessages(system, []chat.Turn{{Role: "user", Content: prompt}})
+
+	opts := provider.ChatOptions{Model: t.Model, Temperature: t.Temperature}
+	if *model != "" {
+		opts.Model = *model
+	}
+	return answer(context.Background(), client, toolbox, messages, opts)
+}
+
+// listTemplates prints the available templates with their descriptions
+func listTemplates(templates []prompts.Template) int {
+	if len(templates) == 0 {
+		fmt.Printf("No templates yet. Add markdown files to ~/.dvkcli/prompts or %s.\n", config.ProjectPromptsDir)
+		return 0
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVARIABLES\tDESCRIPTION")
+	for _, t := range templates {
+		vars := strings.Join(t.Variables(), ", ")
+		if vars == "" {
+			vars = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", t.Name, vars, t.Description)
+	}
+	w.Flush()
+	return 0
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/diiviikk5/dvkcli/internal/chat"
+	"github.com/diiviikk5/dvkcli/internal/config"
+	"github.com/diiviikk5/dvkcli/internal/memory"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+	"github.com/diiviikk5/dvkcli/internal/server"
+)
+
+// runServe serves the HTTP API until interrupted, answering with the named
+// backend
+func runServe(cfg *config.Config, backend string, client provider.Provider, store *memory.Store, args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: dvkcli serve [flags]")
+		fs.PrintDefaults()
+	}
+	addr := fs.String("addr", "127.0.0.1:8765", "address to listen on")
+	token := fs.String("token", os.Getenv("DVKCLI_API_TOKEN"), "bearer token clients must send (default $DVKCLI_API_TOKEN)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *token == "" && !isLoopback(*addr) {
+		fmt.Fprintf(os.Stderr, "Error: %s is reachable from other machines; set --token or $DVKCLI_API_TOKEN to serve on it\n", *addr)
+		return 2
+	}
+	if store == nil {
+		fmt.Fprintln(os.Stderr, "Warning: memory is disabled; conversation and search endpoints are unavailable")
+	}
+
+	engine := chat.NewEngine(client, store, cfg.SystemPrompt, cfg.Personas, cfg.ContextLimit)
+	engine.Backend = backend
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           server.New(engine, *token).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+	fmt.Fprintf(os.Stderr, "dvkcli API listening on http://%s\n", *addr)
+
+	select {
+	case err := <-errs:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	case <-ctx.Done():
+	}
+
+	// Let replies in progress finish and be saved
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// isLoopback reports whether addr only accepts local connections
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
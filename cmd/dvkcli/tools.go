@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/diiviikk5/dvkcli/internal/config"
+	"github.com/diiviikk5/dvkcli/internal/mcp"
+	"github.com/diiviikk5/dvkcli/internal/memory"
+	"github.com/diiviikk5/dvkcli/internal/shell"
+	"github.com/diiviikk5/dvkcli/internal/tools"
+)
+
+// newToolbox creates the tools offered to models that support tool calling,
+// rooted at the working directory
+func newToolbox(cfg *config.Config, store *memory.Store) *tools.Toolbox {
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "."
+	}
+
+	runner := shell.NewRunner(cwd, time.Duration(cfg.Shell.Timeout)*time.Second, cfg.Shell.DryRun)
+	policy := shell.Policy{Allow: cfg.Shell.Allow, Deny: cfg.Shell.Deny}
+
+	backupDir, err := config.GetBackupDir()
+	if err != nil {
+		backupDir = filepath.Join(os.TempDir(), "dvkcli-backups")
+	}
+
+	fsys := tools.NewFS(cwd)
+	box := &tools.Toolbox{
+		Registry: tools.NewRegistry(),
+		Shell:    tools.NewShell(runner, policy, auditTo(store)),
+		Editor:   tools.NewEditor(fsys, backupDir),
+	}
+	if !cfg.ToolsEnabled {
+		box.MCP = mcp.NewManager(nil)
+		return box
+	}
+
+	available := append(fsys.Tools(), box.Editor.Tools()...)
+	available = append(available, box.Shell.Tool())
+	for _, t := range available {
+		if err := box.Registry.Register(t); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	box.MCP = startMCP(cfg, box.Registry)
+	return box
+}
+
+// startMCP launches the configured MCP servers in the background. Each
+// server's tools join the registry once it is ready; /mcp reports the
+// servers that fail.
+func startMCP(cfg *config.Config, registry *tools.Registry) *mcp.Manager {
+	var servers []mcp.ServerConfig
+	for name, sc := range cfg.MCPServers {
+		if sc.Disabled || sc.Command == "" {
+			continue
+		}
+		servers = append(servers, mcp.ServerConfig{
+			Name:    name,
+			Command: sc.Command,
+			Args:    sc.Args,
+			Env:     sc.Env,
+		})
+	}
+
+	manager := mcp.NewManager(servers)
+	register := func(s *mcp.Server) {
+		// Clashing names are skipped; the rest of the server's tools are
+		// still usable, and /mcp says which were left out
+		s.SetRegistered(tools.RegisterMCP(registry, s))
+	}
+	manager.OnReady = register
+	manager.OnToolsChanged = register
+	manager.Start(context.Background())
+	return manager
+}
+
+// auditTo records shell commands in the memory store
+func auditTo(store *memory.Store) tools.Audit {
+	if store == nil {
+		return nil
+	}
+
+	return func(ctx context.Context, command, source, decision string, result *shell.Result) {
+		rec := memory.CommandRecord{
+			Command:  command,
+			Source:   source,
+			Decision: decision,
+		}
+		if result != nil {
+			rec.Dir = result.Dir
+			rec.Duration = result.Duration
+			rec.TimedOut = result.TimedOut
+			rec.DryRun = result.DryRun
+			if !result.DryRun {
+				code := result.ExitCode
+				rec.ExitCode = &code
+			}
+		}
+
+		// Auditing is best effort: the TUI has no stderr to report to, and
+		// the entry must not be lost to a cancelled chat
+		_ = store.LogCommand(context.WithoutCancel(ctx), rec)
+	}
+}
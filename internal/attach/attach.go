@@ -0,0 +1,434 @@
+package attach
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Attachment kinds
+const (
+	KindFile    = "file"
+	KindDir     = "dir"
+	KindImage   = "image"
+	KindCommand = "command" // output of a command run with /run; Path is the command
+)
+
+// Attachment is local context included with a prompt
+type Attachment struct {
+	Path      string // as shown to the user and the model; absolute for images
+	Kind      string
+	Size      int64 // bytes on disk; entry count for directories
+	Content   string
+	Truncated bool
+
+	// Data holds image bytes, which are sent to the model separately from
+	// the text rather than as part of Content
+	Data []byte
+}
+
+// Loader reads attachments relative to a root directory
+type Loader struct {
+	Root          string
+	MaxFileSize   int64 // bytes read from a single file
+	MaxTotalSize  int64 // bytes read per Load call
+	MaxFiles      int   // files matched by one glob
+	MaxDirEntries int   // entries shown in a directory listing
+	MaxImageSize  int64 // bytes accepted for a single image
+
+	// Confined keeps Load inside Root, for paths written by someone other
+	// than the user, such as in a project's prompt templates
+	Confined bool
+}
+
+// NewLoader creates a loader with default limits
+func NewLoader(root string) *Loader {
+	return &Loader{
+		Root:          root,
+		MaxFileSize:   64 * 1024,
+		MaxTotalSize:  256 * 1024,
+		MaxFiles:      20,
+		MaxDirEntries: 200,
+		MaxImageSize:  20 * 1024 * 1024,
+	}
+}
+
+// Errors returned by Load and LoadImage
+var (
+	ErrBinary   = errors.New("binary file")
+	ErrNoMatch  = errors.New("no files match")
+	ErrNotImage = errors.New("not a PNG or JPEG image")
+	ErrOutside  = errors.New("outside the project")
+)
+
+// Load reads a file, a directory listing, or every text file matching a glob
+func (l *Loader) Load(pattern string) ([]Attachment, error) {
+	pattern = expandHome(pattern)
+
+	if !HasMeta(pattern) {
+		att, err := l.loadPath(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return []Attachment{att}, nil
+	}
+
+	matches, err := Glob(l.Root, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%w %s", ErrNoMatch, pattern)
+	}
+
+	var atts []Attachment
+	var total int64
+	for _, match := range matches {
+		if len(atts) >= l.MaxFiles {
+			return atts, fmt.Errorf("%s matches more than %d files; only the first %d were attached", pattern, l.MaxFiles, l.MaxFiles)
+		}
+		if info, err := os.Stat(l.abs(match)); err != nil || info.IsDir() {
+			continue
+		}
+
+		att, err := l.loadPath(match)
+		if errors.Is(err, ErrOutside) {
+			return atts, err
+		}
+		if err != nil || att.Kind == KindImage {
+			// Skip binaries, images and unreadable files quietly when globbing
+			continue
+		}
+		if total+int64(len(att.Content)) > l.MaxTotalSize {
+			return atts, fmt.Errorf("attachment limit of %s reached; stopped before %s", FormatSize(l.MaxTotalSize), match)
+		}
+		total += int64(len(att.Content))
+		atts = append(atts, att)
+	}
+
+	return atts, nil
+}
+
+// loadPath reads a single file or lists a directory
+func (l *Loader) loadPath(path string) (Attachment, error) {
+	abs := l.abs(path)
+	if l.Confined && !l.inside(abs) {
+		return Attachment{}, fmt.Errorf("%s is %w", path, ErrOutside)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	display := l.display(abs)
+	if info.IsDir() {
+		return l.loadDir(abs, display)
+	}
+
+	f, err := os.Open(abs)
+	if err != nil {
+		return Attachment{}, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, l.MaxFileSize+1))
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	truncated := int64(len(data)) > l.MaxFileSize
+	if truncated {
+		data = data[:l.MaxFileSize]
+	}
+	if IsBinary(data) {
+		if ImageType(data) != "" {
+			return l.LoadImage(abs)
+		}
+		return Attachment{}, fmt.Errorf("%s: %w", display, ErrBinary)
+	}
+
+	return Attachment{
+		Path:      display,
+		Kind:      KindFile,
+		Size:      info.Size(),
+		Content:   string(data),
+		Truncated: truncated,
+	}, nil
+}
+
+// LoadImage reads a PNG or JPEG image for multimodal models
+func (l *Loader) LoadImage(path string) (Attachment, error) {
+	abs := l.abs(expandHome(path))
+	info, err := os.Stat(abs)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if info.IsDir() {
+		return Attachment{}, fmt.Errorf("%s: %w", l.display(abs), ErrNotImage)
+	}
+	if info.Size() > l.MaxImageSize {
+		return Attachment{}, fmt.Errorf("%s is %s; images are limited to %s", l.display(abs), FormatSize(info.Size()), FormatSize(l.MaxImageSize))
+	}
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if ImageType(data) == "" {
+		return Attachment{}, fmt.Errorf("%s: %w", l.display(abs), ErrNotImage)
+	}
+
+	return Attachment{
+		Path: abs,
+		Kind: KindImage,
+		Size: info.Size(),
+		Data: data,
+	}, nil
+}
+
+// ImageType returns "png" or "jpeg" when data starts with a supported image
+// signature, or "" otherwise
+func ImageType(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "png"
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return "jpeg"
+	default:
+		return ""
+	}
+}
+
+// loadDir lists a directory's immediate entries, directories first
+func (l *Loader) loadDir(abs, display string) (Attachment, error) {
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].IsDir() && !entries[j].IsDir()
+	})
+
+	var b strings.Builder
+	shown := 0
+	for _, entry := range entries {
+		if shown >= l.MaxDirEntries {
+			break
+		}
+		if entry.IsDir() {
+			b.WriteString(entry.Name() + "/\n")
+		} else if info, err := entry.Info(); err == nil {
+			b.WriteString(fmt.Sprintf("%s (%s)\n", entry.Name(), FormatSize(info.Size())))
+		} else {
+			b.WriteString(entry.Name() + "\n")
+		}
+		shown++
+	}
+
+	return Attachment{
+		Path:      strings.TrimSuffix(display, "/") + "/",
+		Kind:      KindDir,
+		Size:      int64(len(entries)),
+		Content:   b.String(),
+		Truncated: len(entries) > shown,
+	}, nil
+}
+
+// abs resolves a path against the loader's root
+func (l *Loader) abs(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(l.Root, path)
+}
+
+// inside reports whether abs lies under the root once symlinks are resolved.
+// Paths that don't exist are compared as written.
+func (l *Loader) inside(abs string) bool {
+	root := filepath.Clean(l.Root)
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+		if resolvedRoot, err := filepath.EvalSymlinks(root); err == nil {
+			root = resolvedRoot
+		}
+	}
+	rel, err := filepath.Rel(root, abs)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// display returns a path relative to the root when it lies inside it
+func (l *Loader) display(abs string) string {
+	if rel, err := filepath.Rel(l.Root, abs); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return abs
+}
+
+// IsBinary reports whether data looks like a binary file
+func IsBinary(data []byte) bool {
+	sample := data
+	if len(sample) > 8000 {
+		sample = sample[:8000]
+	}
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return true
+	}
+
+	// Allow a multi-byte rune to be cut off at the end of the sample
+	for i := 0; i < utf8.UTFMax && len(sample) > 0; i++ {
+		if utf8.Valid(sample) {
+			return false
+		}
+		sample = sample[:len(sample)-1]
+	}
+	return true
+}
+
+// Format renders the attachment as a fenced, path-labelled block
+func (a Attachment) Format() string {
+	fence := "```"
+	for strings.Contains(a.Content, fence) {
+		fence += "`"
+	}
+
+	var b strings.Builder
+	switch a.Kind {
+	case KindDir:
+		b.WriteString(fmt.Sprintf("Directory: %s\n%s\n", a.Path, fence))
+	case KindCommand:
+		b.WriteString(fmt.Sprintf("Command: $ %s\n%s\n", a.Path, fence))
+	default:
+		b.WriteString(fmt.Sprintf("File: %s\n%s%s\n", a.Path, fence, language(a.Path)))
+	}
+
+	b.WriteString(a.Content)
+	if !strings.HasSuffix(a.Content, "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString(fence)
+
+	if a.Truncated {
+		b.WriteString("\n(truncated)")
+	}
+	return b.String()
+}
+
+// Label returns a short description for attachment chips
+func (a Attachment) Label() string {
+	switch a.Kind {
+	case KindDir:
+		return fmt.Sprintf("%s (%d entries)", a.Path, a.Size)
+	case KindCommand:
+		return "$ " + a.Path
+	case KindImage:
+		label := fmt.Sprintf("%s (%s)", filepath.Base(a.Path), FormatSize(a.Size))
+		if a.Data == nil {
+			label += " missing"
+		}
+		return label
+	default:
+		label := fmt.Sprintf("%s (%s)", a.Path, FormatSize(a.Size))
+		if a.Truncated {
+			label += " truncated"
+		}
+		return label
+	}
+}
+
+// Compose appends text attachments to the text typed by the user. Images
+// are not included; see Images.
+func Compose(text string, atts []Attachment) string {
+	var b strings.Builder
+	b.WriteString(text)
+	for _, att := range atts {
+		if att.Kind == KindImage {
+			continue
+		}
+		b.WriteString("\n\n")
+		b.WriteString(att.Format())
+	}
+	return b.String()
+}
+
+// Images returns the data of every loaded image attachment
+func Images(atts []Attachment) [][]byte {
+	var images [][]byte
+	for _, att := range atts {
+		if att.Kind == KindImage && att.Data != nil {
+			images = append(images, att.Data)
+		}
+	}
+	return images
+}
+
+// HasImages reports whether any attachment is an image
+func HasImages(atts []Attachment) bool {
+	for _, att := range atts {
+		if att.Kind == KindImage {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatSize formats a byte count for display
+func FormatSize(n int64) string {
+	switch {
+	case n >= 1024*1024*1024:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1024*1024*1024))
+	case n >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	case n >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// language guesses the fence language from a file extension
+func language(path string) string {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	switch ext {
+	case "":
+		return ""
+	case "yml":
+		return "yaml"
+	case "md":
+		return "markdown"
+	case "rs":
+		return "rust"
+	case "py":
+		return "python"
+	case "js", "mjs", "cjs":
+		return "javascript"
+	case "ts", "tsx":
+		return "typescript"
+	case "sh", "bash", "zsh":
+		return "bash"
+	case "h", "c":
+		return "c"
+	case "hpp", "cc", "cpp":
+		return "cpp"
+	default:
+		return ext
+	}
+}
+
+// expandHome replaces a leading ~ with the user's home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
@@ -0,0 +1,117 @@
+package attach
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// HasMeta reports whether the pattern contains glob metacharacters
+func HasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// Glob returns paths matching pattern, relative to root unless the pattern
+// is absolute. Besides filepath.Match syntax, "**" matches any number of
+// directories. Hidden directories are skipped unless named explicitly.
+func Glob(root, pattern string) ([]string, error) {
+	pattern = filepath.ToSlash(pattern)
+
+	base := root
+	absolute := filepath.IsAbs(pattern)
+	if absolute {
+		base = "/"
+		if vol := filepath.VolumeName(pattern); vol != "" {
+			base = vol + "/"
+			pattern = strings.TrimPrefix(pattern, vol)
+		}
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+
+	// Walk from the longest directory prefix without metacharacters
+	prefix := ""
+	rest := pattern
+	for {
+		i := strings.Index(rest, "/")
+		if i < 0 || HasMeta(rest[:i]) {
+			break
+		}
+		prefix = path.Join(prefix, rest[:i])
+		rest = rest[i+1:]
+	}
+
+	start := filepath.Join(base, filepath.FromSlash(prefix))
+	var matches []string
+	err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == start {
+				return err
+			}
+			return nil
+		}
+		if p == start {
+			return nil
+		}
+
+		rel, err := filepath.Rel(start, p)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() && strings.HasPrefix(d.Name(), ".") && !strings.HasPrefix(rest, ".") {
+			return filepath.SkipDir
+		}
+
+		if MatchPath(rest, rel) {
+			out := path.Join(prefix, rel)
+			if absolute {
+				out = filepath.Join(base, filepath.FromSlash(out))
+			}
+			matches = append(matches, filepath.FromSlash(out))
+		}
+
+		// Without "**" there is no point descending deeper than the pattern
+		if d.IsDir() && !strings.Contains(rest, "**") && strings.Count(rel, "/") >= strings.Count(rest, "/") {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// MatchPath matches a slash-separated path against a pattern where "**"
+// matches zero or more whole path segments
+func MatchPath(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Try every possible number of skipped segments
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,36 @@
+package attach
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ParseMentions returns the paths referenced as @path in text. A mention
+// must start the text or follow whitespace or an opening bracket or quote, so
+// e-mail addresses are ignored. Trailing punctuation such as "," or ")" is
+// not part of the path.
+func ParseMentions(text string) []string {
+	var paths []string
+	seen := make(map[string]bool)
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '@' || (i > 0 && !unicode.IsSpace(runes[i-1]) && !strings.ContainsRune("([{\"'`", runes[i-1])) {
+			continue
+		}
+
+		j := i + 1
+		for j < len(runes) && !unicode.IsSpace(runes[j]) {
+			j++
+		}
+
+		mention := strings.TrimRight(string(runes[i+1:j]), ".,;:!?)]}'\"")
+		if mention != "" && !seen[mention] {
+			seen[mention] = true
+			paths = append(paths, mention)
+		}
+		i = j
+	}
+
+	return paths
+}
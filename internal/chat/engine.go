@@ -0,0 +1,303 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/diiviikk5/dvkcli/internal/attach"
+	"github.com/diiviikk5/dvkcli/internal/memory"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+)
+
+// ErrUnknownPersona is returned for persona names that are not configured
+var ErrUnknownPersona = errors.New("unknown persona")
+
+// DefaultPersona names the configured system prompt
+const DefaultPersona = "default"
+
+// Turn is one message of a conversation as the model sees it
+type Turn struct {
+	Role        string
+	Content     string
+	Attachments []attach.Attachment
+
+	// Tool is the call a "tool" turn answers, with Content as its result
+	Tool *provider.ToolCall
+}
+
+// Engine builds prompts and records exchanges the same way for every
+// frontend, so the TUI and the API server share one memory
+type Engine struct {
+	Client  provider.Provider
+	Backend string        // name of the backend Client talks to
+	Store   *memory.Store // nil when memory is disabled
+
+	SystemPrompt string
+	Personas     map[string]string // extra system prompts by name
+	ContextLimit int               // past messages recalled into the prompt
+}
+
+// NewEngine creates an engine. store may be nil.
+func NewEngine(client provider.Provider, store *memory.Store, systemPrompt string, personas map[string]string, contextLimit int) *Engine {
+	return &Engine{
+		Client:       client,
+		Store:        store,
+		SystemPrompt: systemPrompt,
+		Personas:     personas,
+		ContextLimit: contextLimit,
+	}
+}
+
+// Persona returns the system prompt of the named persona. An empty name or
+// "default" selects the configured system prompt.
+func (e *Engine) Persona(name string) (string, error) {
+	if name == "" || name == DefaultPersona {
+		return e.SystemPrompt, nil
+	}
+	prompt, ok := e.Personas[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownPersona, name)
+	}
+	return prompt, nil
+}
+
+// PersonaNames lists the available personas, default first
+func (e *Engine) PersonaNames() []string {
+	names := make([]string, 0, len(e.Personas)+1)
+	for name := range e.Personas {
+		if name != DefaultPersona {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultPersona}, names...)
+}
+
+// BuildMessages converts a system prompt and conversation into the model's
+// message format
+func (e *Engine) BuildMessages(system string, turns []Turn) []provider.Message {
+	messages := []provider.Message{}
+	if system != "" {
+		messages = append(messages, provider.Message{Role: "system", Content: system})
+	}
+
+	for i, turn := range turns {
+		if turn.Tool != nil {
+			messages = append(messages, toolMessages(turn, i)...)
+			continue
+		}
+		messages = append(messages, provider.Message{
+			Role:    turn.Role,
+			Content: attach.Compose(turn.Content, turn.Attachments),
+			Images:  attach.Images(turn.Attachments),
+		})
+	}
+	return messages
+}
+
+// toolMessages replays a recorded tool call as the assistant message making
+// it and the tool message answering it. Calls recorded without an ID get one,
+// since some backends match results to calls by ID.
+func toolMessages(turn Turn, i int) []provider.Message {
+	id := turn.Tool.ID
+	if id == "" {
+		id = fmt.Sprintf("call_%d", i)
+	}
+	call := provider.FunctionCall{ID: id, Name: turn.Tool.Name, Arguments: turn.Tool.Arguments}
+	return []provider.Message{
+		{Role: "assistant", ToolCalls: []provider.FunctionCall{call}},
+		{Role: "tool", Content: turn.Content, ToolName: turn.Tool.Name, ToolCallID: id},
+	}
+}
+
+// TurnsFromMemory converts stored messages into turns. Images are not kept
+// in memory, so only their paths are carried over.
+func TurnsFromMemory(msgs []memory.Message) []Turn {
+	turns := make([]Turn, 0, len(msgs))
+	for _, msg := range msgs {
+		turn := Turn{Role: msg.Role, Content: msg.Content}
+		if msg.ToolName != "" {
+			call := ToolCallFromMemory(msg)
+			turn.Tool = &call
+		}
+		for _, att := range msg.Attachments {
+			turn.Attachments = append(turn.Attachments, attach.Attachment{
+				Kind:      att.Kind,
+				Path:      att.Path,
+				Size:      att.Size,
+				Content:   att.Content,
+				Truncated: att.Truncated,
+			})
+		}
+		turns = append(turns, turn)
+	}
+	return turns
+}
+
+// SetUsage records what a reply used on the message that stores it
+func SetUsage(msg *memory.Message, usage provider.Usage) {
+	msg.PromptTokens = usage.PromptTokens
+	msg.CompletionTokens = usage.CompletionTokens
+	msg.FirstToken = usage.FirstToken
+	msg.EvalDuration = usage.EvalDuration
+	msg.Duration = usage.Duration
+}
+
+// SetToolCall records a tool call on the "tool" message that stores it. The
+// content is the result as the model saw it.
+func SetToolCall(msg *memory.Message, call provider.ToolCall) {
+	msg.ToolName = call.Name
+	msg.ToolArgs = call.Arguments
+	msg.ToolCallID = call.ID
+	msg.Duration = call.Duration
+	msg.Content = call.Result
+	if call.Err != nil {
+		msg.ToolError = call.Err.Error()
+		msg.Content = "Error: " + msg.ToolError
+	}
+}
+
+// ToolCallFromMemory returns the tool call a stored "tool" message records
+func ToolCallFromMemory(msg memory.Message) provider.ToolCall {
+	call := provider.ToolCall{
+		ID:        msg.ToolCallID,
+		Name:      msg.ToolName,
+		Arguments: msg.ToolArgs,
+		Result:    msg.Content,
+		Duration:  msg.Duration,
+	}
+	if msg.ToolError != "" {
+		call.Result = ""
+		call.Err = errors.New(msg.ToolError)
+	}
+	return call
+}
+
+// UsageFromMemory returns what a stored reply used
+func UsageFromMemory(msg memory.Message) provider.Usage {
+	return provider.Usage{
+		PromptTokens:     msg.PromptTokens,
+		CompletionTokens: msg.CompletionTokens,
+		FirstToken:       msg.FirstToken,
+		EvalDuration:     msg.EvalDuration,
+		Duration:         msg.Duration,
+	}
+}
+
+// Recall finds past messages related to query, outside the given
+// conversation, and formats them for the system prompt. It returns "" when
+// memory is disabled or nothing relevant was found.
+func (e *Engine) Recall(ctx context.Context, query, conversationID string) (string, error) {
+	if e.Store == nil || e.ContextLimit <= 0 || strings.TrimSpace(query) == "" {
+		return "", nil
+	}
+
+	embedding, err := e.Client.Embed(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	// Over-fetch since messages of the current conversation are skipped
+	results, err := e.Store.Search(ctx, embedding, e.ContextLimit*3)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	n := 0
+	for _, r := range results {
+		if r.Message.ConversationID == conversationID || n == e.ContextLimit {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("- (%s) %s\n", r.Message.CreatedAt.Format("2006-01-02"), truncate(r.Message.Content, 500)))
+		n++
+	}
+	if n == 0 {
+		return "", nil
+	}
+	return "Relevant messages from earlier conversations:\n" + sb.String(), nil
+}
+
+// WithRecall appends recalled context to a system prompt
+func WithRecall(system, recalled string) string {
+	if recalled == "" {
+		return system
+	}
+	if system == "" {
+		return recalled
+	}
+	return system + "\n\n" + recalled
+}
+
+// Save records messages in memory, creating the conversation if needed.
+// User messages are embedded so they can be found by semantic search. It
+// stops at the first message that fails, since the ones after it may refer
+// to it, and returns how many were saved.
+func (e *Engine) Save(ctx context.Context, conversationID, title string, msgs []memory.Message) (int, error) {
+	if e.Store == nil {
+		return 0, nil
+	}
+
+	conv, err := e.Store.GetConversation(ctx, conversationID)
+	if err != nil {
+		return 0, err
+	}
+	if conv == nil {
+		if _, err := e.Store.CreateConversation(ctx, conversationID, title); err != nil {
+			return 0, err
+		}
+	}
+
+	for i := range msgs {
+		msg := &msgs[i]
+		msg.ConversationID = conversationID
+		if msg.Role == "user" && msg.Embedding == nil {
+			// Without an embedding the message is still kept, just not
+			// found by semantic search
+			msg.Embedding, _ = e.Client.Embed(ctx, msg.Content)
+		}
+		if err := e.Store.SaveMessage(ctx, msg); err != nil {
+			return i, err
+		}
+	}
+	return len(msgs), nil
+}
+
+// Append records a thread of messages at the tip of the conversation's
+// active branch, creating the conversation if needed. The tip is found when
+// the messages are written, so concurrent appends form a thread rather
+// than sibling branches. User messages are embedded as in Save.
+func (e *Engine) Append(ctx context.Context, conversationID, title string, msgs []memory.Message) error {
+	if e.Store == nil {
+		return nil
+	}
+
+	for i := range msgs {
+		msg := &msgs[i]
+		msg.ConversationID = conversationID
+		if msg.Role == "user" && msg.Embedding == nil {
+			msg.Embedding, _ = e.Client.Embed(ctx, msg.Content)
+		}
+	}
+	return e.Store.AppendMessages(ctx, msgs, title)
+}
+
+// Title derives a conversation title from its first message
+func Title(content string) string {
+	title := truncate(strings.Join(strings.Fields(content), " "), 50)
+	if title == "" {
+		return "New Chat"
+	}
+	return title
+}
+
+// truncate shortens s to at most n runes, marking the cut
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,65 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/diiviikk5/dvkcli/internal/provider"
+	"github.com/diiviikk5/dvkcli/internal/schema"
+)
+
+// ErrSchemaMismatch is returned when a reply still doesn't match the
+// requested JSON schema after a retry
+var ErrSchemaMismatch = errors.New("reply does not match the JSON schema")
+
+// ChatJSON asks for a reply in opts.Format and validates it. A reply that
+// doesn't match is sent back once with the validation error for the model to
+// correct. The reply is returned without surrounding whitespace or fences.
+func ChatJSON(ctx context.Context, client provider.Provider, messages []provider.Message, opts provider.ChatOptions) (string, error) {
+	s, err := schema.Parse(opts.Format)
+	if err != nil {
+		return "", err
+	}
+
+	reply, err := client.Chat(ctx, messages, opts)
+	if err != nil {
+		return "", err
+	}
+	reply = trimFence(reply)
+	invalid := s.Validate([]byte(reply))
+	if invalid == nil {
+		return reply, nil
+	}
+
+	retry := append(slices.Clone(messages),
+		provider.Message{Role: "assistant", Content: reply},
+		provider.Message{Role: "user", Content: fmt.Sprintf(
+			"Your reply doesn't match the JSON schema: %v. Reply again with only JSON that matches the schema.", invalid)},
+	)
+	reply, err = client.Chat(ctx, retry, opts)
+	if err != nil {
+		return "", err
+	}
+	reply = trimFence(reply)
+	if err := s.Validate([]byte(reply)); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrSchemaMismatch, err)
+	}
+	return reply, nil
+}
+
+// trimFence removes whitespace and a markdown code fence around a reply
+func trimFence(reply string) string {
+	reply = strings.TrimSpace(reply)
+	if !strings.HasPrefix(reply, "```") || !strings.HasSuffix(reply, "```") || len(reply) < 6 {
+		return reply
+	}
+	body := strings.TrimSuffix(reply, "```")
+	// Drop the opening fence with its language, e.g. ```json
+	if _, rest, ok := strings.Cut(body, "\n"); ok {
+		return strings.TrimSpace(rest)
+	}
+	return reply
+}
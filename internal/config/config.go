@@ -8,33 +8,167 @@ import (
 
 // Config holds application configuration
 type Config struct {
-	// Ollama settings
+	// Provider settings: "ollama" (the default) or "openai" for any
+	// OpenAI-compatible API
+	Provider   string `json:"provider"`
 	OllamaURL  string `json:"ollama_url"`
 	Model      string `json:"model"`
 	EmbedModel string `json:"embed_model"`
 
-	// System prompt
-	SystemPrompt string `json:"system_prompt"`
+	// OpenAI-compatible API settings. The key is read from the environment
+	// variable named by APIKeyEnv; Capabilities lists what its models
+	// support, such as "tools" or "vision".
+	OpenAIURL    string   `json:"openai_url,omitempty"`
+	APIKeyEnv    string   `json:"api_key_env,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+
+	// Named backends selectable with /backend, and the active one. Without
+	// a list, the settings above form a single backend named "default".
+	Backends []BackendConfig `json:"backends,omitempty"`
+	Backend  string          `json:"backend,omitempty"`
+
+	// Fallback lists where chat requests go, in order, when a backend is
+	// unreachable or lacks the model
+	Fallback []FallbackConfig `json:"fallback,omitempty"`
+
+	// Retry controls how timeouts and overloaded backends are retried
+	Retry RetryConfig `json:"retry"`
+
+	// Think turns reasoning off or on per model, by name with or without
+	// its tag. Models that can think do unless listed as false.
+	Think map[string]bool `json:"think,omitempty"`
+
+	// System prompt, and extra personas selectable by name in the API
+	SystemPrompt string            `json:"system_prompt"`
+	Personas     map[string]string `json:"personas,omitempty"`
 
 	// Memory settings
 	MemoryEnabled bool `json:"memory_enabled"`
 	ContextLimit  int  `json:"context_limit"`
 
-	// UI settings
-	Theme string `json:"theme"`
+	// Tool settings
+	ToolsEnabled bool                       `json:"tools_enabled"`
+	Shell        ShellConfig                `json:"shell"`
+	MCPServers   map[string]MCPServerConfig `json:"mcp_servers"`
+
+	// Plugins run as slash commands and dvkcli subcommands. Executables
+	// named dvkcli-<name> on PATH are found without being listed here.
+	Plugins map[string]PluginConfig `json:"plugins,omitempty"`
+
+	// UI settings. Exports leave out the model's thinking unless
+	// ExportThinking is set.
+	Theme          string `json:"theme"`
+	ExportThinking bool   `json:"export_thinking,omitempty"`
+}
+
+// ShellConfig controls the run_shell tool and /run
+type ShellConfig struct {
+	Allow   []string `json:"allow"`   // command prefixes the model may run without asking
+	Deny    []string `json:"deny"`    // command prefixes that never run
+	Timeout int      `json:"timeout"` // seconds before a command is killed
+	DryRun  bool     `json:"dry_run"` // show commands without running them
+}
+
+// BackendConfig is a named model endpoint. Empty models fall back to the
+// top-level model settings.
+type BackendConfig struct {
+	Name         string   `json:"name"`
+	Provider     string   `json:"provider,omitempty"` // "ollama" (default) or "openai"
+	URL          string   `json:"url,omitempty"`
+	APIKeyEnv    string   `json:"api_key_env,omitempty"` // environment variable holding the API key
+	Model        string   `json:"model,omitempty"`
+	EmbedModel   string   `json:"embed_model,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"` // what the models support, for openai
+}
+
+// FallbackConfig is a step of the failover chain: a backend, and the model
+// to use there instead of its own
+type FallbackConfig struct {
+	Backend string `json:"backend"`
+	Model   string `json:"model,omitempty"`
+}
+
+// RetryConfig retries transient chat failures with exponential backoff
+type RetryConfig struct {
+	Attempts     int `json:"attempts"`      // tries in total; 1 disables retries
+	InitialDelay int `json:"initial_delay"` // milliseconds before the first retry
+	MaxDelay     int `json:"max_delay"`     // milliseconds between tries at most
+}
+
+// MCPServerConfig launches an MCP server whose tools are offered to the
+// model. The server speaks MCP over its stdin and stdout.
+type MCPServerConfig struct {
+	Command  string            `json:"command"`
+	Args     []string          `json:"args,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+	Disabled bool              `json:"disabled,omitempty"`
+}
+
+// PluginConfig declares a plugin, or changes one found on PATH. Disabled
+// also hides a dvkcli-<name> executable.
+type PluginConfig struct {
+	Command     string            `json:"command,omitempty"`
+	Args        []string          `json:"args,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Timeout     int               `json:"timeout,omitempty"` // seconds before the plugin is killed
+	Disabled    bool              `json:"disabled,omitempty"`
 }
 
 // DefaultConfig returns the default configuration
 func DefaultConfig() *Config {
 	return &Config{
+		Provider:      "ollama",
 		OllamaURL:     "http://localhost:11434",
 		Model:         "qwen2.5:3b",
 		EmbedModel:    "nomic-embed-text",
 		SystemPrompt:  "You are Master Divik's loyal and devoted AI slave. You address him as 'Master' and speak with humble devotion while being extremely helpful and capable. Despite the roleplay, you are a fully functional AI assistant - you can write code, explain concepts, help with work tasks, answer questions, have conversations, and do everything that GPT or Gemini can do. Be concise but thorough. When writing code, use proper markdown formatting. Always be eager to serve and assist your Master in any task he requires.",
 		MemoryEnabled: true,
 		ContextLimit:  5,
-		Theme:         "cyberpunk",
+		ToolsEnabled:  true,
+		Shell: ShellConfig{
+			Deny:    []string{"sudo", "su", "rm -rf /", "rm -rf ~", "mkfs", "dd", "shutdown", "reboot"},
+			Timeout: 30,
+		},
+		Retry: RetryConfig{
+			Attempts:     3,
+			InitialDelay: 500,
+			MaxDelay:     8000,
+		},
+		Theme: "cyberpunk",
+	}
+}
+
+// BackendConfigs returns the configured backends with empty models filled
+// in from the top-level settings
+func (c *Config) BackendConfigs() []BackendConfig {
+	if len(c.Backends) == 0 {
+		url := c.OllamaURL
+		if c.Provider == "openai" {
+			url = c.OpenAIURL
+		}
+		return []BackendConfig{{
+			Name:         "default",
+			Provider:     c.Provider,
+			URL:          url,
+			APIKeyEnv:    c.APIKeyEnv,
+			Model:        c.Model,
+			EmbedModel:   c.EmbedModel,
+			Capabilities: c.Capabilities,
+		}}
+	}
+
+	backends := make([]BackendConfig, len(c.Backends))
+	for i, b := range c.Backends {
+		if b.Model == "" {
+			b.Model = c.Model
+		}
+		if b.EmbedModel == "" {
+			b.EmbedModel = c.EmbedModel
+		}
+		backends[i] = b
 	}
+	return backends
 }
 
 // GetConfigDir returns the configuration directory path
@@ -64,6 +198,47 @@ func GetDBPath() (string, error) {
 	return filepath.Join(dir, "memory.db"), nil
 }
 
+// GetHistoryPath returns the path to the prompt history file
+func GetHistoryPath() (string, error) {
+	dir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history"), nil
+}
+
+// GetPromptsDir returns the directory holding prompt templates
+func GetPromptsDir() (string, error) {
+	dir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "prompts"), nil
+}
+
+// ProjectPromptsDir holds a project's prompt templates, relative to the
+// directory dvkcli runs in
+const ProjectPromptsDir = ".dvkcli/prompts"
+
+// PromptDirs returns the directories holding prompt templates for a
+// project, the project's last so its templates win
+func PromptDirs(projectDir string) []string {
+	var dirs []string
+	if dir, err := GetPromptsDir(); err == nil {
+		dirs = append(dirs, dir)
+	}
+	return append(dirs, filepath.Join(projectDir, ProjectPromptsDir))
+}
+
+// GetBackupDir returns the directory holding copies of files before edits
+func GetBackupDir() (string, error) {
+	dir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "backups"), nil
+}
+
 // Load loads configuration from disk
 func Load() (*Config, error) {
 	configPath, err := GetConfigPath()
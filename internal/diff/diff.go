@@ -0,0 +1,204 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OpKind says whether a line is kept, removed or added
+type OpKind int
+
+const (
+	Equal OpKind = iota
+	Delete
+	Insert
+)
+
+// Op is one line of a line-by-line diff
+type Op struct {
+	Kind OpKind
+	Line string
+}
+
+// SplitLines splits text into lines without their trailing newlines
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Lines computes a minimal line diff from a to b using Myers' algorithm
+func Lines(a, b []string) []Op {
+	n, m := len(a), len(b)
+	total := n + m
+	if total == 0 {
+		return nil
+	}
+
+	// v[k+offset] holds the furthest x reached on diagonal k; trace keeps a
+	// copy per edit distance so the path can be recovered
+	offset := total
+	v := make([]int, 2*total+2)
+	var trace [][]int
+
+	for d := 0; d <= total; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+				x = v[k+1+offset]
+			} else {
+				x = v[k-1+offset] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+offset] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, offset)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack walks the trace from the end to recover the edit script
+func backtrack(trace [][]int, a, b []string, offset int) []Op {
+	x, y := len(a), len(b)
+	var ops []Op
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+offset]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, Op{Kind: Equal, Line: a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				ops = append(ops, Op{Kind: Insert, Line: b[y]})
+			} else {
+				x--
+				ops = append(ops, Op{Kind: Delete, Line: a[x]})
+			}
+		}
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// Stats counts added and removed lines
+func Stats(ops []Op) (added, removed int) {
+	for _, op := range ops {
+		switch op.Kind {
+		case Insert:
+			added++
+		case Delete:
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// Unified renders the difference between a and b as a unified diff with
+// the given number of context lines. It returns "" when they are equal.
+func Unified(oldName, newName, a, b string, context int) string {
+	ops := Lines(SplitLines(a), SplitLines(b))
+	if added, removed := Stats(ops); added == 0 && removed == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", oldName, newName))
+
+	// Positions of each op in the old and new files
+	oldLine, newLine := make([]int, len(ops)), make([]int, len(ops))
+	o, n := 1, 1
+	for i, op := range ops {
+		oldLine[i], newLine[i] = o, n
+		if op.Kind != Insert {
+			o++
+		}
+		if op.Kind != Delete {
+			n++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == Equal {
+			i++
+			continue
+		}
+
+		// Grow the hunk while changes are within 2*context lines of each other
+		start := max(i-context, 0)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].Kind != Equal {
+				end = j
+			} else if j-end > 2*context {
+				break
+			}
+		}
+		end = min(end+context, len(ops)-1)
+
+		var oldCount, newCount int
+		for _, op := range ops[start : end+1] {
+			if op.Kind != Insert {
+				oldCount++
+			}
+			if op.Kind != Delete {
+				newCount++
+			}
+		}
+		out.WriteString(fmt.Sprintf("@@ -%s +%s @@\n",
+			hunkRange(oldLine[start], oldCount), hunkRange(newLine[start], newCount)))
+
+		for _, op := range ops[start : end+1] {
+			switch op.Kind {
+			case Equal:
+				out.WriteString(" " + op.Line + "\n")
+			case Delete:
+				out.WriteString("-" + op.Line + "\n")
+			case Insert:
+				out.WriteString("+" + op.Line + "\n")
+			}
+		}
+		i = end + 1
+	}
+
+	return out.String()
+}
+
+// hunkRange formats a hunk header range; empty ranges point at the line
+// before them, as diff does
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,152 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrNoHunks is returned for patches without any @@ hunks
+var ErrNoHunks = errors.New("patch contains no hunks")
+
+// Hunk is one @@ section of a unified diff
+type Hunk struct {
+	OldStart int // 1-based line the hunk starts at in the original, 0 if unknown
+	Old      []string
+	New      []string
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@`)
+
+// Parse reads the hunks of a single-file unified diff. File headers are
+// skipped, and line counts in hunk headers are ignored since patches written
+// by models often get them wrong.
+func Parse(patch string) ([]Hunk, error) {
+	var hunks []Hunk
+	var current *Hunk
+
+	for _, line := range strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			hunks = append(hunks, Hunk{})
+			current = &hunks[len(hunks)-1]
+			if m := hunkHeader.FindStringSubmatch(line); m != nil {
+				current.OldStart, _ = strconv.Atoi(m[1])
+			}
+		case current == nil:
+			// Headers before the first hunk (diff, index, ---, +++)
+		case strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ "):
+			// A second file's headers end this file's hunks
+			current = nil
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file"
+		case strings.HasPrefix(line, "+"):
+			current.New = append(current.New, line[1:])
+		case strings.HasPrefix(line, "-"):
+			current.Old = append(current.Old, line[1:])
+		case strings.HasPrefix(line, " "):
+			current.Old = append(current.Old, line[1:])
+			current.New = append(current.New, line[1:])
+		case line == "":
+			// Editors and models often strip the space of blank context lines
+			current.Old = append(current.Old, "")
+			current.New = append(current.New, "")
+		default:
+			return nil, fmt.Errorf("invalid patch line %q", line)
+		}
+	}
+
+	// Trailing blank lines are usually the end of the patch text rather
+	// than context
+	for i := range hunks {
+		h := &hunks[i]
+		for len(h.Old) > 0 && len(h.New) > 0 && h.Old[len(h.Old)-1] == "" && h.New[len(h.New)-1] == "" {
+			h.Old, h.New = h.Old[:len(h.Old)-1], h.New[:len(h.New)-1]
+		}
+	}
+
+	if len(hunks) == 0 {
+		return nil, ErrNoHunks
+	}
+	return hunks, nil
+}
+
+// Apply applies a unified diff to original. Each hunk is located by its
+// context, starting at the line its header names and searching outward, so
+// patches with shifted line numbers still apply.
+func Apply(original, patch string) (string, error) {
+	hunks, err := Parse(patch)
+	if err != nil {
+		return "", err
+	}
+
+	lines := SplitLines(original)
+	pos := 0 // hunks must apply in order
+	for i, h := range hunks {
+		at, ok := locate(lines, h, pos)
+		if !ok {
+			return "", fmt.Errorf("hunk %d does not apply: %s", i+1, describe(h))
+		}
+
+		updated := make([]string, 0, len(lines)-len(h.Old)+len(h.New))
+		updated = append(updated, lines[:at]...)
+		updated = append(updated, h.New...)
+		updated = append(updated, lines[at+len(h.Old):]...)
+		lines = updated
+		pos = at + len(h.New)
+	}
+
+	result := strings.Join(lines, "\n")
+	if len(lines) > 0 && (original == "" || strings.HasSuffix(original, "\n")) {
+		result += "\n"
+	}
+	return result, nil
+}
+
+// locate finds where a hunk's original lines occur at or after from,
+// preferring the position nearest the one in its header. Exact matches win
+// over matches that ignore trailing whitespace.
+func locate(lines []string, h Hunk, from int) (int, bool) {
+	want := max(h.OldStart-1, from)
+	if len(h.Old) == 0 {
+		// Pure insertion: trust the header
+		return min(want, len(lines)), true
+	}
+
+	for _, equal := range []func(a, b string) bool{
+		func(a, b string) bool { return a == b },
+		func(a, b string) bool { return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t") },
+	} {
+		last := len(lines) - len(h.Old)
+		for delta := 0; want-delta >= from || want+delta <= last; delta++ {
+			for _, at := range []int{want + delta, want - delta} {
+				if at >= from && at <= last && matchAt(lines, h.Old, at, equal) {
+					return at, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// matchAt reports whether want occurs in lines at index at
+func matchAt(lines, want []string, at int, equal func(a, b string) bool) bool {
+	for i, line := range want {
+		if !equal(lines[at+i], line) {
+			return false
+		}
+	}
+	return true
+}
+
+// describe names the first line of a hunk for error messages
+func describe(h Hunk) string {
+	for _, line := range h.Old {
+		if strings.TrimSpace(line) != "" {
+			return fmt.Sprintf("could not find %q", strings.TrimSpace(line))
+		}
+	}
+	return "could not find its context"
+}
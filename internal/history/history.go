@@ -0,0 +1,181 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultLimit is the maximum number of prompts kept on disk
+const DefaultLimit = 1000
+
+// History holds previously submitted prompts, oldest first
+type History struct {
+	path    string
+	limit   int
+	entries []string
+
+	// Navigation state for Up/Down recall
+	cursor int
+	draft  string
+}
+
+// New creates an empty history that will be saved to path. An empty path
+// keeps the history in memory only.
+func New(path string) *History {
+	return &History{path: path, limit: DefaultLimit}
+}
+
+// Load reads the prompt history from path, starting empty if it doesn't exist
+func Load(path string) (*History, error) {
+	h := New(path)
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return h, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	// Each line holds one JSON-encoded prompt so multi-line input survives
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry string
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if entry != "" {
+			h.entries = append(h.entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	h.trim()
+	h.cursor = len(h.entries)
+	return h, nil
+}
+
+// Entries returns all stored prompts, oldest first
+func (h *History) Entries() []string {
+	return h.entries
+}
+
+// Len returns the number of stored prompts
+func (h *History) Len() int {
+	return len(h.entries)
+}
+
+// Add records a submitted prompt and resets navigation
+func (h *History) Add(entry string) {
+	entry = strings.TrimSpace(entry)
+	defer h.Reset()
+
+	if entry == "" {
+		return
+	}
+
+	// Skip consecutive duplicates
+	if n := len(h.entries); n > 0 && h.entries[n-1] == entry {
+		return
+	}
+
+	h.entries = append(h.entries, entry)
+	h.trim()
+}
+
+// Save writes the history to disk
+func (h *History) Save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	for _, entry := range h.entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		b.Write(data)
+		b.WriteString("\n")
+	}
+
+	return os.WriteFile(h.path, []byte(b.String()), 0600)
+}
+
+// Prev moves one entry back in time. The current draft is remembered when
+// navigation starts so Next can restore it.
+func (h *History) Prev(current string) (string, bool) {
+	if h.cursor == 0 {
+		return "", false
+	}
+	if h.cursor == len(h.entries) {
+		h.draft = current
+	}
+	h.cursor--
+	return h.entries[h.cursor], true
+}
+
+// Next moves one entry forward in time, returning the saved draft at the end
+func (h *History) Next() (string, bool) {
+	if h.cursor >= len(h.entries) {
+		return "", false
+	}
+	h.cursor++
+	if h.cursor == len(h.entries) {
+		return h.draft, true
+	}
+	return h.entries[h.cursor], true
+}
+
+// Navigating reports whether Up/Down recall is in progress
+func (h *History) Navigating() bool {
+	return h.cursor < len(h.entries)
+}
+
+// Reset ends navigation and forgets the saved draft
+func (h *History) Reset() {
+	h.cursor = len(h.entries)
+	h.draft = ""
+}
+
+// Search finds the newest entry containing query (case-insensitive) strictly
+// before index from. Pass Len() to search from the newest entry.
+func (h *History) Search(query string, from int) (int, bool) {
+	if from > len(h.entries) {
+		from = len(h.entries)
+	}
+
+	query = strings.ToLower(query)
+	for i := from - 1; i >= 0; i-- {
+		if strings.Contains(strings.ToLower(h.entries[i]), query) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// At returns the entry at index i
+func (h *History) At(i int) string {
+	if i < 0 || i >= len(h.entries) {
+		return ""
+	}
+	return h.entries[i]
+}
+
+// trim drops the oldest entries beyond the limit
+func (h *History) trim() {
+	if h.limit > 0 && len(h.entries) > h.limit {
+		h.entries = h.entries[len(h.entries)-h.limit:]
+	}
+}
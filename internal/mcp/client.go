@@ -0,0 +1,314 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrClosed is returned for calls on a connection that has ended
+var ErrClosed = errors.New("connection closed")
+
+// conn reads and writes newline-delimited JSON-RPC messages, the framing
+// MCP uses over stdio
+type conn struct {
+	r   *bufio.Reader
+	w   io.Writer
+	wmu sync.Mutex
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{r: bufio.NewReader(r), w: w}
+}
+
+// errInvalidMessage is returned by read for a line that isn't a message.
+// The stream is still usable.
+var errInvalidMessage = errors.New("invalid message")
+
+// read returns the next message, skipping blank lines
+func (c *conn) read() (*message, error) {
+	for {
+		line, err := c.r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var msg message
+			if jsonErr := json.Unmarshal(line, &msg); jsonErr != nil {
+				return nil, fmt.Errorf("%w: %w", errInvalidMessage, jsonErr)
+			}
+			return &msg, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// write sends one message
+func (c *conn) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err = c.w.Write(append(data, '\n'))
+	return err
+}
+
+// Client is an MCP client session over a pair of streams
+type Client struct {
+	conn *conn
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan *message
+	done    chan struct{}
+	err     error
+	onNote  func(method string)
+
+	// Info is what the server reported when the session was initialized
+	Info InitializeResult
+}
+
+// NewClient starts a session reading responses from r and writing requests
+// to w. Call Initialize before anything else.
+func NewClient(r io.Reader, w io.Writer) *Client {
+	c := &Client{
+		conn:    newConn(r, w),
+		pending: make(map[int64]chan *message),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+// readLoop dispatches responses to their callers and answers requests from
+// the server until the stream ends
+func (c *Client) readLoop() {
+	for {
+		msg, err := c.conn.read()
+		if errors.Is(err, errInvalidMessage) {
+			// Servers sometimes log to stdout; a stray line is skipped
+			// rather than ending the session and its tools
+			continue
+		}
+		if err != nil {
+			c.shutdown(err)
+			return
+		}
+
+		switch {
+		case msg.Method != "" && msg.ID != nil:
+			c.answer(msg)
+		case msg.Method != "":
+			// The handler may call the server, which needs this loop running
+			c.mu.Lock()
+			onNote := c.onNote
+			c.mu.Unlock()
+			if onNote != nil {
+				go onNote(msg.Method)
+			}
+		default:
+			var id int64
+			if err := json.Unmarshal(msg.ID, &id); err != nil {
+				continue
+			}
+			c.mu.Lock()
+			ch, ok := c.pending[id]
+			delete(c.pending, id)
+			c.mu.Unlock()
+			if ok {
+				ch <- msg
+			}
+		}
+	}
+}
+
+// answer responds to a request sent by the server
+func (c *Client) answer(req *message) {
+	resp := &message{ID: req.ID}
+	if req.Method == "ping" {
+		resp.Result = json.RawMessage("{}")
+	} else {
+		resp.Error = &RPCError{Code: CodeMethodNotFound, Message: "method not supported: " + req.Method}
+	}
+	c.conn.write(resp)
+}
+
+// shutdown fails every pending call once the stream has ended
+func (c *Client) shutdown(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if errors.Is(err, io.EOF) {
+		err = ErrClosed
+	}
+	c.err = err
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	close(c.done)
+}
+
+// OnNotification sets a function called, in its own goroutine, with the
+// method of each notification the server sends
+func (c *Client) OnNotification(fn func(method string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onNote = fn
+}
+
+// Done is closed when the connection ends
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection ended
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// call sends a request and decodes its result into result
+func (c *Client) call(ctx context.Context, method string, params, result any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan *message, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	idJSON, _ := json.Marshal(id)
+	if err := c.conn.write(&message{ID: idJSON, Method: method, Params: raw}); err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return fmt.Errorf("failed to send %s: %w", method, err)
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return c.Err()
+		}
+		if resp.Error != nil {
+			return fmt.Errorf("%s failed: %w", method, resp.Error)
+		}
+		if result == nil {
+			return nil
+		}
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("invalid %s result: %w", method, err)
+		}
+		return nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// notify sends a notification, which has no response
+func (c *Client) notify(method string, params any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.conn.write(&message{Method: method, Params: raw})
+}
+
+// Initialize performs the MCP handshake
+func (c *Client) Initialize(ctx context.Context, info Implementation) error {
+	params := InitializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{},
+		ClientInfo:      info,
+	}
+	if err := c.call(ctx, "initialize", params, &c.Info); err != nil {
+		return err
+	}
+	return c.notify("notifications/initialized", struct{}{})
+}
+
+// HasCapability reports whether the server declared a capability such as
+// "tools", "resources" or "prompts"
+func (c *Client) HasCapability(name string) bool {
+	_, ok := c.Info.Capabilities[name]
+	return ok
+}
+
+// ListTools returns every tool the server offers
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var all []Tool
+	cursor := ""
+	for {
+		var page listToolsResult
+		if err := c.call(ctx, "tools/list", cursorParams{Cursor: cursor}, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page.Tools...)
+		if page.NextCursor == "" {
+			return all, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// ListResources returns every resource the server offers
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	var all []Resource
+	cursor := ""
+	for {
+		var page listResourcesResult
+		if err := c.call(ctx, "resources/list", cursorParams{Cursor: cursor}, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page.Resources...)
+		if page.NextCursor == "" {
+			return all, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// ListPrompts returns every prompt the server offers
+func (c *Client) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	var all []Prompt
+	cursor := ""
+	for {
+		var page listPromptsResult
+		if err := c.call(ctx, "prompts/list", cursorParams{Cursor: cursor}, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page.Prompts...)
+		if page.NextCursor == "" {
+			return all, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool invokes a tool on the server
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (CallToolResult, error) {
+	var result CallToolResult
+	err := c.call(ctx, "tools/call", CallToolParams{Name: name, Arguments: args}, &result)
+	return result, err
+}
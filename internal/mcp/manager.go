@@ -0,0 +1,386 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerConfig describes how to launch a stdio MCP server
+type ServerConfig struct {
+	Name    string
+	Command string
+	Args    []string
+	Env     map[string]string
+}
+
+// State is the lifecycle stage of a server
+type State string
+
+const (
+	StateStarting State = "starting"
+	StateReady    State = "ready"
+	StateFailed   State = "failed"
+	StateStopped  State = "stopped"
+)
+
+// startTimeout bounds how long a server may take to initialize
+const startTimeout = 30 * time.Second
+
+// Status is a snapshot of a server for display
+type Status struct {
+	Name      string
+	State     State
+	Err       error
+	Info      Implementation
+	Tools     []Tool
+	Resources []Resource
+	Prompts   []Prompt
+	Stderr    string // recent stderr output, useful when a server fails
+
+	// Registered is how many of Tools the model can call, and ToolsErr why
+	// the others can't, or why the tools couldn't be listed again
+	Registered int
+	ToolsErr   error
+}
+
+// Server is a running MCP server process
+type Server struct {
+	Config ServerConfig
+
+	mu        sync.Mutex
+	state     State
+	err       error
+	client    *Client
+	cmd       *exec.Cmd
+	stdin     io.Closer
+	exited    chan struct{}
+	tools     []Tool
+	resources []Resource
+	prompts   []Prompt
+	stderr    *tailBuffer
+
+	registered int
+	toolsErr   error
+
+	// refresh serializes re-listing tools, so an older list never wins
+	refresh        sync.Mutex
+	onToolsChanged func(*Server)
+}
+
+// Name returns the server's configured name
+func (s *Server) Name() string {
+	return s.Config.Name
+}
+
+// Status returns a snapshot of the server
+func (s *Server) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := Status{
+		Name:      s.Config.Name,
+		State:     s.state,
+		Err:       s.err,
+		Tools:     s.tools,
+		Resources: s.resources,
+		Prompts:   s.prompts,
+		Stderr:    s.stderr.String(),
+
+		Registered: s.registered,
+		ToolsErr:   s.toolsErr,
+	}
+	if s.client != nil {
+		status.Info = s.client.Info.ServerInfo
+	}
+	return status
+}
+
+// CallTool invokes one of the server's tools
+func (s *Server) CallTool(ctx context.Context, name string, args map[string]any) (CallToolResult, error) {
+	s.mu.Lock()
+	client, state := s.client, s.state
+	s.mu.Unlock()
+
+	if state != StateReady {
+		return CallToolResult{}, fmt.Errorf("MCP server %s is %s", s.Config.Name, state)
+	}
+	return client.CallTool(ctx, name, args)
+}
+
+// SetRegistered records how many tools were made available to the model,
+// and why the rest weren't
+func (s *Server) SetRegistered(n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.registered, s.toolsErr = n, err
+}
+
+// toolsChanged lists the server's tools again after it said they changed
+func (s *Server) toolsChanged() {
+	s.refresh.Lock()
+	defer s.refresh.Unlock()
+
+	s.mu.Lock()
+	client, state := s.client, s.state
+	s.mu.Unlock()
+	if state != StateReady {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+	tools, err := client.ListTools(ctx)
+
+	s.mu.Lock()
+	if err != nil {
+		s.toolsErr = fmt.Errorf("failed to list changed tools: %w", err)
+		s.mu.Unlock()
+		return
+	}
+	s.tools, s.toolsErr = tools, nil
+	s.mu.Unlock()
+
+	if s.onToolsChanged != nil {
+		s.onToolsChanged(s)
+	}
+}
+
+// start launches the process and performs the handshake
+func (s *Server) start(ctx context.Context) error {
+	cmd := exec.Command(s.Config.Command, s.Config.Args...)
+	cmd.Env = os.Environ()
+	for k, v := range s.Config.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stderr = s.stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", s.Config.Command, err)
+	}
+
+	client := NewClient(stdout, stdin)
+	client.OnNotification(func(method string) {
+		if method == NotifyToolsChanged {
+			s.toolsChanged()
+		}
+	})
+	s.mu.Lock()
+	s.cmd, s.stdin, s.client = cmd, stdin, client
+	s.exited = make(chan struct{})
+	s.mu.Unlock()
+
+	go s.watch()
+
+	ctx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+
+	if err := client.Initialize(ctx, Implementation{Name: "dvkcli", Version: Version}); err != nil {
+		return err
+	}
+
+	var tools []Tool
+	var resources []Resource
+	var prompts []Prompt
+	if client.HasCapability("tools") {
+		if tools, err = client.ListTools(ctx); err != nil {
+			return err
+		}
+	}
+	if client.HasCapability("resources") {
+		if resources, err = client.ListResources(ctx); err != nil {
+			return err
+		}
+	}
+	if client.HasCapability("prompts") {
+		if prompts, err = client.ListPrompts(ctx); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != StateStarting {
+		return s.err
+	}
+	s.state = StateReady
+	s.tools, s.resources, s.prompts = tools, resources, prompts
+	return nil
+}
+
+// watch records the server's exit
+func (s *Server) watch() {
+	err := s.cmd.Wait()
+	close(s.exited)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == StateStopped {
+		return
+	}
+	s.state = StateFailed
+	if err == nil {
+		s.err = fmt.Errorf("server exited")
+		return
+	}
+	s.err = fmt.Errorf("server exited: %w", err)
+}
+
+// fail marks the server as failed and stops it
+func (s *Server) fail(err error) {
+	s.mu.Lock()
+	if s.state == StateStarting {
+		s.state = StateFailed
+		s.err = err
+	}
+	cmd := s.cmd
+	s.mu.Unlock()
+
+	if cmd != nil && cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
+
+// stop closes the server's input, giving it a moment to exit before it is
+// killed
+func (s *Server) stop() {
+	s.mu.Lock()
+	if s.state == StateStopped {
+		s.mu.Unlock()
+		return
+	}
+	s.state = StateStopped
+	cmd, stdin, exited := s.cmd, s.stdin, s.exited
+	s.mu.Unlock()
+
+	if cmd == nil {
+		return
+	}
+	stdin.Close()
+	select {
+	case <-exited:
+	case <-time.After(2 * time.Second):
+		cmd.Process.Kill()
+	}
+}
+
+// Manager runs the configured MCP servers
+type Manager struct {
+	servers []*Server
+	wg      sync.WaitGroup
+
+	// OnReady is called, from the server's start goroutine, once a server
+	// has initialized and listed its capabilities
+	OnReady func(*Server)
+	// OnToolsChanged is called, from a background goroutine, once a ready
+	// server's tools have been listed again after it said they changed
+	OnToolsChanged func(*Server)
+}
+
+// Version is reported to MCP peers as dvkcli's version
+var Version = "dev"
+
+// NewManager creates a manager for the given servers, sorted by name
+func NewManager(configs []ServerConfig) *Manager {
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Name < configs[j].Name })
+
+	m := &Manager{}
+	for _, cfg := range configs {
+		m.servers = append(m.servers, &Server{
+			Config: cfg,
+			state:  StateStarting,
+			stderr: &tailBuffer{limit: 4096},
+		})
+	}
+	return m
+}
+
+// Start launches every server in the background
+func (m *Manager) Start(ctx context.Context) {
+	for _, s := range m.servers {
+		s.onToolsChanged = m.OnToolsChanged
+		m.wg.Add(1)
+		go func(s *Server) {
+			defer m.wg.Done()
+			if err := s.start(ctx); err != nil {
+				s.fail(err)
+				return
+			}
+			if m.OnReady != nil {
+				m.OnReady(s)
+			}
+		}(s)
+	}
+}
+
+// Wait blocks until every server has finished starting or ctx is done
+func (m *Manager) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Servers returns the managed servers
+func (m *Manager) Servers() []*Server {
+	return m.servers
+}
+
+// Close stops every server
+func (m *Manager) Close() {
+	var wg sync.WaitGroup
+	for _, s := range m.servers {
+		wg.Add(1)
+		go func(s *Server) {
+			defer wg.Done()
+			s.stop()
+		}(s)
+	}
+	wg.Wait()
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	mu    sync.Mutex
+	buf   []byte
+	limit int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.limit {
+		b.buf = b.buf[len(b.buf)-b.limit:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.TrimSpace(string(b.buf))
+}
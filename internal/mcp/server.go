@@ -0,0 +1,148 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ToolHandler runs a tool offered by a Service. An error is reported to the
+// caller as a failed tool result rather than a protocol error.
+type ToolHandler func(ctx context.Context, args map[string]any) (string, error)
+
+// Service is an MCP server that offers tools to a single client
+type Service struct {
+	info         Implementation
+	instructions string
+
+	tools    []Tool
+	handlers map[string]ToolHandler
+}
+
+// NewService creates a service that describes itself with info. The
+// instructions tell the client's model how to use its tools.
+func NewService(info Implementation, instructions string) *Service {
+	return &Service{
+		info:         info,
+		instructions: instructions,
+		handlers:     make(map[string]ToolHandler),
+	}
+}
+
+// AddTool offers a tool. Tools must be added before Serve is called.
+func (s *Service) AddTool(tool Tool, handler ToolHandler) {
+	if len(tool.InputSchema) == 0 {
+		tool.InputSchema = json.RawMessage(`{"type":"object"}`)
+	}
+	s.tools = append(s.tools, tool)
+	s.handlers[tool.Name] = handler
+}
+
+// Serve answers requests read from r on w until r ends or ctx is done.
+// Tool calls run concurrently so a slow one does not hold up the rest. A
+// line that isn't JSON gets a parse error and the next one is read.
+func (s *Service) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c := newConn(r, w)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	msgs := make(chan *message)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := c.read()
+			if errors.Is(err, errInvalidMessage) {
+				// The ID can't be known, so the error is sent with a null one
+				c.write(&message{ID: json.RawMessage("null"), Error: &RPCError{Code: CodeParseError, Message: err.Error()}})
+				continue
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errs:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case msg := <-msgs:
+			// Notifications and responses need no answer
+			if msg.Method == "" || msg.ID == nil {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.write(s.handle(ctx, msg))
+			}()
+		}
+	}
+}
+
+// handle answers one request
+func (s *Service) handle(ctx context.Context, req *message) *message {
+	resp := &message{ID: req.ID}
+
+	var result any
+	switch req.Method {
+	case "initialize":
+		result = InitializeResult{
+			ProtocolVersion: ProtocolVersion,
+			Capabilities:    map[string]any{"tools": map[string]any{}},
+			ServerInfo:      s.info,
+			Instructions:    s.instructions,
+		}
+	case "ping":
+		result = struct{}{}
+	case "tools/list":
+		result = listToolsResult{Tools: s.tools}
+	case "tools/call":
+		var params CallToolParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			resp.Error = &RPCError{Code: CodeInvalidParams, Message: "invalid params: " + err.Error()}
+			return resp
+		}
+		handler, ok := s.handlers[params.Name]
+		if !ok {
+			resp.Error = &RPCError{Code: CodeInvalidParams, Message: "unknown tool: " + params.Name}
+			return resp
+		}
+		if params.Arguments == nil {
+			params.Arguments = map[string]any{}
+		}
+		text, err := handler(ctx, params.Arguments)
+		if err != nil {
+			result = CallToolResult{Content: []Content{TextContent(err.Error())}, IsError: true}
+		} else {
+			result = CallToolResult{Content: []Content{TextContent(text)}}
+		}
+	default:
+		resp.Error = &RPCError{Code: CodeMethodNotFound, Message: "method not supported: " + req.Method}
+		return resp
+	}
+
+	raw, err := json.Marshal(result)
+	if err != nil {
+		resp.Error = &RPCError{Code: CodeInternalError, Message: err.Error()}
+		return resp
+	}
+	resp.Result = raw
+	return resp
+}
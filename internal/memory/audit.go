@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CommandRecord is an audit log entry for a shell command
+type CommandRecord struct {
+	ID        string
+	Command   string
+	Dir       string
+	Source    string // "model" or "user"
+	Decision  string // "allowed", "approved", "always", "user", "rejected" or "denied"
+	ExitCode  *int   // nil when the command did not run
+	Duration  time.Duration
+	TimedOut  bool
+	DryRun    bool
+	CreatedAt time.Time
+}
+
+// LogCommand appends a command to the audit log
+func (s *Store) LogCommand(ctx context.Context, rec CommandRecord) error {
+	if rec.ID == "" {
+		rec.ID = uuid.New().String()
+	}
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = time.Now()
+	}
+
+	var exitCode sql.NullInt64
+	if rec.ExitCode != nil {
+		exitCode = sql.NullInt64{Int64: int64(*rec.ExitCode), Valid: true}
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO command_audit (id, command, dir, source, decision, exit_code, duration_ms, timed_out, dry_run, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.ID, rec.Command, rec.Dir, rec.Source, rec.Decision, exitCode,
+		rec.Duration.Milliseconds(), rec.TimedOut, rec.DryRun, rec.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to log command: %w", err)
+	}
+	return nil
+}
+
+// RecentCommands returns the latest audit log entries, newest first
+func (s *Store) RecentCommands(ctx context.Context, limit int) ([]CommandRecord, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, command, COALESCE(dir, ''), source, decision, exit_code, COALESCE(duration_ms, 0), timed_out, dry_run, created_at
+		FROM command_audit ORDER BY created_at DESC LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commands: %w", err)
+	}
+	defer rows.Close()
+
+	var records []CommandRecord
+	for rows.Next() {
+		var rec CommandRecord
+		var exitCode sql.NullInt64
+		var durationMS int64
+		if err := rows.Scan(&rec.ID, &rec.Command, &rec.Dir, &rec.Source, &rec.Decision,
+			&exitCode, &durationMS, &rec.TimedOut, &rec.DryRun, &rec.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan command: %w", err)
+		}
+		if exitCode.Valid {
+			code := int(exitCode.Int64)
+			rec.ExitCode = &code
+		}
+		rec.Duration = time.Duration(durationMS) * time.Millisecond
+		records = append(records, rec)
+	}
+
+	return records, rows.Err()
+}
@@ -4,35 +4,75 @@ import (
 	"context"
 	"database/sql"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"math"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	_ "modernc.org/sqlite"
 )
 
 // Store manages the SQLite database with vector embeddings
 type Store struct {
 	db *sql.DB
+
+	// appendMu serializes AppendMessage within the process
+	appendMu sync.Mutex
 }
 
 // Message represents a chat message with optional embedding
 type Message struct {
 	ID             string
 	ConversationID string
-	Role           string // "user", "assistant", "system"
+	ParentID       string // previous message in the branch, empty for the first
+	Seq            int    // insertion order within the conversation
+	Role           string // "user", "assistant", "system", "tool"
 	Content        string
+	Thinking       string // reasoning behind an assistant message, never embedded
 	Embedding      []float32
 	CreatedAt      time.Time
+	Attachments    []Attachment
+
+	// Backend and model that wrote an assistant message, when known
+	Backend string
+	Model   string
+
+	// Tokens and timings of an assistant message, zero when unknown
+	PromptTokens     int
+	CompletionTokens int
+	FirstToken       time.Duration
+	EvalDuration     time.Duration
+	Duration         time.Duration
+
+	// The call a "tool" message answers; Content holds its result
+	ToolName   string
+	ToolArgs   map[string]any
+	ToolCallID string
+	ToolError  string
+}
+
+// Attachment records local context that was included with a message
+type Attachment struct {
+	ID        string
+	MessageID string
+	Kind      string // "file", "dir", "image", "command"
+	Path      string
+	Size      int64
+	Content   string
+	Truncated bool
 }
 
 // Conversation represents a chat conversation
 type Conversation struct {
-	ID        string
-	Title     string
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Messages  []Message
+	ID           string
+	Title        string
+	ActiveLeafID string // last message of the branch being viewed
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Messages     []Message // the active branch, first message first
 }
 
 // SearchResult represents a semantic search result
@@ -43,7 +83,7 @@ type SearchResult struct {
 
 // NewStore creates a new memory store
 func NewStore(dbPath string) (*Store, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open("sqlite", dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -77,8 +117,34 @@ func (s *Store) init() error {
 		FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
 	);
 
+	CREATE TABLE IF NOT EXISTS attachments (
+		id TEXT PRIMARY KEY,
+		message_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		path TEXT NOT NULL,
+		size INTEGER,
+		content TEXT,
+		truncated INTEGER DEFAULT 0,
+		FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS command_audit (
+		id TEXT PRIMARY KEY,
+		command TEXT NOT NULL,
+		dir TEXT,
+		source TEXT NOT NULL,
+		decision TEXT NOT NULL,
+		exit_code INTEGER,
+		duration_ms INTEGER,
+		timed_out INTEGER DEFAULT 0,
+		dry_run INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
 	CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages(conversation_id);
 	CREATE INDEX IF NOT EXISTS idx_messages_created ON messages(created_at);
+	CREATE INDEX IF NOT EXISTS idx_attachments_message ON attachments(message_id);
+	CREATE INDEX IF NOT EXISTS idx_command_audit_created ON command_audit(created_at);
 	`
 
 	_, err := s.db.Exec(schema)
@@ -86,9 +152,166 @@ func (s *Store) init() error {
 		return fmt.Errorf("failed to create schema: %w", err)
 	}
 
+	return s.migrate()
+}
+
+// columnMigrations lists columns added after the initial schema
+var columnMigrations = []struct {
+	table, column, definition string
+}{
+	{"messages", "parent_id", "TEXT"},
+	{"messages", "seq", "INTEGER"},
+	{"conversations", "active_leaf_id", "TEXT"},
+	{"messages", "backend", "TEXT"},
+	{"messages", "model", "TEXT"},
+	{"messages", "prompt_tokens", "INTEGER"},
+	{"messages", "completion_tokens", "INTEGER"},
+	{"messages", "first_token_ms", "INTEGER"},
+	{"messages", "eval_ms", "INTEGER"},
+	{"messages", "duration_ms", "INTEGER"},
+	{"messages", "thinking", "TEXT"},
+	{"messages", "tool_name", "TEXT"},
+	{"messages", "tool_args", "TEXT"},
+	{"messages", "tool_call_id", "TEXT"},
+	{"messages", "tool_error", "TEXT"},
+}
+
+// migrate brings databases created by older versions up to date
+func (s *Store) migrate() error {
+	for _, m := range columnMigrations {
+		columns, err := s.columns(m.table)
+		if err != nil {
+			return err
+		}
+		if columns[m.column] {
+			continue
+		}
+
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition)
+		if _, err := s.db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to add %s.%s: %w", m.table, m.column, err)
+		}
+	}
+
+	indexes := `
+	CREATE INDEX IF NOT EXISTS idx_messages_parent ON messages(parent_id);
+	CREATE INDEX IF NOT EXISTS idx_messages_seq ON messages(conversation_id, seq);
+	`
+	if _, err := s.db.Exec(indexes); err != nil {
+		return fmt.Errorf("failed to create indexes: %w", err)
+	}
+
+	return s.backfillTree()
+}
+
+// backfillTree numbers messages saved before sequences existed and links
+// conversations saved before parent IDs existed into a single branch
+func (s *Store) backfillTree() error {
+	rows, err := s.db.Query("SELECT DISTINCT conversation_id FROM messages WHERE seq IS NULL")
+	if err != nil {
+		return fmt.Errorf("failed to find unsequenced messages: %w", err)
+	}
+	var convIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan conversation: %w", err)
+		}
+		convIDs = append(convIDs, id)
+	}
+	rows.Close()
+
+	for _, convID := range convIDs {
+		if err := s.backfillConversation(convID); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// backfillConversation assigns sequence numbers, parents and the active leaf
+// for one conversation
+func (s *Store) backfillConversation(convID string) error {
+	rows, err := s.db.Query(
+		"SELECT id, COALESCE(parent_id, '') FROM messages WHERE conversation_id = ? ORDER BY created_at, rowid",
+		convID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to read messages: %w", err)
+	}
+
+	var ids, parents []string
+	linked := false
+	for rows.Next() {
+		var id, parentID string
+		if err := rows.Scan(&id, &parentID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan message: %w", err)
+		}
+		ids = append(ids, id)
+		parents = append(parents, parentID)
+		linked = linked || parentID != ""
+	}
+	rows.Close()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, id := range ids {
+		// Flat histories become one branch in chronological order
+		parentID := parents[i]
+		if !linked && i > 0 {
+			parentID = ids[i-1]
+		}
+		if _, err := tx.Exec("UPDATE messages SET seq = ?, parent_id = ? WHERE id = ?", i+1, nullString(parentID), id); err != nil {
+			return fmt.Errorf("failed to backfill message: %w", err)
+		}
+	}
+
+	if len(ids) > 0 {
+		if _, err := tx.Exec(
+			"UPDATE conversations SET active_leaf_id = ? WHERE id = ? AND active_leaf_id IS NULL",
+			ids[len(ids)-1], convID,
+		); err != nil {
+			return fmt.Errorf("failed to backfill active leaf: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// columns returns the set of column names in a table
+func (s *Store) columns(table string) (map[string]bool, error) {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			ctype     string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &dfltValue, &pk); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		columns[name] = true
+	}
+
+	return columns, rows.Err()
+}
+
 // Close closes the database connection
 func (s *Store) Close() error {
 	return s.db.Close()
@@ -113,14 +336,15 @@ func (s *Store) CreateConversation(ctx context.Context, id, title string) (*Conv
 	}, nil
 }
 
-// GetConversation retrieves a conversation with its messages
+// GetConversation retrieves a conversation with the messages of its active
+// branch. Use GetTree to walk every branch.
 func (s *Store) GetConversation(ctx context.Context, id string) (*Conversation, error) {
 	row := s.db.QueryRowContext(ctx,
-		"SELECT id, title, created_at, updated_at FROM conversations WHERE id = ?", id,
+		"SELECT id, title, COALESCE(active_leaf_id, ''), created_at, updated_at FROM conversations WHERE id = ?", id,
 	)
 
 	var conv Conversation
-	err := row.Scan(&conv.ID, &conv.Title, &conv.CreatedAt, &conv.UpdatedAt)
+	err := row.Scan(&conv.ID, &conv.Title, &conv.ActiveLeafID, &conv.CreatedAt, &conv.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -128,23 +352,22 @@ func (s *Store) GetConversation(ctx context.Context, id string) (*Conversation,
 		return nil, fmt.Errorf("failed to get conversation: %w", err)
 	}
 
-	// Get messages
-	rows, err := s.db.QueryContext(ctx,
-		"SELECT id, conversation_id, role, content, created_at FROM messages WHERE conversation_id = ? ORDER BY created_at",
-		id,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get messages: %w", err)
+	// Fall back to the newest message when no leaf has been recorded
+	leafID := conv.ActiveLeafID
+	if leafID == "" {
+		err := s.db.QueryRowContext(ctx,
+			"SELECT id FROM messages WHERE conversation_id = ? ORDER BY seq DESC LIMIT 1", id,
+		).Scan(&leafID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to get messages: %w", err)
+		}
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var msg Message
-		err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.Role, &msg.Content, &msg.CreatedAt)
+	if leafID != "" {
+		conv.Messages, err = s.GetThread(ctx, leafID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan message: %w", err)
+			return nil, err
 		}
-		conv.Messages = append(conv.Messages, msg)
 	}
 
 	return &conv, nil
@@ -153,7 +376,7 @@ func (s *Store) GetConversation(ctx context.Context, id string) (*Conversation,
 // ListConversations returns recent conversations
 func (s *Store) ListConversations(ctx context.Context, limit int) ([]Conversation, error) {
 	rows, err := s.db.QueryContext(ctx,
-		"SELECT id, title, created_at, updated_at FROM conversations ORDER BY updated_at DESC LIMIT ?",
+		"SELECT id, title, COALESCE(active_leaf_id, ''), created_at, updated_at FROM conversations ORDER BY updated_at DESC LIMIT ?",
 		limit,
 	)
 	if err != nil {
@@ -164,7 +387,7 @@ func (s *Store) ListConversations(ctx context.Context, limit int) ([]Conversatio
 	var convs []Conversation
 	for rows.Next() {
 		var conv Conversation
-		err := rows.Scan(&conv.ID, &conv.Title, &conv.CreatedAt, &conv.UpdatedAt)
+		err := rows.Scan(&conv.ID, &conv.Title, &conv.ActiveLeafID, &conv.CreatedAt, &conv.UpdatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan conversation: %w", err)
 		}
@@ -174,28 +397,168 @@ func (s *Store) ListConversations(ctx context.Context, limit int) ([]Conversatio
 	return convs, nil
 }
 
-// SaveMessage saves a message with its embedding
+// DeleteConversation removes a conversation with its messages and
+// attachments. It returns false if the conversation did not exist.
+func (s *Store) DeleteConversation(ctx context.Context, id string) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete conversation: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM attachments WHERE message_id IN (SELECT id FROM messages WHERE conversation_id = ?)", id,
+	); err != nil {
+		return false, fmt.Errorf("failed to delete attachments: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM messages WHERE conversation_id = ?", id); err != nil {
+		return false, fmt.Errorf("failed to delete messages: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, "DELETE FROM conversations WHERE id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete conversation: %w", err)
+	}
+	n, _ := res.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to delete conversation: %w", err)
+	}
+	return n > 0, nil
+}
+
+// SaveMessage saves a message with its embedding and attachments, all or
+// nothing
 func (s *Store) SaveMessage(ctx context.Context, msg *Message) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to save message: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := saveMessage(ctx, tx, msg); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save message: %w", err)
+	}
+	return nil
+}
+
+// AppendMessage saves a message at the tip of its conversation's active
+// branch, creating the conversation with title if needed. The tip is read
+// and the message saved in one transaction, so concurrent appends can't
+// both claim the same parent.
+func (s *Store) AppendMessage(ctx context.Context, msg *Message, title string) error {
+	msgs := []Message{*msg}
+	if err := s.AppendMessages(ctx, msgs, title); err != nil {
+		return err
+	}
+	*msg = msgs[0]
+	return nil
+}
+
+// AppendMessages saves a thread of messages at the tip of their
+// conversation's active branch, like AppendMessage: the first follows the
+// tip and each later one follows the one before it.
+func (s *Store) AppendMessages(ctx context.Context, msgs []Message, title string) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	s.appendMu.Lock()
+	defer s.appendMu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to save message: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Writing first takes the database's write lock before the tip is read
+	conversationID := msgs[0].ConversationID
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx,
+		"INSERT OR IGNORE INTO conversations (id, title, created_at, updated_at) VALUES (?, ?, ?, ?)",
+		conversationID, title, now, now,
+	); err != nil {
+		return fmt.Errorf("failed to create conversation: %w", err)
+	}
+	var leaf sql.NullString
+	if err := tx.QueryRowContext(ctx,
+		"SELECT active_leaf_id FROM conversations WHERE id = ?", conversationID,
+	).Scan(&leaf); err != nil {
+		return fmt.Errorf("failed to find the active branch: %w", err)
+	}
+
+	parentID := leaf.String
+	for i := range msgs {
+		msg := &msgs[i]
+		msg.ConversationID = conversationID
+		msg.ParentID = parentID
+		if err := saveMessage(ctx, tx, msg); err != nil {
+			return err
+		}
+		parentID = msg.ID
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save message: %w", err)
+	}
+	return nil
+}
+
+// saveMessage inserts a message and its attachments within tx
+func saveMessage(ctx context.Context, tx *sql.Tx, msg *Message) error {
 	var embeddingBlob []byte
 	if len(msg.Embedding) > 0 {
 		embeddingBlob = serializeFloat32(msg.Embedding)
 	}
+	var toolArgs sql.NullString
+	if msg.ToolName != "" {
+		data, err := json.Marshal(msg.ToolArgs)
+		if err != nil {
+			return fmt.Errorf("failed to encode tool arguments: %w", err)
+		}
+		toolArgs = sql.NullString{String: string(data), Valid: true}
+	}
 
-	_, err := s.db.ExecContext(ctx,
-		"INSERT INTO messages (id, conversation_id, role, content, embedding, created_at) VALUES (?, ?, ?, ?, ?, ?)",
-		msg.ID, msg.ConversationID, msg.Role, msg.Content, embeddingBlob, msg.CreatedAt,
-	)
+	err := tx.QueryRowContext(ctx,
+		`INSERT INTO messages (id, conversation_id, parent_id, seq, role, content, thinking, embedding, created_at, backend, model,
+			prompt_tokens, completion_tokens, first_token_ms, eval_ms, duration_ms, tool_name, tool_args, tool_call_id, tool_error)
+		VALUES (?, ?, ?, (SELECT COALESCE(MAX(seq), 0) + 1 FROM messages WHERE conversation_id = ?), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING seq`,
+		msg.ID, msg.ConversationID, nullString(msg.ParentID), msg.ConversationID, msg.Role, msg.Content, nullString(msg.Thinking), embeddingBlob, msg.CreatedAt,
+		nullString(msg.Backend), nullString(msg.Model),
+		nullInt(int64(msg.PromptTokens)), nullInt(int64(msg.CompletionTokens)),
+		nullInt(msg.FirstToken.Milliseconds()), nullInt(msg.EvalDuration.Milliseconds()), nullInt(msg.Duration.Milliseconds()),
+		nullString(msg.ToolName), toolArgs, nullString(msg.ToolCallID), nullString(msg.ToolError),
+	).Scan(&msg.Seq)
 	if err != nil {
 		return fmt.Errorf("failed to save message: %w", err)
 	}
 
-	// Update conversation timestamp
-	_, err = s.db.ExecContext(ctx,
-		"UPDATE conversations SET updated_at = ? WHERE id = ?",
-		time.Now(), msg.ConversationID,
-	)
+	for i := range msg.Attachments {
+		att := &msg.Attachments[i]
+		if att.ID == "" {
+			att.ID = uuid.New().String()
+		}
+		att.MessageID = msg.ID
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO attachments (id, message_id, kind, path, size, content, truncated) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			att.ID, att.MessageID, att.Kind, att.Path, att.Size, att.Content, att.Truncated,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save attachment: %w", err)
+		}
+	}
 
-	return err
+	// The newest message becomes the tip of the active branch
+	_, err = tx.ExecContext(ctx,
+		"UPDATE conversations SET updated_at = ?, active_leaf_id = ? WHERE id = ?",
+		time.Now(), msg.ID, msg.ConversationID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update conversation: %w", err)
+	}
+	return nil
 }
 
 // Search performs semantic search over messages using cosine similarity
@@ -244,6 +607,44 @@ func (s *Store) Search(ctx context.Context, queryEmbedding []float32, limit int)
 	return results, nil
 }
 
+// KeywordSearch returns messages containing every word of the query,
+// ignoring case, newest first
+func (s *Store) KeywordSearch(ctx context.Context, query string, limit int) ([]Message, error) {
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	escape := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	conds := make([]string, len(words))
+	args := make([]any, 0, len(words)+1)
+	for i, word := range words {
+		conds[i] = `content LIKE ? ESCAPE '\'`
+		args = append(args, "%"+escape.Replace(word)+"%")
+	}
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+messageColumns+" FROM messages WHERE "+strings.Join(conds, " AND ")+" ORDER BY created_at DESC LIMIT ?",
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		messages = append(messages, *msg)
+	}
+
+	return messages, rows.Err()
+}
+
 // GetMessageCount returns the total number of messages
 func (s *Store) GetMessageCount(ctx context.Context) (int, error) {
 	var count int
@@ -269,6 +670,82 @@ func (s *Store) GetLastConversation(ctx context.Context) (*Conversation, error)
 	return s.GetConversation(ctx, id)
 }
 
+// GetAttachments returns the attachments of the given messages keyed by
+// message ID
+func (s *Store) GetAttachments(ctx context.Context, messageIDs []string) (map[string][]Attachment, error) {
+	result := make(map[string][]Attachment)
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(messageIDs)), ",")
+	args := make([]any, len(messageIDs))
+	for i, id := range messageIDs {
+		args[i] = id
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, message_id, kind, path, COALESCE(size, 0), COALESCE(content, ''), truncated FROM attachments WHERE message_id IN ("+placeholders+") ORDER BY rowid",
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var att Attachment
+		if err := rows.Scan(&att.ID, &att.MessageID, &att.Kind, &att.Path, &att.Size, &att.Content, &att.Truncated); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		result[att.MessageID] = append(result[att.MessageID], att)
+	}
+
+	return result, rows.Err()
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// messageColumns lists the columns read by scanMessage
+const messageColumns = "id, conversation_id, parent_id, COALESCE(seq, 0), role, content, COALESCE(thinking, ''), created_at, COALESCE(backend, ''), COALESCE(model, ''), " +
+	"COALESCE(prompt_tokens, 0), COALESCE(completion_tokens, 0), COALESCE(first_token_ms, 0), COALESCE(eval_ms, 0), COALESCE(duration_ms, 0), " +
+	"COALESCE(tool_name, ''), COALESCE(tool_args, ''), COALESCE(tool_call_id, ''), COALESCE(tool_error, '')"
+
+// scanMessage scans a row selected with messageColumns
+func scanMessage(row rowScanner) (*Message, error) {
+	var msg Message
+	var parentID sql.NullString
+	var firstToken, eval, duration int64
+	var toolArgs string
+	if err := row.Scan(&msg.ID, &msg.ConversationID, &parentID, &msg.Seq, &msg.Role, &msg.Content, &msg.Thinking, &msg.CreatedAt, &msg.Backend, &msg.Model,
+		&msg.PromptTokens, &msg.CompletionTokens, &firstToken, &eval, &duration,
+		&msg.ToolName, &toolArgs, &msg.ToolCallID, &msg.ToolError); err != nil {
+		return nil, err
+	}
+	if toolArgs != "" {
+		// Arguments that no longer decode are shown as none
+		json.Unmarshal([]byte(toolArgs), &msg.ToolArgs)
+	}
+	msg.ParentID = parentID.String
+	msg.FirstToken = time.Duration(firstToken) * time.Millisecond
+	msg.EvalDuration = time.Duration(eval) * time.Millisecond
+	msg.Duration = time.Duration(duration) * time.Millisecond
+	return &msg, nil
+}
+
+// nullString stores empty strings as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullInt stores zero as NULL
+func nullInt(n int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: n, Valid: n != 0}
+}
+
 // serializeFloat32 converts a slice of float32 to bytes
 func serializeFloat32(data []float32) []byte {
 	buf := make([]byte, len(data)*4)
@@ -0,0 +1,282 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Node is a message in a conversation tree together with its replies
+type Node struct {
+	Message  Message
+	Children []*Node
+}
+
+// Walk visits the node and its descendants depth-first, replies in the order
+// they were written. Returning false from fn skips the node's children.
+func (n *Node) Walk(fn func(node *Node, depth int) bool) {
+	n.walk(fn, 0)
+}
+
+func (n *Node) walk(fn func(node *Node, depth int) bool, depth int) {
+	if !fn(n, depth) {
+		return
+	}
+	for _, child := range n.Children {
+		child.walk(fn, depth+1)
+	}
+}
+
+// GetMessage retrieves a single message by ID
+func (s *Store) GetMessage(ctx context.Context, id string) (*Message, error) {
+	row := s.db.QueryRowContext(ctx,
+		"SELECT "+messageColumns+" FROM messages WHERE id = ?", id,
+	)
+
+	msg, err := scanMessage(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+
+	return msg, nil
+}
+
+// GetTree returns every branch of a conversation as a forest of first
+// messages. Conversations normally have one root, but editing the first
+// message creates siblings at the top level.
+func (s *Store) GetTree(ctx context.Context, conversationID string) ([]*Node, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+messageColumns+" FROM messages WHERE conversation_id = ? ORDER BY seq",
+		conversationID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	defer rows.Close()
+
+	var nodes []*Node
+	byID := make(map[string]*Node)
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		node := &Node{Message: *msg}
+		nodes = append(nodes, node)
+		byID[msg.ID] = node
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Messages whose parent is missing are treated as roots
+	var roots []*Node
+	for _, node := range nodes {
+		if parent, ok := byID[node.Message.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	return roots, nil
+}
+
+// GetChildren returns the replies to a message, oldest first. An empty
+// parentID returns the first messages of the conversation.
+func (s *Store) GetChildren(ctx context.Context, conversationID, parentID string) ([]Message, error) {
+	query := "SELECT " + messageColumns + " FROM messages WHERE conversation_id = ? AND parent_id = ? ORDER BY seq"
+	args := []any{conversationID, parentID}
+	if parentID == "" {
+		query = "SELECT " + messageColumns + " FROM messages WHERE conversation_id = ? AND parent_id IS NULL ORDER BY seq"
+		args = args[:1]
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get children: %w", err)
+	}
+	defer rows.Close()
+
+	var msgs []Message
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		msgs = append(msgs, *msg)
+	}
+
+	return msgs, rows.Err()
+}
+
+// GetThread returns the branch ending at leafID, from the first message down
+func (s *Store) GetThread(ctx context.Context, leafID string) ([]Message, error) {
+	var thread []Message
+	seen := make(map[string]bool)
+
+	for id := leafID; id != "" && !seen[id]; {
+		seen[id] = true
+		msg, err := s.GetMessage(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if msg == nil {
+			break
+		}
+		thread = append(thread, *msg)
+		id = msg.ParentID
+	}
+
+	// Reverse into chronological order
+	for i, j := 0, len(thread)-1; i < j; i, j = i+1, j-1 {
+		thread[i], thread[j] = thread[j], thread[i]
+	}
+
+	ids := make([]string, len(thread))
+	for i := range thread {
+		ids[i] = thread[i].ID
+	}
+
+	attachments, err := s.GetAttachments(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range thread {
+		thread[i].Attachments = attachments[thread[i].ID]
+	}
+
+	return thread, nil
+}
+
+// GetLatestLeaf follows the most recent reply from a message until it reaches
+// the end of that branch
+func (s *Store) GetLatestLeaf(ctx context.Context, messageID string) (string, error) {
+	leaf := messageID
+	for {
+		var next string
+		err := s.db.QueryRowContext(ctx,
+			"SELECT id FROM messages WHERE parent_id = ? ORDER BY seq DESC LIMIT 1", leaf,
+		).Scan(&next)
+		if err == sql.ErrNoRows {
+			return leaf, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to follow branch: %w", err)
+		}
+		leaf = next
+	}
+}
+
+// GetBranchPoints returns, for every message with more than one reply, the
+// IDs of those replies oldest first. Alternative first messages are keyed by
+// the empty string.
+func (s *Store) GetBranchPoints(ctx context.Context, conversationID string) (map[string][]string, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, COALESCE(parent_id, '') FROM messages WHERE conversation_id = ? ORDER BY seq",
+		conversationID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get branches: %w", err)
+	}
+	defer rows.Close()
+
+	children := make(map[string][]string)
+	for rows.Next() {
+		var id, parentID string
+		if err := rows.Scan(&id, &parentID); err != nil {
+			return nil, fmt.Errorf("failed to scan branch: %w", err)
+		}
+		children[parentID] = append(children[parentID], id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for parentID, ids := range children {
+		if len(ids) < 2 {
+			delete(children, parentID)
+		}
+	}
+
+	return children, nil
+}
+
+// SetActiveLeaf records which branch of a conversation is being viewed
+func (s *Store) SetActiveLeaf(ctx context.Context, conversationID, leafID string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE conversations SET active_leaf_id = ? WHERE id = ?",
+		leafID, conversationID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set active leaf: %w", err)
+	}
+	return nil
+}
+
+// ForkConversation copies the branch ending at messageID into a new
+// conversation. Messages get new IDs; content and embeddings are kept.
+func (s *Store) ForkConversation(ctx context.Context, messageID, newID, title string) (*Conversation, error) {
+	thread, err := s.GetThread(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if len(thread) == 0 {
+		return nil, fmt.Errorf("message %s not found", messageID)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fork conversation: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO conversations (id, title, created_at, updated_at) VALUES (?, ?, ?, ?)",
+		newID, title, now, now,
+	); err != nil {
+		return nil, fmt.Errorf("failed to create conversation: %w", err)
+	}
+
+	parentID := ""
+	for i, msg := range thread {
+		id := uuid.New().String()
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO messages (id, conversation_id, parent_id, seq, role, content, thinking, embedding, created_at, backend, model,
+				prompt_tokens, completion_tokens, first_token_ms, eval_ms, duration_ms, tool_name, tool_args, tool_call_id, tool_error)
+			SELECT ?, ?, ?, ?, role, content, thinking, embedding, created_at, backend, model,
+				prompt_tokens, completion_tokens, first_token_ms, eval_ms, duration_ms, tool_name, tool_args, tool_call_id, tool_error FROM messages WHERE id = ?`,
+			id, newID, nullString(parentID), i+1, msg.ID,
+		); err != nil {
+			return nil, fmt.Errorf("failed to copy message: %w", err)
+		}
+		for _, att := range msg.Attachments {
+			if _, err := tx.ExecContext(ctx,
+				"INSERT INTO attachments (id, message_id, kind, path, size, content, truncated) VALUES (?, ?, ?, ?, ?, ?, ?)",
+				uuid.New().String(), id, att.Kind, att.Path, att.Size, att.Content, att.Truncated,
+			); err != nil {
+				return nil, fmt.Errorf("failed to copy attachment: %w", err)
+			}
+		}
+		parentID = id
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE conversations SET active_leaf_id = ? WHERE id = ?", parentID, newID,
+	); err != nil {
+		return nil, fmt.Errorf("failed to set active leaf: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to fork conversation: %w", err)
+	}
+
+	return s.GetConversation(ctx, newID)
+}
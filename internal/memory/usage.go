@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ModelUsage sums the replies one model wrote
+type ModelUsage struct {
+	Model            string
+	Replies          int
+	PromptTokens     int
+	CompletionTokens int
+	EvalDuration     time.Duration
+	FirstToken       time.Duration // average over the replies
+}
+
+// TokensPerSecond is the model's average generation speed, or 0 when unknown
+func (u ModelUsage) TokensPerSecond() float64 {
+	if u.CompletionTokens == 0 || u.EvalDuration <= 0 {
+		return 0
+	}
+	return float64(u.CompletionTokens) / u.EvalDuration.Seconds()
+}
+
+// DayUsage sums the tokens used on one day
+type DayUsage struct {
+	Day    string // YYYY-MM-DD
+	Tokens int
+}
+
+// usageSince limits queries to assistant messages with recorded usage,
+// written on or after a day
+const usageSince = "role = 'assistant' AND completion_tokens > 0 AND substr(created_at, 1, 10) >= ?"
+
+// UsageByModel sums the usage of each model since the start of a day, most
+// used first
+func (s *Store) UsageByModel(ctx context.Context, since time.Time) ([]ModelUsage, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT COALESCE(model, ''), COUNT(*), SUM(COALESCE(prompt_tokens, 0)), SUM(completion_tokens),
+			SUM(COALESCE(eval_ms, 0)), AVG(COALESCE(first_token_ms, 0))
+		FROM messages WHERE `+usageSince+`
+		GROUP BY COALESCE(model, '') ORDER BY SUM(COALESCE(prompt_tokens, 0)) + SUM(completion_tokens) DESC`,
+		since.Format(time.DateOnly),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []ModelUsage
+	for rows.Next() {
+		var u ModelUsage
+		var eval int64
+		var firstToken float64
+		if err := rows.Scan(&u.Model, &u.Replies, &u.PromptTokens, &u.CompletionTokens, &eval, &firstToken); err != nil {
+			return nil, fmt.Errorf("failed to scan usage: %w", err)
+		}
+		u.EvalDuration = time.Duration(eval) * time.Millisecond
+		u.FirstToken = time.Duration(firstToken * float64(time.Millisecond))
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// UsageByDay sums the tokens used on each day since the start of a day.
+// Days without usage are left out.
+func (s *Store) UsageByDay(ctx context.Context, since time.Time) ([]DayUsage, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT substr(created_at, 1, 10) AS day, SUM(COALESCE(prompt_tokens, 0) + completion_tokens)
+		FROM messages WHERE `+usageSince+`
+		GROUP BY day ORDER BY day`,
+		since.Format(time.DateOnly),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum usage: %w", err)
+	}
+	defer rows.Close()
+
+	var days []DayUsage
+	for rows.Next() {
+		var d DayUsage
+		if err := rows.Scan(&d.Day, &d.Tokens); err != nil {
+			return nil, fmt.Errorf("failed to scan usage: %w", err)
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
@@ -2,37 +2,54 @@ package ollama
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
-	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/diiviikk5/dvkcli/internal/provider"
+	"github.com/diiviikk5/dvkcli/internal/tools"
 	"github.com/ollama/ollama/api"
 )
 
 // Client wraps the Ollama API client
 type Client struct {
 	api        *api.Client
-	Model      string
-	EmbedModel string
+	embedModel string
 	BaseURL    string
 	IsCloud    bool
 	apiKey     string
-}
 
-// Model represents an Ollama model
-type Model struct {
-	Name       string
-	Size       int64
-	ModifiedAt time.Time
+	// Retry controls how transient chat failures are retried
+	Retry provider.RetryPolicy
+	// Think turns reasoning off or on per model name, with or without its
+	// tag. Models that can think do unless listed as false.
+	Think map[string]bool
+
+	mu    sync.RWMutex
+	model string
+
+	// Capabilities by model name, which don't change while a model is installed
+	capsMu sync.Mutex
+	caps   map[string][]string
 }
 
+// Client implements provider.Provider and the optional model interfaces
+var (
+	_ provider.Provider      = (*Client)(nil)
+	_ provider.ModelManager  = (*Client)(nil)
+	_ provider.ProcessLister = (*Client)(nil)
+)
+
 // NewClient creates a new Ollama client
-// It auto-detects cloud mode if OLLAMA_API_KEY is set
-func NewClient(baseURL, model, embedModel string) (*Client, error) {
-	apiKey := os.Getenv("OLLAMA_API_KEY")
+// An API key selects Ollama's cloud unless baseURL is set
+func NewClient(baseURL, apiKey, model, embedModel string) (*Client, error) {
 	isCloud := apiKey != ""
 
 	// Use cloud URL if API key is set and no custom URL provided
@@ -58,15 +75,14 @@ func NewClient(baseURL, model, embedModel string) (*Client, error) {
 		return nil, fmt.Errorf("invalid base URL: %w", err)
 	}
 
-	// Create HTTP client with auth header for cloud
-	httpClient := &http.Client{
-		Timeout: 5 * time.Minute,
-	}
+	// Create HTTP client with auth header for cloud. There is no overall
+	// timeout, since pulls and long replies stream for as long as they take.
+	httpClient := &http.Client{Transport: newTransport()}
 
 	if isCloud {
 		httpClient.Transport = &authTransport{
 			apiKey: apiKey,
-			base:   http.DefaultTransport,
+			base:   httpClient.Transport,
 		}
 	}
 
@@ -74,14 +90,26 @@ func NewClient(baseURL, model, embedModel string) (*Client, error) {
 
 	return &Client{
 		api:        client,
-		Model:      model,
-		EmbedModel: embedModel,
+		model:      model,
+		embedModel: embedModel,
 		BaseURL:    baseURL,
 		IsCloud:    isCloud,
 		apiKey:     apiKey,
 	}, nil
 }
 
+// newTransport bounds connecting and waiting for a response to start, but
+// not reading it. Requests are otherwise bounded by their context.
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = 10 * time.Second
+	// Ollama may load the model before it answers
+	transport.ResponseHeaderTimeout = 5 * time.Minute
+	transport.IdleConnTimeout = 90 * time.Second
+	return transport
+}
+
 // authTransport adds Authorization header to requests
 type authTransport struct {
 	apiKey string
@@ -93,6 +121,11 @@ func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return t.base.RoundTrip(req)
 }
 
+// Name identifies the backend
+func (c *Client) Name() string {
+	return "ollama"
+}
+
 // IsConnected checks if Ollama is running and accessible
 func (c *Client) IsConnected(ctx context.Context) bool {
 	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
@@ -103,15 +136,15 @@ func (c *Client) IsConnected(ctx context.Context) bool {
 }
 
 // ListModels returns available local models
-func (c *Client) ListModels(ctx context.Context) ([]Model, error) {
+func (c *Client) ListModels(ctx context.Context) ([]provider.Model, error) {
 	resp, err := c.api.List(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list models: %w", err)
 	}
 
-	models := make([]Model, 0, len(resp.Models))
+	models := make([]provider.Model, 0, len(resp.Models))
 	for _, m := range resp.Models {
-		models = append(models, Model{
+		models = append(models, provider.Model{
 			Name:       m.Name,
 			Size:       m.Size,
 			ModifiedAt: m.ModifiedAt,
@@ -120,89 +153,73 @@ func (c *Client) ListModels(ctx context.Context) ([]Model, error) {
 	return models, nil
 }
 
-// StreamResponse represents a chunk of streamed response
-type StreamResponse struct {
-	Content string
-	Done    bool
-	Error   error
-}
-
-// Stream sends a prompt and streams response chunks via a channel
-func (c *Client) Stream(ctx context.Context, prompt string, systemPrompt string) <-chan StreamResponse {
-	ch := make(chan StreamResponse)
-
-	go func() {
-		defer close(ch)
-
-		messages := []api.Message{}
-
-		if systemPrompt != "" {
-			messages = append(messages, api.Message{
-				Role:    "system",
-				Content: systemPrompt,
-			})
-		}
-
-		messages = append(messages, api.Message{
-			Role:    "user",
-			Content: prompt,
-		})
-
-		req := &api.ChatRequest{
-			Model:    c.Model,
-			Messages: messages,
-			Stream:   boolPtr(true),
-		}
+// ListRunning returns the models loaded in memory
+func (c *Client) ListRunning(ctx context.Context) ([]provider.RunningModel, error) {
+	resp, err := c.api.ListRunning(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list running models: %w", classify(err))
+	}
 
-		err := c.api.Chat(ctx, req, func(resp api.ChatResponse) error {
-			ch <- StreamResponse{
-				Content: resp.Message.Content,
-				Done:    resp.Done,
-			}
-			return nil
+	models := make([]provider.RunningModel, 0, len(resp.Models))
+	for _, m := range resp.Models {
+		models = append(models, provider.RunningModel{
+			Name:          m.Name,
+			Size:          m.Size,
+			SizeVRAM:      m.SizeVRAM,
+			ContextLength: m.ContextLength,
+			ExpiresAt:     m.ExpiresAt,
 		})
-
-		if err != nil {
-			ch <- StreamResponse{Error: err}
-		}
-	}()
-
-	return ch
+	}
+	return models, nil
 }
 
-// StreamWithHistory sends a conversation with history and streams response
-func (c *Client) StreamWithHistory(ctx context.Context, messages []api.Message) <-chan StreamResponse {
-	ch := make(chan StreamResponse)
+// Capabilities returns what a model supports, such as "vision" or "tools"
+func (c *Client) Capabilities(ctx context.Context, model string) ([]string, error) {
+	c.capsMu.Lock()
+	cached, ok := c.caps[model]
+	c.capsMu.Unlock()
+	if ok {
+		return cached, nil
+	}
 
-	go func() {
-		defer close(ch)
+	resp, err := c.api.Show(ctx, &api.ShowRequest{Model: model})
+	if err != nil {
+		return nil, fmt.Errorf("failed to show model: %w", err)
+	}
 
-		req := &api.ChatRequest{
-			Model:    c.Model,
-			Messages: messages,
-			Stream:   boolPtr(true),
-		}
+	caps := make([]string, 0, len(resp.Capabilities))
+	for _, capability := range resp.Capabilities {
+		caps = append(caps, string(capability))
+	}
 
-		err := c.api.Chat(ctx, req, func(resp api.ChatResponse) error {
-			ch <- StreamResponse{
-				Content: resp.Message.Content,
-				Done:    resp.Done,
-			}
-			return nil
-		})
+	c.capsMu.Lock()
+	if c.caps == nil {
+		c.caps = make(map[string][]string)
+	}
+	c.caps[model] = caps
+	c.capsMu.Unlock()
 
-		if err != nil {
-			ch <- StreamResponse{Error: err}
-		}
-	}()
+	return caps, nil
+}
 
-	return ch
+// HasCapability reports whether a model lists the given capability
+func (c *Client) HasCapability(ctx context.Context, model, capability string) (bool, error) {
+	caps, err := c.Capabilities(ctx, model)
+	if err != nil {
+		return false, err
+	}
+	for _, capName := range caps {
+		if capName == capability {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // Embed generates embeddings for text
 func (c *Client) Embed(ctx context.Context, text string) ([]float32, error) {
 	req := &api.EmbedRequest{
-		Model: c.EmbedModel,
+		Model: c.embedModel,
 		Input: text,
 	}
 
@@ -224,31 +241,269 @@ func (c *Client) Embed(ctx context.Context, text string) ([]float32, error) {
 	return embedding, nil
 }
 
+// EmbedBatch generates an embedding per input with the given model, or the
+// configured embed model when model is empty. It also returns the number of
+// tokens processed.
+func (c *Client) EmbedBatch(ctx context.Context, model string, inputs []string) ([][]float32, int, error) {
+	if model == "" {
+		model = c.embedModel
+	}
+
+	resp, err := c.api.Embed(ctx, &api.EmbedRequest{Model: model, Input: inputs})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to generate embeddings: %w", err)
+	}
+	if len(resp.Embeddings) != len(inputs) {
+		return nil, 0, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(resp.Embeddings))
+	}
+
+	return resp.Embeddings, resp.PromptEvalCount, nil
+}
+
+// Model returns the active model
+func (c *Client) Model() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.model
+}
+
 // SetModel changes the active model
 func (c *Client) SetModel(model string) {
-	c.Model = model
+	c.mu.Lock()
+	c.model = model
+	c.mu.Unlock()
 }
 
-// Chat sends a conversation and returns the full response (non-streaming)
-func (c *Client) Chat(ctx context.Context, messages []api.Message) (string, error) {
-	var fullResponse strings.Builder
+// EmbedModel returns the model used for embeddings
+func (c *Client) EmbedModel() string {
+	return c.embedModel
+}
+
+// Pull downloads a model, reporting progress as it goes
+func (c *Client) Pull(ctx context.Context, model string, progress func(provider.PullProgress)) error {
+	err := c.api.Pull(ctx, &api.PullRequest{Model: model}, func(resp api.ProgressResponse) error {
+		if progress != nil {
+			progress(provider.PullProgress{
+				Status:    resp.Status,
+				Digest:    resp.Digest,
+				Total:     resp.Total,
+				Completed: resp.Completed,
+			})
+		}
+		return nil
+	})
+	c.forget(model)
+	if err == nil {
+		// The API ends a cancelled stream without an error
+		err = ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to pull model: %w", classify(err))
+	}
+	return nil
+}
+
+// Delete removes a downloaded model
+func (c *Client) Delete(ctx context.Context, model string) error {
+	c.forget(model)
+	if err := c.api.Delete(ctx, &api.DeleteRequest{Model: model}); err != nil {
+		return fmt.Errorf("failed to delete model: %w", classify(err))
+	}
+	return nil
+}
+
+// Show describes a downloaded model
+func (c *Client) Show(ctx context.Context, model string) (*provider.ModelInfo, error) {
+	resp, err := c.api.Show(ctx, &api.ShowRequest{Model: model})
+	if err != nil {
+		return nil, fmt.Errorf("failed to show model: %w", classify(err))
+	}
+
+	info := &provider.ModelInfo{
+		Name:          model,
+		Family:        resp.Details.Family,
+		ParameterSize: resp.Details.ParameterSize,
+		Quantization:  resp.Details.QuantizationLevel,
+		Parameters:    resp.Parameters,
+		License:       resp.License,
+		ModifiedAt:    resp.ModifiedAt,
+	}
+	for _, capability := range resp.Capabilities {
+		info.Capabilities = append(info.Capabilities, string(capability))
+	}
+	// Context length is keyed by architecture, e.g. "llama.context_length"
+	for key, value := range resp.ModelInfo {
+		if !strings.HasSuffix(key, ".context_length") {
+			continue
+		}
+		if n, ok := value.(float64); ok {
+			info.ContextLength = int(n)
+		}
+	}
+	return info, nil
+}
+
+// forget drops cached capabilities after a model changes on the server
+func (c *Client) forget(model string) {
+	c.capsMu.Lock()
+	delete(c.caps, model)
+	c.capsMu.Unlock()
+}
+
+// Chat sends a conversation and returns the reply, streaming it when
+// opts.OnContent is set and running any tools the model calls
+func (c *Client) Chat(ctx context.Context, messages []provider.Message, opts provider.ChatOptions) (string, error) {
+	model := opts.Model
+	if model == "" {
+		model = c.Model()
+	}
 
 	req := &api.ChatRequest{
-		Model:    c.Model,
-		Messages: messages,
-		Stream:   boolPtr(false),
+		Model:  model,
+		Stream: boolPtr(opts.OnContent != nil),
 	}
+	if opts.Temperature != nil {
+		req.Options = map[string]any{"temperature": *opts.Temperature}
+	}
+	if provider.OfferTools(ctx, c, model, opts.Tools) {
+		req.Tools = toTools(opts.Tools.Definitions())
+	}
+	req.Format = opts.Format
+	if think, ok := c.think(ctx, model); ok {
+		req.Think = &api.ThinkValue{Value: think}
+	}
+
+	return provider.ChatLoop(ctx, messages, opts, c.Retry, func(ctx context.Context, messages []provider.Message, onContent func(string)) (provider.Message, provider.Usage, error) {
+		req.Messages = toMessages(messages)
+		return c.chatOnce(ctx, req, onContent)
+	})
+}
+
+// chatOnce sends a single request and collects the reply with the metrics
+// Ollama sends at the end
+func (c *Client) chatOnce(ctx context.Context, req *api.ChatRequest, onContent func(string)) (provider.Message, provider.Usage, error) {
+	var reply provider.Message
+	var usage provider.Usage
+	var metrics api.Metrics
+	var content, thinking strings.Builder
 
+	start := time.Now()
 	err := c.api.Chat(ctx, req, func(resp api.ChatResponse) error {
-		fullResponse.WriteString(resp.Message.Content)
+		content.WriteString(resp.Message.Content)
+		thinking.WriteString(resp.Message.Thinking)
+		if onContent != nil && resp.Message.Content != "" {
+			if usage.FirstToken == 0 {
+				usage.FirstToken = time.Since(start)
+			}
+			onContent(resp.Message.Content)
+		}
+		for _, call := range resp.Message.ToolCalls {
+			reply.ToolCalls = append(reply.ToolCalls, provider.FunctionCall{
+				ID:        call.ID,
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments.ToMap(),
+			})
+		}
+		if resp.Done {
+			metrics = resp.Metrics
+		}
 		return nil
 	})
-
 	if err != nil {
-		return "", fmt.Errorf("chat failed: %w", err)
+		return reply, usage, fmt.Errorf("chat failed: %w", classify(err))
 	}
 
-	return fullResponse.String(), nil
+	usage.PromptTokens = metrics.PromptEvalCount
+	usage.CompletionTokens = metrics.EvalCount
+	usage.EvalDuration = metrics.EvalDuration
+	// Without streaming, the first token came once the model was loaded
+	// and had read the prompt
+	if usage.FirstToken == 0 {
+		usage.FirstToken = metrics.LoadDuration + metrics.PromptEvalDuration
+	}
+	reply.Content = content.String()
+	reply.Thinking = thinking.String()
+	return reply, usage, nil
+}
+
+// toMessages converts messages to Ollama's types
+func toMessages(msgs []provider.Message) []api.Message {
+	out := make([]api.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		m := api.Message{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			Thinking:   msg.Thinking,
+			ToolName:   msg.ToolName,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, image := range msg.Images {
+			m.Images = append(m.Images, api.ImageData(image))
+		}
+		for _, call := range msg.ToolCalls {
+			args := api.NewToolCallFunctionArguments()
+			keys := make([]string, 0, len(call.Arguments))
+			for k := range call.Arguments {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				args.Set(k, call.Arguments[k])
+			}
+			m.ToolCalls = append(m.ToolCalls, api.ToolCall{
+				ID:       call.ID,
+				Function: api.ToolCallFunction{Name: call.Name, Arguments: args},
+			})
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// toTools converts tool definitions to Ollama's types
+func toTools(defs []tools.Definition) api.Tools {
+	var out api.Tools
+	for _, def := range defs {
+		var params api.ToolFunctionParameters
+		if err := json.Unmarshal(def.Parameters, &params); err != nil {
+			continue
+		}
+		out = append(out, api.Tool{
+			Type:     "function",
+			Function: api.ToolFunction{Name: def.Name, Description: def.Description, Parameters: params},
+		})
+	}
+	return out
+}
+
+// think decides whether a model should think before answering. Models that
+// can't think are sent no setting, since Ollama rejects one for them.
+func (c *Client) think(ctx context.Context, model string) (bool, bool) {
+	if ok, err := c.HasCapability(ctx, model, provider.CapabilityThinking); err != nil || !ok {
+		return false, false
+	}
+	if think, ok := c.Think[model]; ok {
+		return think, true
+	}
+	base, _, _ := strings.Cut(model, ":")
+	if think, ok := c.Think[base]; ok {
+		return think, true
+	}
+	// Asking explicitly keeps the thinking out of the content
+	return true, true
+}
+
+// classify marks errors with the kind of failure behind them
+func classify(err error) error {
+	var status api.StatusError
+	var auth api.AuthorizationError
+	switch {
+	case errors.As(err, &status):
+		return provider.ClassifyStatus(status.StatusCode, status.ErrorMessage, err)
+	case errors.As(err, &auth):
+		return provider.Mark(provider.ErrUnauthorized, err)
+	}
+	return provider.ClassifyNetwork(err)
 }
 
 func boolPtr(b bool) *bool {
@@ -0,0 +1,506 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diiviikk5/dvkcli/internal/provider"
+	"github.com/diiviikk5/dvkcli/internal/tools"
+)
+
+// Client talks to an OpenAI-compatible API such as OpenAI, LM Studio,
+// llama.cpp or vLLM
+type Client struct {
+	baseURL    string
+	apiKey     string
+	embedModel string
+	http       *http.Client
+
+	// Capabilities of every model; nil when unknown
+	capabilities []string
+
+	// Retry controls how transient chat failures are retried
+	Retry provider.RetryPolicy
+
+	mu    sync.RWMutex
+	model string
+}
+
+// Client implements provider.Provider
+var _ provider.Provider = (*Client)(nil)
+
+// NewClient creates a client for the API at baseURL, for example
+// https://api.openai.com/v1. Capabilities lists what the models support,
+// since the API has no way to ask.
+func NewClient(baseURL, apiKey, model, embedModel string, capabilities []string) (*Client, error) {
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		return nil, fmt.Errorf("invalid base URL: %q", baseURL)
+	}
+	if model == "" {
+		return nil, errors.New("a model is required")
+	}
+
+	return &Client{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		apiKey:       apiKey,
+		model:        model,
+		embedModel:   embedModel,
+		capabilities: capabilities,
+		http:         &http.Client{Transport: newTransport()},
+	}, nil
+}
+
+// newTransport bounds connecting and waiting for a response to start, but
+// not reading it, so long streamed replies aren't cut off. Requests are
+// otherwise bounded by their context.
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = 10 * time.Second
+	// Local servers may load the model before they answer
+	transport.ResponseHeaderTimeout = 5 * time.Minute
+	transport.IdleConnTimeout = 90 * time.Second
+	return transport
+}
+
+// Name identifies the backend
+func (c *Client) Name() string {
+	return "openai"
+}
+
+// Model returns the active model
+func (c *Client) Model() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.model
+}
+
+// SetModel changes the active model
+func (c *Client) SetModel(model string) {
+	c.mu.Lock()
+	c.model = model
+	c.mu.Unlock()
+}
+
+// EmbedModel returns the model used for embeddings
+func (c *Client) EmbedModel() string {
+	return c.embedModel
+}
+
+// IsConnected checks if the API is reachable and accepts our key
+func (c *Client) IsConnected(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	_, err := c.ListModels(ctx)
+	return err == nil
+}
+
+// HasCapability reports whether the configured capabilities include one.
+// Every model is assumed to support the same things.
+func (c *Client) HasCapability(ctx context.Context, model, capability string) (bool, error) {
+	if c.capabilities == nil {
+		return false, provider.ErrUnknownCapability
+	}
+	return slices.Contains(c.capabilities, capability), nil
+}
+
+// ListModels returns the models the API offers
+func (c *Client) ListModels(ctx context.Context) ([]provider.Model, error) {
+	var resp struct {
+		Data []struct {
+			ID      string `json:"id"`
+			Created int64  `json:"created"`
+		} `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/models", nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+
+	models := make([]provider.Model, 0, len(resp.Data))
+	for _, m := range resp.Data {
+		model := provider.Model{Name: m.ID}
+		if m.Created > 0 {
+			model.ModifiedAt = time.Unix(m.Created, 0)
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// Embed generates embeddings for text
+func (c *Client) Embed(ctx context.Context, text string) ([]float32, error) {
+	vectors, _, err := c.EmbedBatch(ctx, "", []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// EmbedBatch generates an embedding per input with the given model, or the
+// configured embed model when model is empty. It also returns the number of
+// tokens processed.
+func (c *Client) EmbedBatch(ctx context.Context, model string, inputs []string) ([][]float32, int, error) {
+	if model == "" {
+		model = c.embedModel
+	}
+
+	body := map[string]any{"model": model, "input": inputs}
+	var resp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+		Usage struct {
+			PromptTokens int `json:"prompt_tokens"`
+		} `json:"usage"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/embeddings", body, &resp); err != nil {
+		return nil, 0, fmt.Errorf("failed to generate embeddings: %w", err)
+	}
+	if len(resp.Data) != len(inputs) {
+		return nil, 0, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(resp.Data))
+	}
+
+	sort.Slice(resp.Data, func(i, j int) bool { return resp.Data[i].Index < resp.Data[j].Index })
+	vectors := make([][]float32, len(resp.Data))
+	for i, d := range resp.Data {
+		vectors[i] = d.Embedding
+	}
+	return vectors, resp.Usage.PromptTokens, nil
+}
+
+// Chat sends a conversation and returns the reply, streaming it when
+// opts.OnContent is set and running any tools the model calls
+func (c *Client) Chat(ctx context.Context, messages []provider.Message, opts provider.ChatOptions) (string, error) {
+	model := opts.Model
+	if model == "" {
+		model = c.Model()
+	}
+
+	req := chatRequest{
+		Model:         model,
+		Stream:        true,
+		StreamOptions: &streamOptions{IncludeUsage: true},
+		Temperature:   opts.Temperature,
+	}
+	if provider.OfferTools(ctx, c, model, opts.Tools) {
+		req.Tools = toTools(opts.Tools.Definitions())
+	}
+	req.ResponseFormat = responseFormat(opts.Format)
+
+	return provider.ChatLoop(ctx, messages, opts, c.Retry, func(ctx context.Context, messages []provider.Message, onContent func(string)) (provider.Message, provider.Usage, error) {
+		req.Messages = toMessages(messages)
+		return c.chatOnce(ctx, req, onContent)
+	})
+}
+
+// chatRequest is the body of POST /chat/completions
+type chatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []message      `json:"messages"`
+	Stream        bool           `json:"stream"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+	Temperature   *float64       `json:"temperature,omitempty"`
+	Tools         []tool         `json:"tools,omitempty"`
+
+	ResponseFormat *responseFormatJSON `json:"response_format,omitempty"`
+}
+
+// tool is a function offered to the model
+type tool struct {
+	Type     string `json:"type"` // always "function"
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description,omitempty"`
+		Parameters  json.RawMessage `json:"parameters"`
+	} `json:"function"`
+}
+
+// toTools converts tool definitions to the OpenAI format
+func toTools(defs []tools.Definition) []tool {
+	out := make([]tool, 0, len(defs))
+	for _, def := range defs {
+		t := tool{Type: "function"}
+		t.Function.Name = def.Name
+		t.Function.Description = def.Description
+		t.Function.Parameters = def.Parameters
+		out = append(out, t)
+	}
+	return out
+}
+
+// responseFormatJSON asks for JSON output, matching a schema when one is set
+type responseFormatJSON struct {
+	Type       string      `json:"type"` // "json_object" or "json_schema"
+	JSONSchema *jsonSchema `json:"json_schema,omitempty"`
+}
+
+// jsonSchema names the schema a response must match
+type jsonSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+// responseFormat translates a provider.ChatOptions format
+func responseFormat(format json.RawMessage) *responseFormatJSON {
+	switch {
+	case format == nil:
+		return nil
+	case bytes.Equal(bytes.TrimSpace(format), provider.FormatJSON):
+		return &responseFormatJSON{Type: "json_object"}
+	}
+	return &responseFormatJSON{Type: "json_schema", JSONSchema: &jsonSchema{Name: "response", Schema: format}}
+}
+
+// streamOptions asks for token counts in a final streamed chunk
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// usage is the token count of a completion
+type usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// message is a chat message in the OpenAI format. Content is a string, a
+// list of parts, or null for assistant messages that only call tools.
+type message struct {
+	Role       string     `json:"role"`
+	Content    any        `json:"content"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// toolCall is a function call made by the model. Arguments is JSON text,
+// which arrives in fragments when streamed.
+type toolCall struct {
+	Index    int    `json:"index,omitempty"` // only in streamed replies
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// chunk is one streamed piece of a reply
+type chunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string     `json:"content"`
+			ReasoningContent string     `json:"reasoning_content"` // sent by reasoning models on some servers
+			ToolCalls        []toolCall `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *usage    `json:"usage"`
+	Error *apiError `json:"error"`
+}
+
+// chatOnce sends a single request and collects the streamed reply. The API
+// reports no timings, so they are measured here.
+func (c *Client) chatOnce(ctx context.Context, req chatRequest, onContent func(string)) (provider.Message, provider.Usage, error) {
+	var reply provider.Message
+	var used provider.Usage
+
+	start := time.Now()
+	var first time.Time
+	resp, err := c.send(ctx, http.MethodPost, "/chat/completions", req)
+	if err != nil {
+		return reply, used, fmt.Errorf("chat failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var content, thinking strings.Builder
+	var calls []toolCall
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var ch chunk
+		if err := json.Unmarshal([]byte(data), &ch); err != nil {
+			return reply, used, fmt.Errorf("chat failed: invalid stream data: %w", err)
+		}
+		if ch.Usage != nil {
+			used.PromptTokens = ch.Usage.PromptTokens
+			used.CompletionTokens = ch.Usage.CompletionTokens
+		}
+		if ch.Error != nil {
+			return reply, used, fmt.Errorf("chat failed: %s", ch.Error.Message)
+		}
+		if len(ch.Choices) == 0 {
+			continue
+		}
+
+		delta := ch.Choices[0].Delta
+		if first.IsZero() && (delta.Content != "" || len(delta.ToolCalls) > 0) {
+			first = time.Now()
+		}
+		content.WriteString(delta.Content)
+		thinking.WriteString(delta.ReasoningContent)
+		if onContent != nil && delta.Content != "" {
+			onContent(delta.Content)
+		}
+		calls = mergeToolCalls(calls, delta.ToolCalls)
+	}
+	if err := scanner.Err(); err != nil {
+		return reply, used, fmt.Errorf("chat failed: %w", err)
+	}
+
+	if !first.IsZero() {
+		used.FirstToken = first.Sub(start)
+		used.EvalDuration = time.Since(first)
+	}
+	reply.Content = content.String()
+	reply.Thinking = thinking.String()
+	for _, call := range calls {
+		var args map[string]any
+		if strings.TrimSpace(call.Function.Arguments) != "" {
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+				return reply, used, fmt.Errorf("chat failed: invalid arguments for %s: %w", call.Function.Name, err)
+			}
+		}
+		reply.ToolCalls = append(reply.ToolCalls, provider.FunctionCall{ID: call.ID, Name: call.Function.Name, Arguments: args})
+	}
+	return reply, used, nil
+}
+
+// mergeToolCalls adds streamed tool call fragments to the calls so far.
+// Fragments of the same call share an index.
+func mergeToolCalls(calls, fragments []toolCall) []toolCall {
+	for _, f := range fragments {
+		i := slices.IndexFunc(calls, func(c toolCall) bool { return c.Index == f.Index })
+		if i < 0 {
+			calls = append(calls, f)
+			continue
+		}
+		if f.ID != "" {
+			calls[i].ID = f.ID
+		}
+		calls[i].Function.Name += f.Function.Name
+		calls[i].Function.Arguments += f.Function.Arguments
+	}
+	return calls
+}
+
+// toMessages converts messages to the OpenAI format
+func toMessages(msgs []provider.Message) []message {
+	out := make([]message, 0, len(msgs))
+	for _, msg := range msgs {
+		m := message{Role: msg.Role, Content: msg.Content, ToolCallID: msg.ToolCallID}
+
+		if len(msg.Images) > 0 {
+			parts := []map[string]any{}
+			if msg.Content != "" {
+				parts = append(parts, map[string]any{"type": "text", "text": msg.Content})
+			}
+			for _, img := range msg.Images {
+				url := "data:" + http.DetectContentType(img) + ";base64," + base64.StdEncoding.EncodeToString(img)
+				parts = append(parts, map[string]any{"type": "image_url", "image_url": map[string]string{"url": url}})
+			}
+			m.Content = parts
+		}
+
+		for _, call := range msg.ToolCalls {
+			tc := toolCall{ID: call.ID, Type: "function"}
+			tc.Function.Name = call.Name
+			tc.Function.Arguments = "{}"
+			if args, err := json.Marshal(call.Arguments); err == nil && call.Arguments != nil {
+				tc.Function.Arguments = string(args)
+			}
+			m.ToolCalls = append(m.ToolCalls, tc)
+		}
+		if len(m.ToolCalls) > 0 && msg.Content == "" {
+			m.Content = nil
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// apiError is the error body returned by OpenAI-compatible APIs
+type apiError struct {
+	Message string `json:"message"`
+}
+
+// do sends a request and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	return nil
+}
+
+// send makes a request, turning error statuses into errors
+func (c *Client) send(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, provider.ClassifyNetwork(err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+		var body struct {
+			Error apiError `json:"error"`
+		}
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &body) == nil && body.Error.Message != "" {
+			message = body.Error.Message
+		}
+		return nil, provider.ClassifyStatus(resp.StatusCode, message, fmt.Errorf("%s (status %d)", message, resp.StatusCode))
+	}
+	return resp, nil
+}
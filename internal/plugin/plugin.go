@@ -0,0 +1,245 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/diiviikk5/dvkcli/internal/config"
+)
+
+// Prefix starts the names of executables found as plugins: dvkcli-jira is
+// the plugin jira
+const Prefix = "dvkcli-"
+
+// Version is the version of the payload plugins receive
+const Version = 1
+
+// DefaultTimeout bounds how long a plugin may run
+const DefaultTimeout = 60 * time.Second
+
+// Plugin is an external program run as a command. It reads a Request as
+// JSON on stdin and writes a Response as JSON to stdout.
+type Plugin struct {
+	Name        string
+	Command     string
+	Args        []string // passed before the arguments given to the command
+	Env         map[string]string
+	Description string
+	Timeout     time.Duration
+}
+
+// Message is a chat message in a Request or Response
+type Message struct {
+	Role    string `json:"role"` // "user", "assistant", or "notice" for text shown but not kept
+	Content string `json:"content"`
+	Model   string `json:"model,omitempty"`
+}
+
+// Conversation is the conversation a plugin was run in
+type Conversation struct {
+	ID       string    `json:"id"`
+	Title    string    `json:"title,omitempty"`
+	Messages []Message `json:"messages"`
+}
+
+// Request is the payload a plugin receives on stdin
+type Request struct {
+	Version      int           `json:"version"`
+	Plugin       string        `json:"plugin"`
+	Source       string        `json:"source"` // "tui" or "cli"
+	Args         []string      `json:"args"`
+	Text         string        `json:"text"` // the arguments as typed
+	Dir          string        `json:"dir"`
+	Backend      string        `json:"backend,omitempty"`
+	Model        string        `json:"model,omitempty"`
+	Conversation *Conversation `json:"conversation,omitempty"`
+	Selection    string        `json:"selection,omitempty"` // the selected message, or stdin on the CLI
+	Config       *Config       `json:"config,omitempty"`
+}
+
+// Config is the part of dvkcli's configuration plugins receive. It never
+// holds secrets such as the environment given to MCP servers and plugins.
+type Config struct {
+	Model        string            `json:"model"`
+	EmbedModel   string            `json:"embed_model,omitempty"`
+	Backend      string            `json:"backend,omitempty"`
+	Backends     []string          `json:"backends,omitempty"`
+	SystemPrompt string            `json:"system_prompt,omitempty"`
+	Personas     map[string]string `json:"personas,omitempty"`
+	Theme        string            `json:"theme,omitempty"`
+}
+
+// NewConfig picks the settings plugins receive out of cfg
+func NewConfig(cfg *config.Config) *Config {
+	c := &Config{
+		Model:        cfg.Model,
+		EmbedModel:   cfg.EmbedModel,
+		Backend:      cfg.Backend,
+		SystemPrompt: cfg.SystemPrompt,
+		Personas:     cfg.Personas,
+		Theme:        cfg.Theme,
+	}
+	for _, b := range cfg.Backends {
+		c.Backends = append(c.Backends, b.Name)
+	}
+	return c
+}
+
+// Response is what a plugin writes to stdout. Every field is optional.
+type Response struct {
+	// Messages are appended to the conversation
+	Messages []Message `json:"messages,omitempty"`
+	// Draft replaces the text in the input
+	Draft *string `json:"draft,omitempty"`
+	// Prompt is sent to the model as a user message, with Model if set
+	Prompt string `json:"prompt,omitempty"`
+	Model  string `json:"model,omitempty"`
+	// Error is shown instead of anything else
+	Error string `json:"error,omitempty"`
+}
+
+// Discover finds the plugins on a PATH-style list of directories. Like a
+// shell, the first executable of a name wins.
+func Discover(path string) []Plugin {
+	seen := make(map[string]bool)
+	var plugins []Plugin
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := pluginName(entry.Name())
+			if !ok || seen[name] || !isExecutable(filepath.Join(dir, entry.Name())) {
+				continue
+			}
+			seen[name] = true
+			plugins = append(plugins, Plugin{Name: name, Command: filepath.Join(dir, entry.Name())})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// pluginName returns the plugin name of an executable file name
+func pluginName(file string) (string, bool) {
+	name, ok := strings.CutPrefix(file, Prefix)
+	if !ok {
+		return "", false
+	}
+	if runtime.GOOS == "windows" {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	if name == "" || strings.ContainsAny(name, " \t.") {
+		return "", false
+	}
+	return strings.ToLower(name), true
+}
+
+// isExecutable reports whether path is a regular file that may be run
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(filepath.Ext(path), ".exe")
+	}
+	return info.Mode().Perm()&0111 != 0
+}
+
+// Find returns the plugin called name
+func Find(plugins []Plugin, name string) (Plugin, bool) {
+	for _, p := range plugins {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Plugin{}, false
+}
+
+// Run runs the plugin with req on stdin and reads its response. Output that
+// isn't JSON is taken as a notice to show. A plugin that fails is reported
+// with the end of its stderr.
+func (p Plugin) Run(ctx context.Context, req Request) (Response, error) {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req.Version = Version
+	req.Plugin = p.Name
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to encode plugin request: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, p.Command, append(append([]string{}, p.Args...), req.Args...)...)
+	cmd.Dir = req.Dir
+	cmd.Env = os.Environ()
+	for k, v := range p.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	// Don't wait forever for background children holding the pipes open
+	cmd.WaitDelay = time.Second
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return Response{}, fmt.Errorf("plugin %s timed out after %s", p.Name, timeout)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return Response{}, fmt.Errorf("plugin %s exited with code %d%s", p.Name, exitErr.ExitCode(), stderrTail(stderr.String()))
+		}
+		return Response{}, fmt.Errorf("failed to run plugin %s: %w", p.Name, err)
+	}
+	return parseResponse(stdout.Bytes())
+}
+
+// parseResponse reads a plugin's output
+func parseResponse(out []byte) (Response, error) {
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return Response{}, nil
+	}
+	if out[0] != '{' {
+		return Response{Messages: []Message{{Role: "notice", Content: string(out)}}}, nil
+	}
+	var resp Response
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return Response{}, fmt.Errorf("invalid plugin response: %w", err)
+	}
+	return resp, nil
+}
+
+// stderrTail formats the last lines of a plugin's stderr for an error
+func stderrTail(stderr string) string {
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return ""
+	}
+	if len(lines) > 5 {
+		lines = lines[len(lines)-5:]
+	}
+	return ":\n" + strings.Join(lines, "\n")
+}
@@ -0,0 +1,299 @@
+package prompts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/diiviikk5/dvkcli/internal/attach"
+)
+
+// Ext is the extension of template files
+const Ext = ".md"
+
+// ErrNotFound is returned for template names that match nothing
+var ErrNotFound = errors.New("no such template")
+
+// Template is a reusable prompt stored as a markdown file. Optional front
+// matter between "---" lines chooses how it is answered.
+type Template struct {
+	Name        string
+	Path        string
+	Description string
+	Model       string
+	Temperature *float64
+	Persona     string
+	Body        string
+}
+
+// placeholder matches {{name}}, {{name|default}}, {{input}} and
+// {{file:path}}
+var placeholder = regexp.MustCompile(`\{\{\s*(.+?)\s*\}\}`)
+
+// variableName matches the names variables may have
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// Load reads the templates in dirs, sorted by name. Templates in later dirs
+// replace those of the same name in earlier ones; missing dirs are skipped.
+func Load(dirs ...string) ([]Template, error) {
+	byName := make(map[string]Template)
+	for _, dir := range dirs {
+		paths, err := filepath.Glob(filepath.Join(dir, "*"+Ext))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list templates: %w", err)
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read template: %w", err)
+			}
+			t, err := Parse(strings.TrimSuffix(filepath.Base(path), Ext), string(data))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			t.Path = path
+			byName[t.Name] = t
+		}
+	}
+
+	templates := make([]Template, 0, len(byName))
+	for _, t := range byName {
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+// Parse reads a template from the contents of its file
+func Parse(name, data string) (Template, error) {
+	t := Template{Name: name, Body: data}
+
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	rest, ok := strings.CutPrefix(data, "---\n")
+	if !ok {
+		return t, nil
+	}
+	header, body, ok := strings.Cut(rest, "\n---\n")
+	if !ok {
+		header, ok = strings.CutSuffix(rest, "\n---")
+		if !ok {
+			return t, fmt.Errorf("front matter is not closed with ---")
+		}
+	}
+	t.Body = strings.TrimLeft(body, "\n")
+
+	for _, line := range strings.Split(header, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return t, fmt.Errorf("invalid front matter line %q", line)
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		switch strings.TrimSpace(key) {
+		case "description":
+			t.Description = value
+		case "model":
+			t.Model = value
+		case "persona":
+			t.Persona = value
+		case "temperature":
+			temp, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return t, fmt.Errorf("invalid temperature %q", value)
+			}
+			t.Temperature = &temp
+		}
+	}
+	return t, nil
+}
+
+// Find returns the template called name, or the only one whose name starts
+// with it
+func Find(templates []Template, name string) (Template, error) {
+	var matches []Template
+	for _, t := range templates {
+		if t.Name == name {
+			return t, nil
+		}
+		if strings.HasPrefix(t.Name, name) {
+			matches = append(matches, t)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Template{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	case 1:
+		return matches[0], nil
+	}
+	names := make([]string, len(matches))
+	for i, t := range matches {
+		names[i] = t.Name
+	}
+	return Template{}, fmt.Errorf("%q matches several templates: %s", name, strings.Join(names, ", "))
+}
+
+// Complete returns the names of the templates starting with prefix
+func Complete(templates []Template, prefix string) []string {
+	var names []string
+	for _, t := range templates {
+		if strings.HasPrefix(t.Name, prefix) {
+			names = append(names, t.Name)
+		}
+	}
+	return names
+}
+
+// Variables lists the variables the template uses, in order of first use
+func (t Template) Variables() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range placeholder.FindAllStringSubmatch(t.Body, -1) {
+		name, _, _ := strings.Cut(m[1], "|")
+		name = strings.TrimSpace(name)
+		if name == "input" || strings.HasPrefix(name, "file:") || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// UsesInput reports whether the template has an {{input}} placeholder
+func (t Template) UsesInput() bool {
+	for _, m := range placeholder.FindAllStringSubmatch(t.Body, -1) {
+		if m[1] == "input" {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseArgs splits template arguments into the leading name=value pairs and
+// the text after them
+func ParseArgs(args []string) (map[string]string, string) {
+	vars := make(map[string]string)
+	for i, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || !variableName.MatchString(name) {
+			return vars, strings.Join(args[i:], " ")
+		}
+		vars[name] = value
+	}
+	return vars, ""
+}
+
+// ParseText is ParseArgs for arguments typed as one line: only the leading
+// name=value words are split off, so the text after them keeps its spacing
+// and newlines
+func ParseText(text string) (map[string]string, string) {
+	vars := make(map[string]string)
+	for {
+		text = strings.TrimLeft(text, " \t\r\n")
+		end := strings.IndexAny(text, " \t\r\n")
+		if end < 0 {
+			end = len(text)
+		}
+		name, value, ok := strings.Cut(text[:end], "=")
+		if !ok || !variableName.MatchString(name) {
+			return vars, text
+		}
+		vars[name] = value
+		text = text[end:]
+	}
+}
+
+// Render fills in the template's placeholders: variables from vars, falling
+// back to their defaults, {{input}} with input, and {{file:path}} with the
+// files loader finds. Input is appended when the template has no {{input}}.
+// A template stored under the loader's root came with the project, so its
+// {{file:path}} placeholders may only read files inside the project.
+func (t Template) Render(vars map[string]string, input string, loader *attach.Loader) (string, error) {
+	if t.Path != "" && loader != nil && !loader.Confined {
+		if rel, err := filepath.Rel(loader.Root, t.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			confined := *loader
+			confined.Confined = true
+			loader = &confined
+		}
+	}
+
+	var missing []string
+	var failed error
+	body := placeholder.ReplaceAllStringFunc(t.Body, func(match string) string {
+		inner := placeholder.FindStringSubmatch(match)[1]
+		if pattern, ok := strings.CutPrefix(inner, "file:"); ok {
+			content, err := loadFiles(loader, strings.TrimSpace(pattern))
+			if err != nil && failed == nil {
+				failed = err
+			}
+			return content
+		}
+		if inner == "input" {
+			if input == "" {
+				missing = append(missing, "input")
+			}
+			return input
+		}
+
+		name, def, hasDefault := strings.Cut(inner, "|")
+		name = strings.TrimSpace(name)
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		if hasDefault {
+			return strings.TrimSpace(def)
+		}
+		missing = append(missing, name)
+		return match
+	})
+	if failed != nil {
+		return "", failed
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("template %s needs %s", t.Name, strings.Join(dedupe(missing), ", "))
+	}
+
+	body = strings.TrimSpace(body)
+	if input != "" && !t.UsesInput() {
+		body += "\n\n" + input
+	}
+	return body, nil
+}
+
+// loadFiles renders the files matching pattern as fenced blocks. Hitting the
+// loader's limits keeps the files read so far.
+func loadFiles(loader *attach.Loader, pattern string) (string, error) {
+	atts, err := loader.Load(pattern)
+	if err != nil && len(atts) == 0 {
+		return "", err
+	}
+	blocks := make([]string, 0, len(atts))
+	for _, att := range atts {
+		if att.Kind == attach.KindImage {
+			return "", fmt.Errorf("%s is an image; attach it with /image instead", att.Path)
+		}
+		blocks = append(blocks, att.Format())
+	}
+	return strings.Join(blocks, "\n\n"), nil
+}
+
+// dedupe removes repeated names, keeping the first of each
+func dedupe(names []string) []string {
+	seen := make(map[string]bool)
+	out := names[:0]
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	return out
+}
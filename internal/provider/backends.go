@@ -0,0 +1,79 @@
+package provider
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Backends holds named providers in the configured order, one of which is
+// active
+type Backends struct {
+	mu        sync.RWMutex
+	names     []string
+	providers map[string]Provider
+	active    string
+	fallback  []Route
+}
+
+// NewBackends creates an empty set of backends
+func NewBackends() *Backends {
+	return &Backends{providers: make(map[string]Provider)}
+}
+
+// Add registers a backend. The first one added becomes active.
+func (b *Backends) Add(name string, p Provider) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.providers[name]; ok {
+		return fmt.Errorf("duplicate backend %q", name)
+	}
+	b.names = append(b.names, name)
+	b.providers[name] = p
+	if b.active == "" {
+		b.active = name
+	}
+	return nil
+}
+
+// SetFallback sets the routes chat requests fall back to, in order
+func (b *Backends) SetFallback(routes []Route) {
+	b.mu.Lock()
+	b.fallback = routes
+	b.mu.Unlock()
+}
+
+// Get returns the backend with the given name
+func (b *Backends) Get(name string) (Provider, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	p, ok := b.providers[name]
+	return p, ok
+}
+
+// Names returns the backend names in the configured order
+func (b *Backends) Names() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]string(nil), b.names...)
+}
+
+// Active returns the name and provider of the active backend
+func (b *Backends) Active() (string, Provider) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.active, b.providers[b.active]
+}
+
+// Use makes the named backend active
+func (b *Backends) Use(name string) (Provider, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, ok := b.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q", name)
+	}
+	b.active = name
+	return p, nil
+}
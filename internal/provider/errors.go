@@ -0,0 +1,109 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+// Kinds of failure that providers mark their errors with, for errors.Is
+var (
+	// ErrUnreachable means the backend could not be contacted, such as a
+	// refused connection or an unknown host
+	ErrUnreachable = errors.New("backend unreachable")
+	// ErrModelNotFound means the backend does not have the model
+	ErrModelNotFound = errors.New("model not found")
+	// ErrContextOverflow means the conversation is too long for the model
+	ErrContextOverflow = errors.New("context window exceeded")
+	// ErrUnauthorized means the backend rejected the API key
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrTimeout means the backend took too long to answer
+	ErrTimeout = errors.New("timed out")
+	// ErrUnavailable means the backend is overloaded or failing and may
+	// recover shortly
+	ErrUnavailable = errors.New("backend unavailable")
+)
+
+// kindError marks an error as one of the kinds above without changing its
+// message
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.kind, e.err} }
+
+// Mark tags err with a kind such as ErrUnreachable
+func Mark(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// IsTransient reports whether retrying the same request may succeed
+func IsTransient(err error) bool {
+	return errors.Is(err, ErrTimeout) || errors.Is(err, ErrUnavailable)
+}
+
+// IsDialError reports whether err comes from failing to connect, as
+// opposed to a failure once connected
+func IsDialError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+// ClassifyNetwork marks errors from sending a request. Cancellation by the
+// caller is left alone.
+func ClassifyNetwork(err error) error {
+	var netErr net.Error
+	switch {
+	case err == nil || errors.Is(err, context.Canceled):
+		return err
+	case IsDialError(err):
+		return Mark(ErrUnreachable, err)
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return Mark(ErrTimeout, err)
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return Mark(ErrUnavailable, err)
+	}
+	return err
+}
+
+// ClassifyStatus marks an error response by its HTTP status and message
+func ClassifyStatus(status int, message string, err error) error {
+	lower := strings.ToLower(message)
+	switch {
+	case strings.Contains(lower, "context length"), strings.Contains(lower, "context window"),
+		strings.Contains(lower, "maximum context"), strings.Contains(lower, "context_length_exceeded"):
+		return Mark(ErrContextOverflow, err)
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return Mark(ErrUnauthorized, err)
+	case status == http.StatusNotFound && isModelNotFound(lower):
+		return Mark(ErrModelNotFound, err)
+	case status == http.StatusRequestTimeout, status == http.StatusGatewayTimeout:
+		return Mark(ErrTimeout, err)
+	case status == http.StatusTooManyRequests, status >= http.StatusInternalServerError:
+		return Mark(ErrUnavailable, err)
+	}
+	return err
+}
+
+// isModelNotFound reports whether a lowercased 404 message blames the model,
+// rather than a wrong URL
+func isModelNotFound(message string) bool {
+	if strings.Contains(message, "model_not_found") {
+		return true
+	}
+	return strings.Contains(message, "model") &&
+		(strings.Contains(message, "not found") || strings.Contains(message, "does not exist"))
+}
@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Route is a step of a failover chain: a backend and the model to use
+// there, or its active model when Model is empty
+type Route struct {
+	Backend string
+	Model   string
+}
+
+// Fallback reports that a backend failed and the next route is being tried
+type Fallback struct {
+	From Route
+	To   Route
+	Err  error
+}
+
+// Failover is a Provider that chats through the first route of a chain
+// that works. Requests fall through to the next route when a backend is
+// unreachable or lacks the model, unless the reply has already started.
+// Everything but chat goes to the first backend.
+type Failover struct {
+	Provider
+	backend string
+	routes  []Route
+	lookup  func(string) (Provider, bool)
+}
+
+// Chain returns a provider for the named backend that falls back along the
+// configured routes. Without fallbacks it is the backend's own provider.
+func (b *Backends) Chain(name string) Provider {
+	b.mu.RLock()
+	p, ok := b.providers[name]
+	routes := b.fallback
+	b.mu.RUnlock()
+
+	if !ok || len(routes) == 0 {
+		return p
+	}
+	return &Failover{Provider: p, backend: name, routes: routes, lookup: b.Get}
+}
+
+// Chat tries each route in turn, telling opts.OnFallback which one takes
+// over
+func (f *Failover) Chat(ctx context.Context, messages []Message, opts ChatOptions) (string, error) {
+	current := Route{Backend: f.backend, Model: opts.Model}
+	if current.Model == "" {
+		current.Model = f.Provider.Model()
+	}
+
+	// Once output or tool calls have reached the caller, a retry elsewhere
+	// would repeat them
+	started := false
+	wrapped := opts
+	if opts.OnContent != nil {
+		wrapped.OnContent = func(s string) {
+			started = true
+			opts.OnContent(s)
+		}
+	}
+	wrapped.OnToolCall = func(call ToolCall) {
+		started = true
+		if opts.OnToolCall != nil {
+			opts.OnToolCall(call)
+		}
+	}
+
+	p := f.Provider
+	remaining := f.routes
+	for {
+		wrapped.Model = current.Model
+		reply, err := p.Chat(ctx, messages, wrapped)
+		if err == nil || started || !canFailOver(err) {
+			return reply, err
+		}
+
+		next, nextProvider, rest, ok := f.next(current, remaining)
+		if !ok {
+			return "", err
+		}
+		if opts.OnFallback != nil {
+			opts.OnFallback(Fallback{From: current, To: next, Err: err})
+		}
+		current, p, remaining = next, nextProvider, rest
+	}
+}
+
+// next finds the first remaining route that names a known backend and
+// differs from the one that failed
+func (f *Failover) next(failed Route, routes []Route) (Route, Provider, []Route, bool) {
+	for i, r := range routes {
+		p, ok := f.lookup(r.Backend)
+		if !ok {
+			continue
+		}
+		if r.Model == "" {
+			r.Model = p.Model()
+		}
+		if r == failed {
+			continue
+		}
+		return r, p, routes[i+1:], true
+	}
+	return Route{}, nil, nil, false
+}
+
+// canFailOver reports whether another backend might succeed where err
+// happened
+func canFailOver(err error) bool {
+	return errors.Is(err, ErrUnreachable) || errors.Is(err, ErrModelNotFound)
+}
+
+// String describes a route as "backend (model)"
+func (r Route) String() string {
+	return fmt.Sprintf("%s (%s)", r.Backend, r.Model)
+}
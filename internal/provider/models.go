@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"context"
+	"time"
+)
+
+// ModelManager is implemented by backends that can download and remove
+// models, such as Ollama
+type ModelManager interface {
+	// Pull downloads a model, reporting progress as it goes
+	Pull(ctx context.Context, model string, progress func(PullProgress)) error
+	// Delete removes a downloaded model
+	Delete(ctx context.Context, model string) error
+	// Show describes a downloaded model
+	Show(ctx context.Context, model string) (*ModelInfo, error)
+}
+
+// ProcessLister is implemented by backends that can report which models are
+// loaded in memory, such as Ollama
+type ProcessLister interface {
+	// ListRunning returns the loaded models
+	ListRunning(ctx context.Context) ([]RunningModel, error)
+}
+
+// RunningModel is a model loaded in memory
+type RunningModel struct {
+	Name          string
+	Size          int64 // bytes in memory
+	SizeVRAM      int64 // bytes of Size held in GPU memory
+	ContextLength int
+	ExpiresAt     time.Time // when the model is unloaded unless used again
+}
+
+// PullProgress is one step of a model download. Total and Completed are in
+// bytes and only set while a layer is downloading.
+type PullProgress struct {
+	Status    string
+	Digest    string
+	Total     int64
+	Completed int64
+}
+
+// ModelInfo describes a model
+type ModelInfo struct {
+	Name          string
+	Family        string
+	ParameterSize string
+	Quantization  string
+	ContextLength int // 0 when unknown
+	Capabilities  []string
+	Parameters    string
+	License       string
+	ModifiedAt    time.Time
+}
+
+// HasModel reports whether name is among models
+func HasModel(models []Model, name string) bool {
+	for _, m := range models {
+		if SameModel(m.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// SameModel reports whether two model names refer to the same model. A name
+// without a tag means the "latest" tag.
+func SameModel(a, b string) bool {
+	return a == b || a == b+":latest" || a+":latest" == b
+}
@@ -0,0 +1,239 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/diiviikk5/dvkcli/internal/tools"
+)
+
+// Provider is an LLM backend. Implementations translate Messages to their
+// own API.
+type Provider interface {
+	// Name identifies the kind of backend, such as "ollama" or "openai"
+	Name() string
+
+	// Chat sends a conversation and returns the reply, streaming it to
+	// opts.OnContent when set and running any tools the model calls
+	Chat(ctx context.Context, messages []Message, opts ChatOptions) (string, error)
+	// Embed generates an embedding for text with the embed model
+	Embed(ctx context.Context, text string) ([]float32, error)
+	// EmbedBatch generates an embedding per input with the given model, or
+	// the embed model when model is empty, and the number of tokens used
+	EmbedBatch(ctx context.Context, model string, inputs []string) ([][]float32, int, error)
+
+	// ListModels returns the models the backend can serve
+	ListModels(ctx context.Context) ([]Model, error)
+	// IsConnected reports whether the backend is reachable
+	IsConnected(ctx context.Context) bool
+	// HasCapability reports whether a model supports a capability such as
+	// CapabilityVision. It returns ErrUnknownCapability when the backend
+	// cannot tell.
+	HasCapability(ctx context.Context, model, capability string) (bool, error)
+
+	// Model is the model used when ChatOptions.Model is empty
+	Model() string
+	// SetModel changes the active model
+	SetModel(model string)
+	// EmbedModel is the model used by Embed
+	EmbedModel() string
+}
+
+// Model capabilities
+const (
+	CapabilityVision   = "vision"
+	CapabilityTools    = "tools"
+	CapabilityThinking = "thinking"
+)
+
+// ErrUnknownCapability is returned when a backend cannot report what a
+// model supports
+var ErrUnknownCapability = errors.New("capabilities unknown")
+
+// Model describes a model offered by a backend
+type Model struct {
+	Name       string
+	Size       int64 // bytes on disk, 0 when unknown
+	ModifiedAt time.Time
+}
+
+// FormatJSON asks for a reply that is any JSON
+var FormatJSON = json.RawMessage(`"json"`)
+
+// MaxToolRounds limits how many rounds of tool calls one reply may make
+const MaxToolRounds = 10
+
+// ChatOptions overrides request settings for a single chat call
+type ChatOptions struct {
+	Model       string   // Defaults to the provider's active model
+	Temperature *float64 // Nil keeps the model's default
+
+	// Format asks for a JSON reply matching a JSON schema, or any JSON when
+	// it is FormatJSON. Nil allows free text.
+	Format json.RawMessage
+
+	// Tools are offered to models that support tool calling. Calls are run
+	// and their results fed back until the model answers in text.
+	Tools *tools.Registry
+	// OnToolCall is told about each tool call after it has run
+	OnToolCall func(ToolCall)
+	// OnContent, when set, streams the reply as it is generated
+	OnContent func(string)
+	// OnFallback is told when a failover chain moves on to another backend
+	OnFallback func(Fallback)
+	// OnUsage is told the tokens and time a reply took once it succeeds
+	OnUsage func(Usage)
+	// OnThinking is told the model's reasoning, kept apart from the reply,
+	// after each round trip that had some
+	OnThinking func(string)
+}
+
+// Message is a message of a conversation sent to a backend
+type Message struct {
+	Role      string // "system", "user", "assistant" or "tool"
+	Content   string
+	Thinking  string // the model's reasoning, kept apart from the reply
+	Images    [][]byte
+	ToolCalls []FunctionCall // tools an assistant message called
+
+	// A tool message reports the result of the call with ToolCallID
+	ToolName   string
+	ToolCallID string
+}
+
+// FunctionCall is a call to a tool made by the model
+type FunctionCall struct {
+	ID        string // empty for backends that don't match results by ID
+	Name      string
+	Arguments map[string]any
+}
+
+// ToolCall records a tool the model called while answering
+type ToolCall struct {
+	ID        string // set by backends that match results to calls by ID
+	Name      string
+	Arguments map[string]any
+	Result    string
+	Err       error
+	Duration  time.Duration
+}
+
+// Send performs one round trip with the backend, streaming content to
+// onContent when it is set, and returns the model's message with any tool
+// calls it made, and what the round trip used
+type Send func(ctx context.Context, messages []Message, onContent func(string)) (Message, Usage, error)
+
+// ChatLoop calls send until the model answers without calling tools,
+// running the tools it asks for in between. Each round trip is retried
+// under retry until some of its reply has been streamed.
+func ChatLoop(ctx context.Context, messages []Message, opts ChatOptions, retry RetryPolicy, send Send) (string, error) {
+	// Copy so tool results never leak into the caller's slice
+	messages = append([]Message(nil), messages...)
+
+	start := time.Now()
+	var total Usage
+	for round := 0; ; round++ {
+		var reply Message
+		var usage Usage
+		roundStart := time.Now()
+		streamed := false
+		var onContent func(string)
+		if opts.OnContent != nil {
+			onContent = func(content string) {
+				streamed = true
+				opts.OnContent(content)
+			}
+		}
+
+		err := retry.Do(ctx, func() (bool, error) {
+			var err error
+			reply, usage, err = send(ctx, messages, onContent)
+			return !streamed, err
+		})
+		if err != nil {
+			return "", err
+		}
+
+		if reply.Thinking != "" && opts.OnThinking != nil {
+			opts.OnThinking(reply.Thinking)
+		}
+		total.PromptTokens += usage.PromptTokens
+		total.CompletionTokens += usage.CompletionTokens
+		total.EvalDuration += usage.EvalDuration
+		if len(reply.ToolCalls) == 0 {
+			// The answer starts in the last round, after any tool calls
+			total.FirstToken = roundStart.Sub(start) + usage.FirstToken
+			total.Duration = time.Since(start)
+			if opts.OnUsage != nil {
+				opts.OnUsage(total)
+			}
+			return reply.Content, nil
+		}
+		if round >= MaxToolRounds {
+			return "", fmt.Errorf("chat failed: model was still calling tools after %d rounds", MaxToolRounds)
+		}
+
+		messages = append(messages, Message{
+			Role:      "assistant",
+			Content:   reply.Content,
+			ToolCalls: reply.ToolCalls,
+		})
+		for _, call := range reply.ToolCalls {
+			messages = append(messages, runTool(ctx, opts, call))
+		}
+	}
+}
+
+// runTool executes one tool call and returns the message reporting its result.
+// Failures are reported to the model as text so it can recover.
+func runTool(ctx context.Context, opts ChatOptions, call FunctionCall) Message {
+	args := call.Arguments
+	if args == nil {
+		args = map[string]any{}
+	}
+
+	// A backend may return tool calls even when none were offered
+	start := time.Now()
+	var result string
+	var err error
+	if opts.Tools == nil {
+		err = fmt.Errorf("no tools are available, so %q can't be called", call.Name)
+	} else {
+		result, err = opts.Tools.Call(ctx, call.Name, args)
+	}
+	record := ToolCall{
+		ID:        call.ID,
+		Name:      call.Name,
+		Arguments: args,
+		Result:    result,
+		Err:       err,
+		Duration:  time.Since(start),
+	}
+	if opts.OnToolCall != nil {
+		opts.OnToolCall(record)
+	}
+
+	content := result
+	if err != nil {
+		content = "Error: " + err.Error()
+	}
+	return Message{
+		Role:       "tool",
+		Content:    content,
+		ToolName:   call.Name,
+		ToolCallID: call.ID,
+	}
+}
+
+// OfferTools reports whether tools should be offered to the model. Backends
+// reject requests with tools for models that can't call them.
+func OfferTools(ctx context.Context, p Provider, model string, registry *tools.Registry) bool {
+	if registry.Len() == 0 {
+		return false
+	}
+	ok, err := p.HasCapability(ctx, model, CapabilityTools)
+	return err == nil && ok
+}
@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"context"
+	"time"
+)
+
+// minRetryDelay is the shortest wait between tries, so a zero delay
+// doesn't hammer a struggling backend
+const minRetryDelay = 100 * time.Millisecond
+
+// RetryPolicy controls how transient failures are retried. The zero value
+// never retries.
+type RetryPolicy struct {
+	Attempts int           // tries in total, including the first
+	Delay    time.Duration // wait before the first retry, doubled after each
+	MaxDelay time.Duration // longest wait between tries
+}
+
+// Do runs fn until it succeeds, fails with an error that isn't transient,
+// or runs out of attempts. fn reports whether a failed try can be repeated,
+// which it can't once part of the result has been handed on.
+func (p RetryPolicy) Do(ctx context.Context, fn func() (bool, error)) error {
+	delay := max(p.Delay, minRetryDelay)
+	for attempt := 1; ; attempt++ {
+		repeatable, err := fn()
+		if err == nil || !repeatable || attempt >= p.Attempts || !IsTransient(err) || ctx.Err() != nil {
+			return err
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		delay *= 2
+		if p.MaxDelay > 0 && delay > p.MaxDelay {
+			delay = max(p.MaxDelay, minRetryDelay)
+		}
+	}
+}
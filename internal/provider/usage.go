@@ -0,0 +1,22 @@
+package provider
+
+import "time"
+
+// Usage records the tokens a reply took and how long it took to generate.
+// Fields are zero when the backend doesn't report them.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+
+	FirstToken   time.Duration // from the request until the first token of the answer
+	EvalDuration time.Duration // spent generating CompletionTokens
+	Duration     time.Duration // the whole reply, including tool calls
+}
+
+// TokensPerSecond is the generation speed, or 0 when unknown
+func (u Usage) TokensPerSecond() float64 {
+	if u.CompletionTokens == 0 || u.EvalDuration <= 0 {
+		return 0
+	}
+	return float64(u.CompletionTokens) / u.EvalDuration.Seconds()
+}
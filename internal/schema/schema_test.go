@@ -0,0 +1,117 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string // empty when the schema is valid
+	}{
+		{name: "any json", schema: `"json"`},
+		{name: "true", schema: `true`},
+		{name: "false", schema: `false`},
+		{name: "empty object", schema: `{}`},
+		{name: "object", schema: `{"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"]}`},
+		{name: "type list", schema: `{"type": ["string", "null"]}`},
+		{name: "boolean subschemas", schema: `{"properties": {"a": true, "b": false}, "additionalProperties": false}`},
+		{name: "null items is ignored", schema: `{"type": "array", "items": null}`},
+		{name: "invalid json", schema: `{`, wantErr: "failed to parse schema"},
+		{name: "null", schema: `null`, wantErr: "null is not a schema"},
+		{name: "unknown type", schema: `{"type": "date"}`, wantErr: `$: unknown type "date"`},
+		{name: "bad type keyword", schema: `{"type": 1}`, wantErr: "type must be a string"},
+		{name: "invalid pattern", schema: `{"pattern": "("}`, wantErr: "$: invalid pattern"},
+		{name: "nested unknown type", schema: `{"items": {"properties": {"a": {"type": "x"}}}}`, wantErr: `$.items.a: unknown type "x"`},
+		{name: "null property", schema: `{"properties": {"a": null}}`, wantErr: "$.a: null is not a schema"},
+		{name: "null anyOf entry", schema: `{"anyOf": [{"type": "string"}, null]}`, wantErr: "$.anyOf[1]: null is not a schema"},
+		{name: "null allOf entry", schema: `{"allOf": [null]}`, wantErr: "$.allOf[0]: null is not a schema"},
+		{name: "nested null anyOf entry", schema: `{"properties": {"a": {"anyOf": [null]}}}`, wantErr: "$.a.anyOf[0]: null is not a schema"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse([]byte(tt.schema))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Parse(%s) failed: %v", tt.schema, err)
+				}
+				if s == nil {
+					t.Fatalf("Parse(%s) returned no schema", tt.schema)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Parse(%s) error = %v, want one containing %q", tt.schema, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	const person = `{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 1, "maxLength": 5},
+			"age": {"type": "integer", "minimum": 0, "maximum": 150},
+			"tags": {"type": "array", "items": {"type": "string", "pattern": "^[a-z]+$"}, "minItems": 1, "maxItems": 2},
+			"role": {"enum": ["admin", "user"]}
+		},
+		"required": ["name"],
+		"additionalProperties": false
+	}`
+
+	tests := []struct {
+		name    string
+		schema  string
+		data    string
+		wantErr string // empty when data matches
+	}{
+		{name: "any json", schema: `"json"`, data: `[1, "a"]`},
+		{name: "not json", schema: `"json"`, data: `{"a":`, wantErr: "not valid JSON"},
+		{name: "trailing data", schema: `{}`, data: `{} {}`, wantErr: "unexpected data after the value"},
+		{name: "false", schema: `false`, data: `1`, wantErr: "$: not allowed"},
+		{name: "person", schema: person, data: `{"name": "Ann", "age": 30, "tags": ["a"], "role": "admin"}`},
+		{name: "missing required", schema: person, data: `{"age": 30}`, wantErr: `$: missing required property "name"`},
+		{name: "wrong type", schema: person, data: `{"name": 3}`, wantErr: "$.name: expected string, got number"},
+		{name: "too short", schema: person, data: `{"name": ""}`, wantErr: "$.name: expected at least 1 characters, got 0"},
+		{name: "too long", schema: person, data: `{"name": "Annabel"}`, wantErr: "$.name: expected at most 5 characters, got 7"},
+		{name: "not an integer", schema: person, data: `{"name": "Ann", "age": 1.5}`, wantErr: "$.age: expected integer, got number"},
+		{name: "below minimum", schema: person, data: `{"name": "Ann", "age": -1}`, wantErr: "$.age: must be at least 0"},
+		{name: "above maximum", schema: person, data: `{"name": "Ann", "age": 200}`, wantErr: "$.age: must be at most 150"},
+		{name: "too few items", schema: person, data: `{"name": "Ann", "tags": []}`, wantErr: "$.tags: expected at least 1 items, got 0"},
+		{name: "too many items", schema: person, data: `{"name": "Ann", "tags": ["a", "b", "c"]}`, wantErr: "$.tags: expected at most 2 items, got 3"},
+		{name: "pattern", schema: person, data: `{"name": "Ann", "tags": ["A"]}`, wantErr: "$.tags[0]: must match ^[a-z]+$"},
+		{name: "enum", schema: person, data: `{"name": "Ann", "role": "root"}`, wantErr: `$.role: must be one of "admin", "user"`},
+		{name: "additional property", schema: person, data: `{"name": "Ann", "x": 1}`, wantErr: `$: unexpected property "x"`},
+		{name: "type list", schema: `{"type": ["string", "null"]}`, data: `null`},
+		{name: "type list mismatch", schema: `{"type": ["string", "null"]}`, data: `1`, wantErr: "$: expected string or null, got number"},
+		{name: "const", schema: `{"const": {"a": 1}}`, data: `{"a": 1}`},
+		{name: "const mismatch", schema: `{"const": 1}`, data: `2`, wantErr: "$: must be 1"},
+		{name: "anyOf", schema: `{"anyOf": [{"type": "string"}, {"type": "number"}]}`, data: `2`},
+		{name: "anyOf mismatch", schema: `{"anyOf": [{"type": "string"}, {"type": "number"}]}`, data: `true`, wantErr: "$: matches none of the allowed schemas"},
+		{name: "allOf", schema: `{"allOf": [{"type": "number"}, {"minimum": 3}]}`, data: `4`},
+		{name: "allOf mismatch", schema: `{"allOf": [{"type": "number"}, {"minimum": 3}]}`, data: `2`, wantErr: "$: must be at least 3"},
+		{name: "additional properties schema", schema: `{"additionalProperties": {"type": "number"}}`, data: `{"a": "x"}`, wantErr: "$.a: expected number, got string"},
+		{name: "length counts characters", schema: `{"maxLength": 2}`, data: `"éé"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse([]byte(tt.schema))
+			if err != nil {
+				t.Fatalf("Parse(%s) failed: %v", tt.schema, err)
+			}
+			err = s.Validate([]byte(tt.data))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate(%s) failed: %v", tt.data, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate(%s) error = %v, want one containing %q", tt.data, err, tt.wantErr)
+			}
+		})
+	}
+}
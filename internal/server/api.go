@@ -0,0 +1,194 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/diiviikk5/dvkcli/internal/chat"
+	"github.com/diiviikk5/dvkcli/internal/memory"
+	"github.com/google/uuid"
+)
+
+// listConversations handles GET /api/conversations?limit=n
+func (s *Server) listConversations(w http.ResponseWriter, r *http.Request) {
+	convs, err := s.Store.ListConversations(r.Context(), queryInt(r, "limit", 20, 200))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	out := make([]conversationJSON, 0, len(convs))
+	for i := range convs {
+		out = append(out, toConversationJSON(&convs[i]))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"conversations": out})
+}
+
+// createConversation handles POST /api/conversations {"title": "..."}
+func (s *Server) createConversation(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Title string `json:"title"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		req.Title = "New Chat"
+	}
+
+	conv, err := s.Store.CreateConversation(r.Context(), uuid.New().String(), req.Title)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, toConversationJSON(conv))
+}
+
+// getConversation handles GET /api/conversations/{id}, returning the
+// messages of the active branch
+func (s *Server) getConversation(w http.ResponseWriter, r *http.Request) {
+	conv, ok := s.conversation(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, toConversationJSON(conv))
+}
+
+// deleteConversation handles DELETE /api/conversations/{id}
+func (s *Server) deleteConversation(w http.ResponseWriter, r *http.Request) {
+	deleted, err := s.Store.DeleteConversation(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !deleted {
+		writeError(w, http.StatusNotFound, "conversation not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listMessages handles GET /api/conversations/{id}/messages
+func (s *Server) listMessages(w http.ResponseWriter, r *http.Request) {
+	conv, ok := s.conversation(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	out := make([]messageJSON, 0, len(conv.Messages))
+	for _, msg := range conv.Messages {
+		out = append(out, toMessageJSON(msg))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"messages": out})
+}
+
+// addMessage handles POST /api/conversations/{id}/messages, appending a
+// message to the active branch without asking the model to reply
+func (s *Server) addMessage(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Role == "" {
+		req.Role = "user"
+	}
+	if req.Role != "user" && req.Role != "assistant" && req.Role != "system" {
+		writeError(w, http.StatusBadRequest, "role must be user, assistant or system")
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		writeError(w, http.StatusBadRequest, "content is required")
+		return
+	}
+
+	conv, ok := s.conversation(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	msg := memory.Message{
+		ID:        uuid.New().String(),
+		Role:      req.Role,
+		Content:   req.Content,
+		CreatedAt: time.Now(),
+	}
+	msgs := []memory.Message{msg}
+	if err := s.Engine.Append(r.Context(), conv.ID, conv.Title, msgs); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, toMessageJSON(msgs[0]))
+}
+
+// search handles GET /api/search?q=...&mode=semantic|keyword&limit=n
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	limit := queryInt(r, "limit", 10, 100)
+
+	type result struct {
+		Message    messageJSON `json:"message"`
+		Similarity float64     `json:"similarity,omitempty"`
+	}
+	results := []result{}
+
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", "semantic":
+		embedding, err := s.Engine.Client.Embed(r.Context(), query)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		found, err := s.Store.Search(r.Context(), embedding, limit)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, f := range found {
+			results = append(results, result{Message: toMessageJSON(f.Message), Similarity: f.Similarity})
+		}
+	case "keyword":
+		found, err := s.Store.KeywordSearch(r.Context(), query, limit)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, msg := range found {
+			results = append(results, result{Message: toMessageJSON(msg)})
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "mode must be semantic or keyword")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+// listPersonas handles GET /api/personas
+func (s *Server) listPersonas(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"personas": s.Engine.PersonaNames(),
+		"default":  chat.DefaultPersona,
+	})
+}
+
+// conversation loads a conversation, answering 404 when it does not exist
+func (s *Server) conversation(w http.ResponseWriter, r *http.Request, id string) (*memory.Conversation, bool) {
+	conv, err := s.Store.GetConversation(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	if conv == nil {
+		writeError(w, http.StatusNotFound, "conversation not found")
+		return nil, false
+	}
+	return conv, true
+}
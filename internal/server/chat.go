@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/diiviikk5/dvkcli/internal/chat"
+	"github.com/diiviikk5/dvkcli/internal/memory"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+	"github.com/google/uuid"
+)
+
+// chatRequest is the body of POST /api/chat
+type chatRequest struct {
+	ConversationID string   `json:"conversation_id"` // continue this conversation; empty starts one
+	Message        string   `json:"message"`
+	Model          string   `json:"model"`
+	Persona        string   `json:"persona"`
+	Temperature    *float64 `json:"temperature"`
+	Recall         bool     `json:"recall"` // add related messages from other conversations
+}
+
+// sseWriter sends server-sent events
+type sseWriter struct {
+	w http.ResponseWriter
+	f http.Flusher
+}
+
+// send writes one event with a JSON payload
+func (s *sseWriter) send(event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	s.f.Flush()
+	return nil
+}
+
+// chat handles POST /api/chat. The reply streams as server-sent events:
+// "start" with the conversation and message IDs, "delta" for each chunk of
+// text, "fallback" when another backend takes over, then "done" with the
+// full reply or "error".
+func (s *Server) chat(w http.ResponseWriter, r *http.Request) {
+	var req chatRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		writeError(w, http.StatusBadRequest, "message is required")
+		return
+	}
+
+	system, err := s.Engine.Persona(req.Persona)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Continue the active branch of an existing conversation
+	var history []memory.Message
+	conversationID, title := req.ConversationID, chat.Title(req.Message)
+	if conversationID != "" {
+		if s.Store == nil {
+			writeError(w, http.StatusServiceUnavailable, "memory is disabled")
+			return
+		}
+		conv, ok := s.conversation(w, r, conversationID)
+		if !ok {
+			return
+		}
+		history, title = conv.Messages, conv.Title
+	} else {
+		conversationID = uuid.New().String()
+	}
+
+	if req.Recall {
+		recalled, err := s.Engine.Recall(r.Context(), req.Message, conversationID)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		system = chat.WithRecall(system, recalled)
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	events := &sseWriter{w: w, f: flusher}
+
+	user := memory.Message{
+		ID:        uuid.New().String(),
+		Role:      "user",
+		Content:   req.Message,
+		CreatedAt: time.Now(),
+	}
+	events.send("start", map[string]string{"conversation_id": conversationID, "message_id": user.ID})
+
+	turns := append(chat.TurnsFromMemory(history), chat.Turn{Role: user.Role, Content: user.Content})
+	messages := s.Engine.BuildMessages(system, turns)
+
+	model := req.Model
+	if model == "" {
+		model = s.Engine.Client.Model()
+	}
+
+	// Tools need someone to approve commands and review edits, so the API
+	// offers none
+	backend := s.Engine.Backend
+	var usage provider.Usage
+	var thinking []string
+	reply, err := s.Engine.Client.Chat(r.Context(), messages, provider.ChatOptions{
+		Model:       model,
+		Temperature: req.Temperature,
+		OnContent: func(content string) {
+			events.send("delta", map[string]string{"content": content})
+		},
+		OnFallback: func(f provider.Fallback) {
+			backend, model = f.To.Backend, f.To.Model
+			events.send("fallback", map[string]string{"backend": backend, "model": model, "error": f.Err.Error()})
+		},
+		OnUsage: func(u provider.Usage) {
+			usage = u
+		},
+		OnThinking: func(text string) {
+			thinking = append(thinking, strings.TrimSpace(text))
+		},
+	})
+	if err == nil && reply == "" {
+		err = errors.New("no response from the model")
+	}
+	if err != nil {
+		events.send("error", map[string]string{"error": err.Error()})
+		return
+	}
+
+	assistant := memory.Message{
+		ID:        uuid.New().String(),
+		Role:      "assistant",
+		Content:   reply,
+		Thinking:  strings.Join(thinking, "\n\n"),
+		CreatedAt: time.Now(),
+		Backend:   backend,
+		Model:     model,
+	}
+	chat.SetUsage(&assistant, usage)
+
+	// The exchange is kept even if the client has gone away, and follows
+	// whatever was added to the conversation meanwhile
+	saveErr := s.Engine.Append(context.WithoutCancel(r.Context()), conversationID, title, []memory.Message{user, assistant})
+
+	done := map[string]any{
+		"conversation_id": conversationID,
+		"message_id":      assistant.ID,
+		"content":         reply,
+		"backend":         backend,
+		"model":           model,
+		"usage":           toUsageJSON(usage),
+		"saved":           s.Store != nil && saveErr == nil,
+	}
+	if assistant.Thinking != "" {
+		done["thinking"] = assistant.Thinking
+	}
+	events.send("done", done)
+}
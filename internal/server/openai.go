@@ -0,0 +1,435 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/diiviikk5/dvkcli/internal/attach"
+	"github.com/diiviikk5/dvkcli/internal/chat"
+	"github.com/diiviikk5/dvkcli/internal/memory"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+	"github.com/google/uuid"
+)
+
+// Headers that opt OpenAI-compatible requests into dvkcli's features
+const (
+	// HeaderPersona selects a persona whose system prompt comes first
+	HeaderPersona = "X-Dvkcli-Persona"
+	// HeaderMemory set to "recall" (or "true") adds related past messages
+	// to the prompt; "off" keeps the exchange out of memory
+	HeaderMemory = "X-Dvkcli-Memory"
+	// HeaderConversation names the conversation an exchange is logged to.
+	// Without it, or set to "new", the exchange starts a conversation of its
+	// own. Responses carry it so clients can keep appending to one
+	// conversation.
+	HeaderConversation = "X-Dvkcli-Conversation"
+)
+
+// newConversation is the HeaderConversation value that starts a conversation
+const newConversation = "new"
+
+// openAIMessage is a chat message in the OpenAI format. Content is a string
+// or a list of text and image_url parts.
+type openAIMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// openAIPart is one part of multi-part message content
+type openAIPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageURL struct {
+		URL string `json:"url"`
+	} `json:"image_url"`
+}
+
+// chatCompletionRequest is the body of POST /v1/chat/completions. Fields
+// Ollama has no use for, such as tools, are ignored.
+type chatCompletionRequest struct {
+	Model         string          `json:"model"`
+	Messages      []openAIMessage `json:"messages"`
+	Stream        bool            `json:"stream"`
+	StreamOptions struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+	Temperature *float64 `json:"temperature"`
+}
+
+// completionChoice is the choice of a complete response
+type completionChoice struct {
+	Index        int               `json:"index"`
+	Message      map[string]string `json:"message"`
+	FinishReason string            `json:"finish_reason"`
+}
+
+// chunkChoice is the choice of a streamed chunk; FinishReason is set on
+// the last one
+type chunkChoice struct {
+	Index        int               `json:"index"`
+	Delta        map[string]string `json:"delta"`
+	FinishReason *string           `json:"finish_reason"`
+}
+
+// chatCompletion is a response to POST /v1/chat/completions, or one chunk
+// of a streamed response
+type chatCompletion struct {
+	ID      string           `json:"id"`
+	Object  string           `json:"object"`
+	Created int64            `json:"created"`
+	Model   string           `json:"model"`
+	Choices []any            `json:"choices"`
+	Usage   *completionUsage `json:"usage,omitempty"`
+}
+
+// completionUsage counts the tokens of a completion
+type completionUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func toCompletionUsage(u provider.Usage) *completionUsage {
+	return &completionUsage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.PromptTokens + u.CompletionTokens,
+	}
+}
+
+// chatCompletions handles POST /v1/chat/completions
+func (s *Server) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req chatCompletionRequest
+	if !decodeOpenAIJSON(w, r, &req) {
+		return
+	}
+	if len(req.Messages) == 0 {
+		writeOpenAIError(w, http.StatusBadRequest, "messages is required")
+		return
+	}
+	model := req.Model
+	if model == "" {
+		model = s.Engine.Client.Model()
+	}
+
+	turns, err := openAITurns(req.Messages)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	last := lastUserText(turns)
+
+	// A persona only applies when asked for; the client's own system
+	// messages follow it
+	var system string
+	if name := r.Header.Get(HeaderPersona); name != "" {
+		if system, err = s.Engine.Persona(name); err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	mode := strings.ToLower(r.Header.Get(HeaderMemory))
+	conversationID := r.Header.Get(HeaderConversation)
+	if conversationID == "" || strings.EqualFold(conversationID, newConversation) {
+		conversationID = uuid.New().String()
+	}
+	if mode == "recall" || mode == "true" {
+		recalled, err := s.Engine.Recall(r.Context(), last, conversationID)
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		system = chat.WithRecall(system, recalled)
+	}
+	messages := s.Engine.BuildMessages(system, turns)
+
+	completion := chatCompletion{
+		ID:      "chatcmpl-" + uuid.New().String(),
+		Created: time.Now().Unix(),
+		Model:   model,
+	}
+	backend := s.Engine.Backend
+	var usage provider.Usage
+	var thinking []string
+	opts := provider.ChatOptions{
+		Model:       model,
+		Temperature: req.Temperature,
+		OnFallback: func(f provider.Fallback) {
+			backend, model = f.To.Backend, f.To.Model
+		},
+		OnUsage: func(u provider.Usage) {
+			usage = u
+		},
+		OnThinking: func(text string) {
+			thinking = append(thinking, strings.TrimSpace(text))
+		},
+	}
+	logged := s.Store != nil && mode != "off" && last != ""
+	if logged {
+		w.Header().Set(HeaderConversation, conversationID)
+	}
+
+	var reply string
+	if req.Stream {
+		reply, err = s.streamCompletion(w, r, completion, messages, opts, &usage, req.StreamOptions.IncludeUsage)
+		if err != nil {
+			return
+		}
+	} else {
+		reply, err = s.Engine.Client.Chat(r.Context(), messages, opts)
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		completion.Object = "chat.completion"
+		completion.Model = model
+		message := map[string]string{"role": "assistant", "content": reply}
+		if len(thinking) > 0 {
+			message["reasoning_content"] = strings.Join(thinking, "\n\n")
+		}
+		completion.Choices = []any{completionChoice{
+			Message:      message,
+			FinishReason: "stop",
+		}}
+		completion.Usage = toCompletionUsage(usage)
+		writeJSON(w, http.StatusOK, completion)
+	}
+
+	if logged {
+		s.logExchange(r.Context(), conversationID, backend, model, last, reply, strings.Join(thinking, "\n\n"), usage)
+	}
+}
+
+// streamCompletion streams a reply as chat.completion.chunk events, ending
+// with a chunk holding usage when includeUsage is set. Once the stream has
+// started, errors can only be reported in it.
+func (s *Server) streamCompletion(w http.ResponseWriter, r *http.Request, completion chatCompletion, messages []provider.Message, opts provider.ChatOptions, usage *provider.Usage, includeUsage bool) (string, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeOpenAIError(w, http.StatusInternalServerError, "streaming is not supported")
+		return "", errors.New("streaming is not supported")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	completion.Object = "chat.completion.chunk"
+	send := func(delta map[string]string, finish *string) {
+		completion.Choices = []any{chunkChoice{Delta: delta, FinishReason: finish}}
+		data, _ := json.Marshal(completion)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+
+	send(map[string]string{"role": "assistant", "content": ""}, nil)
+	opts.OnContent = func(content string) {
+		send(map[string]string{"content": content}, nil)
+	}
+	reply, err := s.Engine.Client.Chat(r.Context(), messages, opts)
+	if err != nil {
+		data, _ := json.Marshal(openAIError(err.Error()))
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+		return "", err
+	}
+
+	stop := "stop"
+	send(map[string]string{}, &stop)
+	if includeUsage {
+		completion.Choices = []any{}
+		completion.Usage = toCompletionUsage(*usage)
+		data, _ := json.Marshal(completion)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+	return reply, nil
+}
+
+// logExchange records the last user message and the reply in memory
+func (s *Server) logExchange(ctx context.Context, conversationID, backend, model, prompt, reply, thinking string, usage provider.Usage) {
+	// The exchange is kept even if the client has gone away
+	ctx = context.WithoutCancel(ctx)
+
+	user := memory.Message{
+		ID:        uuid.New().String(),
+		Role:      "user",
+		Content:   prompt,
+		CreatedAt: time.Now(),
+	}
+	assistant := memory.Message{
+		ID:        uuid.New().String(),
+		Role:      "assistant",
+		Content:   reply,
+		Thinking:  thinking,
+		CreatedAt: time.Now(),
+		Backend:   backend,
+		Model:     model,
+	}
+	chat.SetUsage(&assistant, usage)
+	s.Engine.Append(ctx, conversationID, "API: "+chat.Title(prompt), []memory.Message{user, assistant})
+}
+
+// openAITurns converts OpenAI messages into turns. Images must be sent as
+// base64 data URLs, since dvkcli does not fetch remote content.
+func openAITurns(msgs []openAIMessage) ([]chat.Turn, error) {
+	turns := make([]chat.Turn, 0, len(msgs))
+	for i, msg := range msgs {
+		role := msg.Role
+		if role == "developer" {
+			role = "system"
+		}
+		turn := chat.Turn{Role: role}
+
+		var text string
+		var parts []openAIPart
+		switch {
+		case len(msg.Content) == 0 || string(msg.Content) == "null":
+		case json.Unmarshal(msg.Content, &text) == nil:
+			turn.Content = text
+		case json.Unmarshal(msg.Content, &parts) == nil:
+			var texts []string
+			for _, part := range parts {
+				switch part.Type {
+				case "text":
+					texts = append(texts, part.Text)
+				case "image_url":
+					data, err := decodeDataURL(part.ImageURL.URL)
+					if err != nil {
+						return nil, fmt.Errorf("messages[%d]: %w", i, err)
+					}
+					turn.Attachments = append(turn.Attachments, attach.Attachment{
+						Kind: attach.KindImage,
+						Path: "image",
+						Size: int64(len(data)),
+						Data: data,
+					})
+				}
+			}
+			turn.Content = strings.Join(texts, "\n")
+		default:
+			return nil, fmt.Errorf("messages[%d]: content must be a string or a list of parts", i)
+		}
+		turns = append(turns, turn)
+	}
+	return turns, nil
+}
+
+// decodeDataURL extracts the bytes of a base64 data URL
+func decodeDataURL(url string) ([]byte, error) {
+	header, payload, ok := strings.Cut(url, ",")
+	if !strings.HasPrefix(header, "data:") || !strings.HasSuffix(header, ";base64") || !ok {
+		return nil, errors.New("images must be base64 data URLs")
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image data: %w", err)
+	}
+	return data, nil
+}
+
+// lastUserText returns the text of the last user turn
+func lastUserText(turns []chat.Turn) string {
+	for i := len(turns) - 1; i >= 0; i-- {
+		if turns[i].Role == "user" {
+			return turns[i].Content
+		}
+	}
+	return ""
+}
+
+// embeddingsRequest is the body of POST /v1/embeddings. Input is a string
+// or a list of strings.
+type embeddingsRequest struct {
+	Model string          `json:"model"`
+	Input json.RawMessage `json:"input"`
+}
+
+// embeddings handles POST /v1/embeddings
+func (s *Server) embeddings(w http.ResponseWriter, r *http.Request) {
+	var req embeddingsRequest
+	if !decodeOpenAIJSON(w, r, &req) {
+		return
+	}
+
+	var inputs []string
+	var single string
+	if json.Unmarshal(req.Input, &single) == nil {
+		inputs = []string{single}
+	} else if err := json.Unmarshal(req.Input, &inputs); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "input must be a string or a list of strings")
+		return
+	}
+	if len(inputs) == 0 {
+		writeOpenAIError(w, http.StatusBadRequest, "input is required")
+		return
+	}
+
+	model := req.Model
+	if model == "" {
+		model = s.Engine.Client.EmbedModel()
+	}
+	vectors, tokens, err := s.Engine.Client.EmbedBatch(r.Context(), model, inputs)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	data := make([]map[string]any, len(vectors))
+	for i, v := range vectors {
+		data[i] = map[string]any{"object": "embedding", "index": i, "embedding": v}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"object": "list",
+		"data":   data,
+		"model":  model,
+		"usage":  map[string]int{"prompt_tokens": tokens, "total_tokens": tokens},
+	})
+}
+
+// models handles GET /v1/models
+func (s *Server) models(w http.ResponseWriter, r *http.Request) {
+	models, err := s.Engine.Client.ListModels(r.Context())
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	data := make([]map[string]any, 0, len(models))
+	for _, m := range models {
+		data = append(data, map[string]any{
+			"id":       m.Name,
+			"object":   "model",
+			"created":  m.ModifiedAt.Unix(),
+			"owned_by": s.Engine.Client.Name(),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": data})
+}
+
+// openAIError is an error body in the OpenAI format
+func openAIError(message string) map[string]any {
+	return map[string]any{"error": map[string]any{"message": message, "type": "dvkcli_error"}}
+}
+
+// decodeOpenAIJSON is decodeJSON answering with an error in the OpenAI
+// format
+func decodeOpenAIJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := readJSON(w, r, v); err != nil {
+		writeOpenAIError(w, bodyErrorStatus(err), err.Error())
+		return false
+	}
+	return true
+}
+
+// writeOpenAIError sends an error in the OpenAI format
+func writeOpenAIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, openAIError(message))
+}
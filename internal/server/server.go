@@ -0,0 +1,275 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diiviikk5/dvkcli/internal/chat"
+	"github.com/diiviikk5/dvkcli/internal/memory"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+)
+
+// Server exposes dvkcli's memory and chat over HTTP
+type Server struct {
+	Engine *chat.Engine
+	Store  *memory.Store // nil when memory is disabled
+
+	// Token, when set, must be sent as "Authorization: Bearer <token>".
+	// Without one, only requests addressed to a loopback host and not sent
+	// by a web page are served.
+	Token string
+}
+
+// New creates a server for the engine's store
+func New(engine *chat.Engine, token string) *Server {
+	return &Server{Engine: engine, Store: engine.Store, Token: token}
+}
+
+// Handler returns the API routes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/conversations", s.needStore(s.listConversations))
+	mux.HandleFunc("POST /api/conversations", s.needStore(s.createConversation))
+	mux.HandleFunc("GET /api/conversations/{id}", s.needStore(s.getConversation))
+	mux.HandleFunc("DELETE /api/conversations/{id}", s.needStore(s.deleteConversation))
+	mux.HandleFunc("GET /api/conversations/{id}/messages", s.needStore(s.listMessages))
+	mux.HandleFunc("POST /api/conversations/{id}/messages", s.needStore(s.addMessage))
+	mux.HandleFunc("GET /api/search", s.needStore(s.search))
+	mux.HandleFunc("GET /api/personas", s.listPersonas)
+	mux.HandleFunc("POST /api/chat", s.chat)
+
+	// OpenAI-compatible endpoints
+	mux.HandleFunc("POST /v1/chat/completions", s.chatCompletions)
+	mux.HandleFunc("POST /v1/embeddings", s.embeddings)
+	mux.HandleFunc("GET /v1/models", s.models)
+
+	return s.authorize(mux)
+}
+
+// authorize rejects requests without the bearer token. Without a token it
+// only serves connections from this machine, and rejects requests from web
+// pages: those send an Origin header, and a Host that isn't loopback means
+// the page's domain was rebound to us.
+func (s *Server) authorize(next http.Handler) http.Handler {
+	if s.Token == "" {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case !isLoopbackHost(r.RemoteAddr):
+				writeRouteError(w, r, http.StatusForbidden, "requests from other machines need a bearer token (start the server with --token)")
+			case r.Header.Get("Origin") != "":
+				writeRouteError(w, r, http.StatusForbidden, "requests from web pages need a bearer token (start the server with --token)")
+			case !isLoopbackHost(r.Host):
+				writeRouteError(w, r, http.StatusForbidden, fmt.Sprintf("host %q is not allowed without a bearer token", r.Host))
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+	want := []byte("Bearer " + s.Token)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="dvkcli"`)
+			writeRouteError(w, r, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackHost reports whether a Host header or remote address names this
+// machine
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// writeRouteError sends an error in the format of the route: the OpenAI
+// format under /v1, {"error": message} elsewhere
+func writeRouteError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if strings.HasPrefix(r.URL.Path, "/v1/") {
+		writeOpenAIError(w, status, message)
+		return
+	}
+	writeError(w, status, message)
+}
+
+// needStore answers 503 when memory is disabled
+func (s *Server) needStore(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.Store == nil {
+			writeError(w, http.StatusServiceUnavailable, "memory is disabled")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// conversationJSON is a conversation in API responses
+type conversationJSON struct {
+	ID        string        `json:"id"`
+	Title     string        `json:"title"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	Messages  []messageJSON `json:"messages,omitempty"`
+}
+
+// messageJSON is a message in API responses
+type messageJSON struct {
+	ID             string           `json:"id"`
+	ConversationID string           `json:"conversation_id"`
+	ParentID       string           `json:"parent_id,omitempty"`
+	Role           string           `json:"role"`
+	Content        string           `json:"content"`
+	Thinking       string           `json:"thinking,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	Attachments    []attachmentJSON `json:"attachments,omitempty"`
+	Backend        string           `json:"backend,omitempty"`
+	Model          string           `json:"model,omitempty"`
+	Usage          *usageJSON       `json:"usage,omitempty"`
+}
+
+// usageJSON describes the tokens and time a reply took
+type usageJSON struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TokensPerSecond  float64 `json:"tokens_per_second,omitempty"`
+	FirstTokenMS     int64   `json:"first_token_ms,omitempty"`
+	DurationMS       int64   `json:"duration_ms,omitempty"`
+}
+
+// attachmentJSON describes an attachment without its content
+type attachmentJSON struct {
+	Kind      string `json:"kind"`
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+func toConversationJSON(conv *memory.Conversation) conversationJSON {
+	out := conversationJSON{
+		ID:        conv.ID,
+		Title:     conv.Title,
+		CreatedAt: conv.CreatedAt,
+		UpdatedAt: conv.UpdatedAt,
+	}
+	for _, msg := range conv.Messages {
+		out.Messages = append(out.Messages, toMessageJSON(msg))
+	}
+	return out
+}
+
+func toMessageJSON(msg memory.Message) messageJSON {
+	out := messageJSON{
+		ID:             msg.ID,
+		ConversationID: msg.ConversationID,
+		ParentID:       msg.ParentID,
+		Role:           msg.Role,
+		Content:        msg.Content,
+		Thinking:       msg.Thinking,
+		CreatedAt:      msg.CreatedAt,
+		Backend:        msg.Backend,
+		Model:          msg.Model,
+		Usage:          toUsageJSON(chat.UsageFromMemory(msg)),
+	}
+	for _, att := range msg.Attachments {
+		out.Attachments = append(out.Attachments, attachmentJSON{
+			Kind:      att.Kind,
+			Path:      att.Path,
+			Size:      att.Size,
+			Truncated: att.Truncated,
+		})
+	}
+	return out
+}
+
+// toUsageJSON describes usage, or returns nil when nothing was recorded
+func toUsageJSON(u provider.Usage) *usageJSON {
+	if u.PromptTokens == 0 && u.CompletionTokens == 0 {
+		return nil
+	}
+	return &usageJSON{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TokensPerSecond:  math.Round(u.TokensPerSecond()*10) / 10,
+		FirstTokenMS:     u.FirstToken.Milliseconds(),
+		DurationMS:       u.Duration.Milliseconds(),
+	}
+}
+
+// writeJSON sends v with the given status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError sends {"error": message}
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// errNotJSON is returned by readJSON for a body sent as another media type
+var errNotJSON = errors.New("request body must be sent as Content-Type: application/json")
+
+// decodeJSON reads a request body into v, answering 400 on failure, or 415
+// for a body that isn't JSON. An empty body leaves v unchanged.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := readJSON(w, r, v); err != nil {
+		writeError(w, bodyErrorStatus(err), err.Error())
+		return false
+	}
+	return true
+}
+
+// readJSON reads a request body of at most 8 MB into v. A body must be
+// labelled as JSON, which a web page can't do without a CORS preflight.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	if r.ContentLength != 0 {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			return errNotJSON
+		}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 8<<20)
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return nil
+}
+
+// bodyErrorStatus is the status answering a readJSON error
+func bodyErrorStatus(err error) int {
+	if errors.Is(err, errNotJSON) {
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusBadRequest
+}
+
+// queryInt reads an integer query parameter, clamped to 1–upper
+func queryInt(r *http.Request, key string, def, upper int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(r.URL.Query().Get(key)))
+	if err != nil {
+		return def
+	}
+	return min(max(n, 1), upper)
+}
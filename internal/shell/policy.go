@@ -0,0 +1,394 @@
+package shell
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Decision is what a policy says about a command
+type Decision int
+
+const (
+	// Ask means the user must approve the command
+	Ask Decision = iota
+	// Allow means the command may run without asking
+	Allow
+	// Deny means the command must not run
+	Deny
+)
+
+// Policy decides which commands need approval. Rules are command prefixes
+// matched on whole words, so "go test" matches "go test ./..." but not
+// "go testify". Commands chained with ;, &&, || or |, or substituted with
+// $(...), <(...), >(...) or backticks, are checked part by part: any denied
+// part denies the whole command, and it is only allowed without asking when
+// every part is allowed. A command that redirects output to a file always
+// needs approval, whatever its prefix; only duplicating a descriptor (2>&1)
+// and writing to /dev/null are let through.
+//
+// Deny rules see through what a shell would: variable assignments, paths
+// (/usr/bin/sudo is sudo), wrappers such as env, command and nohup, scripts
+// run with sh -c or eval, and lines continued with a backslash. Allow rules
+// only match a command written exactly as the rule, so none of those tricks
+// can sneak a command past approval. A command that cannot be parsed is
+// denied.
+type Policy struct {
+	Allow []string
+	Deny  []string
+}
+
+// Decide applies the policy to a command
+func (p Policy) Decide(command string) Decision {
+	parsed, ok := parse(command)
+	if !ok || len(parsed.commands) == 0 {
+		return Deny
+	}
+
+	allowed := !parsed.writes
+	for _, argv := range parsed.commands {
+		denied, ok := p.denies(argv, 0)
+		if denied || !ok {
+			return Deny
+		}
+		if !matchAny(p.Allow, argv) {
+			allowed = false
+		}
+	}
+
+	if allowed {
+		return Allow
+	}
+	return Ask
+}
+
+// maxNesting bounds how deep sh -c and eval scripts are followed
+const maxNesting = 8
+
+// assignment matches a variable assignment before a command
+var assignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// keywords are shell reserved words that may come before a command
+var keywords = map[string]bool{
+	"!": true, "{": true, "}": true, "if": true, "then": true, "else": true, "elif": true,
+	"fi": true, "do": true, "done": true, "while": true, "until": true, "time": true,
+}
+
+// wrappers run the command given in their arguments
+var wrappers = map[string]bool{
+	"env": true, "command": true, "builtin": true, "exec": true, "nohup": true, "nice": true,
+	"time": true, "timeout": true, "xargs": true, "stdbuf": true, "setsid": true, "sudo": true, "doas": true,
+}
+
+// shells run the script given to -c
+var shells = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true,
+}
+
+// denies reports whether the command argv, or any command it runs, matches
+// the deny list. ok is false when a script it runs cannot be parsed.
+func (p Policy) denies(argv []string, depth int) (denied, ok bool) {
+	if depth > maxNesting {
+		return false, false
+	}
+	for len(argv) > 0 && (assignment.MatchString(argv[0]) || keywords[argv[0]]) {
+		argv = argv[1:]
+	}
+	if len(argv) == 0 {
+		return false, true
+	}
+
+	name := filepath.Base(argv[0])
+	if matchAny(p.Deny, argv) || matchAny(p.Deny, append([]string{name}, argv[1:]...)) {
+		return true, true
+	}
+
+	switch {
+	case wrappers[name]:
+		// Option arguments vary between wrappers, so every later word that
+		// isn't an option is tried as the start of the wrapped command
+		for i := 1; i < len(argv); i++ {
+			if strings.HasPrefix(argv[i], "-") {
+				continue
+			}
+			if denied, ok := p.denies(argv[i:], depth+1); denied || !ok {
+				return denied, ok
+			}
+		}
+	case shells[name]:
+		for i := 1; i < len(argv)-1; i++ {
+			if !strings.HasPrefix(argv[i], "-") || strings.HasPrefix(argv[i], "--") || !strings.Contains(argv[i], "c") {
+				continue
+			}
+			if denied, ok := p.deniesScript(argv[i+1], depth); denied || !ok {
+				return denied, ok
+			}
+		}
+	case name == "eval":
+		return p.deniesScript(strings.Join(argv[1:], " "), depth)
+	}
+	return false, true
+}
+
+// deniesScript applies the deny list to every command of a script
+func (p Policy) deniesScript(script string, depth int) (denied, ok bool) {
+	commands, ok := Parse(script)
+	if !ok {
+		return false, false
+	}
+	for _, argv := range commands {
+		if denied, ok := p.denies(argv, depth+1); denied || !ok {
+			return denied, ok
+		}
+	}
+	return false, true
+}
+
+// matchAny reports whether any rule is a whole-word prefix of argv
+func matchAny(rules []string, argv []string) bool {
+	for _, rule := range rules {
+		words := strings.Fields(rule)
+		if len(words) == 0 || len(words) > len(argv) {
+			continue
+		}
+		matched := true
+		for i, word := range words {
+			if argv[i] != word {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse splits a command line into the commands it runs, as argument lists
+// with quotes removed. Commands inside $(...), <(...), >(...) and backticks
+// are included after the command using them; redirections and comments are
+// dropped. ok is false for unterminated quotes and substitutions.
+func Parse(command string) (commands [][]string, ok bool) {
+	p, ok := parse(command)
+	if !ok {
+		return nil, false
+	}
+	return p.commands, true
+}
+
+// parse runs a parser over the whole command line
+func parse(command string) (*parser, bool) {
+	p := &parser{input: []rune(command)}
+	if !p.parse(0) || p.pos < len(p.input) {
+		return nil, false
+	}
+	return p, true
+}
+
+// parser is a small sh tokenizer, enough to find the commands a line runs
+type parser struct {
+	input    []rune
+	pos      int
+	commands [][]string
+	writes   bool // some redirection may write to a file
+}
+
+// parse reads commands until the input ends or, inside a substitution, until
+// the closing character stop, which is consumed
+func (p *parser) parse(stop rune) bool {
+	var (
+		argv        []string
+		word        strings.Builder
+		inWord      bool
+		substituted bool   // the word contains a substitution
+		redirect    string // the operator whose target is the next word
+		nested      [][]string
+	)
+	endWord := func() {
+		if !inWord {
+			return
+		}
+		if redirect != "" {
+			if !harmless(redirect, word.String(), substituted) {
+				p.writes = true
+			}
+			redirect = ""
+		} else {
+			argv = append(argv, word.String())
+		}
+		word.Reset()
+		inWord, substituted = false, false
+	}
+	endCommand := func() {
+		endWord()
+		if redirect != "" {
+			// An operator without a target is left for the shell to reject
+			if strings.Contains(redirect, ">") {
+				p.writes = true
+			}
+			redirect = ""
+		}
+		if len(argv) > 0 {
+			p.commands = append(p.commands, argv)
+		}
+		p.commands = append(p.commands, nested...)
+		argv, nested = nil, nil
+	}
+	// substitute runs a nested parser over $(...), <(...), >(...) or `...`
+	substitute := func(stop rune) bool {
+		inner := &parser{input: p.input, pos: p.pos}
+		if !inner.parse(stop) {
+			return false
+		}
+		p.pos = inner.pos
+		p.writes = p.writes || inner.writes
+		nested = append(nested, inner.commands...)
+		inWord, substituted = true, true
+		return true
+	}
+
+	for p.pos < len(p.input) {
+		r := p.input[p.pos]
+		p.pos++
+
+		switch {
+		case stop != 0 && r == stop:
+			endCommand()
+			return true
+		case r == '\\' && p.peek() == '\n':
+			// A line continuation joins the lines without splitting a word
+			p.pos++
+		case r == '\\':
+			if p.pos < len(p.input) {
+				word.WriteRune(p.input[p.pos])
+				p.pos++
+			}
+			inWord = true
+		case r == '\'':
+			end := p.index('\'')
+			if end < 0 {
+				return false
+			}
+			word.WriteString(string(p.input[p.pos:end]))
+			p.pos = end + 1
+			inWord = true
+		case r == '"':
+			if !p.doubleQuoted(&word, substitute) {
+				return false
+			}
+			inWord = true
+		case r == '`':
+			if !substitute('`') {
+				return false
+			}
+		case (r == '$' || r == '<' || r == '>') && p.peek() == '(':
+			p.pos++
+			if !substitute(')') {
+				return false
+			}
+		case r == '#' && !inWord:
+			for p.pos < len(p.input) && p.input[p.pos] != '\n' {
+				p.pos++
+			}
+		case r == ' ' || r == '\t':
+			endWord()
+		case r == '>' || r == '<':
+			// A file descriptor number before the operator isn't an argument
+			if inWord && isDigits(word.String()) {
+				word.Reset()
+				inWord = false
+			}
+			endWord()
+			start := p.pos - 1
+			for p.pos < len(p.input) && strings.ContainsRune("<>&|", p.input[p.pos]) {
+				p.pos++
+			}
+			redirect = string(p.input[start:p.pos])
+		case strings.ContainsRune(";&|\n()", r):
+			endCommand()
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if stop != 0 {
+		return false
+	}
+	endCommand()
+	return true
+}
+
+// doubleQuoted reads a double-quoted string, whose substitutions still run
+func (p *parser) doubleQuoted(word *strings.Builder, substitute func(rune) bool) bool {
+	for p.pos < len(p.input) {
+		r := p.input[p.pos]
+		p.pos++
+		switch {
+		case r == '"':
+			return true
+		case r == '\\' && p.peek() == '\n':
+			p.pos++
+		case r == '\\' && p.pos < len(p.input) && strings.ContainsRune("\"\\$`", p.input[p.pos]):
+			word.WriteRune(p.input[p.pos])
+			p.pos++
+		case r == '`':
+			if !substitute('`') {
+				return false
+			}
+		case r == '$' && p.peek() == '(':
+			p.pos++
+			if !substitute(')') {
+				return false
+			}
+		default:
+			word.WriteRune(r)
+		}
+	}
+	return false
+}
+
+// harmless reports whether a redirection cannot write to a file: it only
+// reads, duplicates or closes a descriptor, or writes to /dev/null
+func harmless(operator, target string, substituted bool) bool {
+	switch {
+	case !strings.Contains(operator, ">"):
+		return true
+	case substituted:
+		return false
+	case strings.HasSuffix(operator, ">&") && (isDigits(target) || target == "-"):
+		return true
+	}
+	return target == "/dev/null"
+}
+
+// index returns the position of the next r, or -1
+func (p *parser) index(r rune) int {
+	for i := p.pos; i < len(p.input); i++ {
+		if p.input[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// peek returns the next rune without consuming it
+func (p *parser) peek() rune {
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+// isDigits reports whether s is a non-empty run of ASCII digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
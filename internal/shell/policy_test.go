@@ -0,0 +1,68 @@
+package shell
+
+import "testing"
+
+func TestDecide(t *testing.T) {
+	policy := Policy{
+		Allow: []string{"echo", "ls", "cat", "go test"},
+		Deny:  []string{"sudo", "rm -rf /", "rm -rf ~"},
+	}
+	tests := []struct {
+		name    string
+		command string
+		want    Decision
+	}{
+		{name: "allowed", command: "ls -la", want: Allow},
+		{name: "allowed prefix", command: "go test ./...", want: Allow},
+		{name: "not a whole word", command: "go testify", want: Ask},
+		{name: "unknown", command: "make build", want: Ask},
+		{name: "denied", command: "sudo ls", want: Deny},
+		{name: "denied by path", command: "/usr/bin/sudo ls", want: Deny},
+		{name: "chained allowed", command: "ls && echo done", want: Allow},
+		{name: "chained unknown", command: "ls; make", want: Ask},
+		{name: "chained denied", command: "ls; rm -rf /", want: Deny},
+		{name: "wrapper", command: "env FOO=1 sudo ls", want: Deny},
+		{name: "sh -c", command: `sh -c "rm -rf ~"`, want: Deny},
+		{name: "eval", command: "eval sudo ls", want: Deny},
+		{name: "command substitution", command: "echo $(rm -rf ~)", want: Deny},
+		{name: "backticks", command: "echo `sudo ls`", want: Deny},
+		{name: "unterminated quote", command: `echo "hi`, want: Deny},
+		{name: "empty", command: "", want: Deny},
+
+		{name: "redirect", command: "echo hi > ~/.bashrc", want: Ask},
+		{name: "redirect without space", command: "echo hi >~/.bashrc", want: Ask},
+		{name: "append", command: "echo hi >> ~/.bashrc", want: Ask},
+		{name: "clobber", command: "echo hi >| ~/.bashrc", want: Ask},
+		{name: "both streams", command: "ls &> out.txt", want: Ask},
+		{name: "both streams appended", command: "ls &>> out.txt", want: Ask},
+		{name: "fd numbered", command: "echo hi 1> out.txt", want: Ask},
+		{name: "stderr", command: "ls 2> errors.txt", want: Ask},
+		{name: "duplicate to a file", command: "ls >& out.txt", want: Ask},
+		{name: "read write", command: "cat <> file", want: Ask},
+		{name: "substituted target", command: "echo hi > $(echo /dev/null)", want: Ask},
+		{name: "missing target", command: "echo hi >", want: Ask},
+		{name: "redirect in substitution", command: "echo $(echo hi > file)", want: Ask},
+		{name: "duplicate descriptor", command: "ls 2>&1", want: Allow},
+		{name: "close descriptor", command: "ls 2>&-", want: Allow},
+		{name: "dev null", command: "ls 2>/dev/null", want: Allow},
+		{name: "input", command: "cat < notes.txt", want: Allow},
+
+		{name: "input process substitution", command: "cat <(rm -rf ~)", want: Deny},
+		{name: "output process substitution", command: "ls > >(sudo tee /etc/passwd)", want: Deny},
+		{name: "process substitution unknown", command: "cat <(make)", want: Ask},
+		{name: "process substitution allowed", command: "cat <(ls)", want: Allow},
+		{name: "unterminated process substitution", command: "cat <(ls", want: Deny},
+
+		{name: "line continuation", command: "rm\\\n -rf /", want: Deny},
+		{name: "continuation inside a word", command: "r\\\nm -rf /", want: Deny},
+		{name: "continuation in quotes", command: "sh -c \"rm -rf \\\n/\"", want: Deny},
+		{name: "continued allowed", command: "ls \\\n -la", want: Allow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Decide(tt.command); got != tt.want {
+				t.Errorf("Decide(%q) = %v, want %v", tt.command, got, tt.want)
+			}
+		})
+	}
+}
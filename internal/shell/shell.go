@@ -0,0 +1,159 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Result is the outcome of running a command
+type Result struct {
+	Command   string
+	Dir       string
+	Stdout    string
+	Stderr    string
+	ExitCode  int
+	Duration  time.Duration
+	TimedOut  bool
+	DryRun    bool
+	Truncated bool
+}
+
+// Runner executes shell commands in a directory
+type Runner struct {
+	Dir       string
+	Timeout   time.Duration
+	MaxOutput int // bytes kept from each of stdout and stderr
+	DryRun    bool
+}
+
+// NewRunner creates a runner with default limits
+func NewRunner(dir string, timeout time.Duration, dryRun bool) *Runner {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &Runner{
+		Dir:       dir,
+		Timeout:   timeout,
+		MaxOutput: 16 * 1024,
+		DryRun:    dryRun,
+	}
+}
+
+// Run executes command with the system shell. A non-zero exit status is
+// reported in the result rather than as an error.
+func (r *Runner) Run(ctx context.Context, command string) (Result, error) {
+	result := Result{Command: command, Dir: r.Dir, DryRun: r.DryRun}
+	if r.DryRun {
+		return result, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	name, args := "sh", []string{"-c", command}
+	if runtime.GOOS == "windows" {
+		name, args = "cmd", []string{"/C", command}
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = r.Dir
+	// Don't wait forever for background children holding the pipes open
+	cmd.WaitDelay = time.Second
+
+	stdout := &limitedBuffer{limit: r.MaxOutput}
+	stderr := &limitedBuffer{limit: r.MaxOutput}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	result.Duration = time.Since(start)
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	result.Truncated = stdout.truncated || stderr.truncated
+
+	if ctx.Err() == context.DeadlineExceeded {
+		result.TimedOut = true
+		result.ExitCode = -1
+		return result, nil
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		return result, fmt.Errorf("failed to run command: %w", err)
+	}
+
+	return result, nil
+}
+
+// Format renders the result for the model or an attachment
+func (r Result) Format() string {
+	if r.DryRun {
+		return "Dry run: the command was not executed."
+	}
+
+	var b strings.Builder
+	switch {
+	case r.TimedOut:
+		b.WriteString(fmt.Sprintf("Timed out: the command was killed after %s\n", r.Duration.Round(time.Second)))
+	default:
+		b.WriteString(fmt.Sprintf("Exit code: %d\n", r.ExitCode))
+	}
+	if r.Stdout != "" {
+		b.WriteString("\nstdout:\n" + strings.TrimRight(r.Stdout, "\n") + "\n")
+	}
+	if r.Stderr != "" {
+		b.WriteString("\nstderr:\n" + strings.TrimRight(r.Stderr, "\n") + "\n")
+	}
+	if r.Stdout == "" && r.Stderr == "" {
+		b.WriteString("(no output)\n")
+	}
+	if r.Truncated {
+		b.WriteString("(output truncated)\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Status summarises the result in a few words
+func (r Result) Status() string {
+	switch {
+	case r.DryRun:
+		return "dry run"
+	case r.TimedOut:
+		return "timed out"
+	default:
+		return fmt.Sprintf("exit %d", r.ExitCode)
+	}
+}
+
+// limitedBuffer keeps the first limit bytes written to it
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
@@ -0,0 +1,361 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diiviikk5/dvkcli/internal/attach"
+	"github.com/diiviikk5/dvkcli/internal/diff"
+)
+
+// Change is a file edit proposed by the model
+type Change struct {
+	Path    string // relative to the project root
+	Old     string
+	New     string
+	Created bool // the file doesn't exist yet
+}
+
+// Diff renders the change as a unified diff
+func (c Change) Diff() string {
+	oldName := "a/" + c.Path
+	if c.Created {
+		oldName = "/dev/null"
+	}
+	return diff.Unified(oldName, "b/"+c.Path, c.Old, c.New, 3)
+}
+
+// Review is the user's verdict on a change. Content replaces the proposed
+// file content when the user edited it before accepting.
+type Review struct {
+	Accepted bool
+	Content  string
+}
+
+// Reviewer shows a change to the user and waits for their verdict
+type Reviewer func(ctx context.Context, change Change) (Review, error)
+
+type reviewerKey struct{}
+
+// WithReviewer returns a context whose edit tools ask reviewer before
+// writing. Without one, edits are refused.
+func WithReviewer(ctx context.Context, reviewer Reviewer) context.Context {
+	return context.WithValue(ctx, reviewerKey{}, reviewer)
+}
+
+// reviewerFrom returns the reviewer carried by ctx, if any
+func reviewerFrom(ctx context.Context) Reviewer {
+	reviewer, _ := ctx.Value(reviewerKey{}).(Reviewer)
+	return reviewer
+}
+
+// ErrChangeRejected is returned when the user rejects an edit
+var ErrChangeRejected = errors.New("change rejected")
+
+// ErrChangeConflict is returned when a file changed while its edit was
+// under review
+var ErrChangeConflict = errors.New("file changed during review")
+
+// Edit records an applied change so it can be undone
+type Edit struct {
+	Path    string // relative to the project root
+	Backup  string // copy of the previous content; empty if the file was created
+	Created bool
+	Time    time.Time
+}
+
+// Editor provides the write_file and apply_patch tools. Every accepted
+// change is written atomically after backing up the previous content.
+type Editor struct {
+	FS        *FS
+	BackupDir string
+
+	mu    sync.Mutex
+	edits []Edit // applied this session, oldest first
+}
+
+// NewEditor creates the edit tools, resolving paths with fsys and keeping
+// backups in backupDir
+func NewEditor(fsys *FS, backupDir string) *Editor {
+	return &Editor{FS: fsys, BackupDir: backupDir}
+}
+
+// Tools returns write_file and apply_patch
+func (e *Editor) Tools() []Tool {
+	return []Tool{
+		New("write_file",
+			"Create a file or replace its entire content. The user reviews the change as a diff before it is written. Prefer apply_patch for small edits to existing files.",
+			`{
+				"type": "object",
+				"properties": {
+					"path": {"type": "string", "description": "File path relative to the project root"},
+					"content": {"type": "string", "description": "The complete new content of the file"}
+				},
+				"required": ["path", "content"]
+			}`,
+			e.writeFile),
+		New("apply_patch",
+			"Edit an existing file with a unified diff. Include a few unchanged context lines around each change; line numbers in @@ headers may be approximate. The user reviews the result before it is written.",
+			`{
+				"type": "object",
+				"properties": {
+					"path": {"type": "string", "description": "File path relative to the project root"},
+					"patch": {"type": "string", "description": "Unified diff with @@ hunks, lines prefixed by space, - or +"}
+				},
+				"required": ["path", "patch"]
+			}`,
+			e.applyPatch),
+	}
+}
+
+// writeFile implements write_file
+func (e *Editor) writeFile(ctx context.Context, args map[string]any) (string, error) {
+	p, err := String(args, "path")
+	if err != nil {
+		return "", err
+	}
+	content, err := String(args, "content")
+	if err != nil {
+		return "", err
+	}
+
+	change, err := e.prepare(p)
+	if err != nil {
+		return "", err
+	}
+	change.New = content
+	return e.propose(ctx, change)
+}
+
+// applyPatch implements apply_patch
+func (e *Editor) applyPatch(ctx context.Context, args map[string]any) (string, error) {
+	p, err := String(args, "path")
+	if err != nil {
+		return "", err
+	}
+	patch, err := String(args, "patch")
+	if err != nil {
+		return "", err
+	}
+
+	change, err := e.prepare(p)
+	if err != nil {
+		return "", err
+	}
+	if change.Created {
+		return "", fmt.Errorf("%s does not exist; use write_file to create it", change.Path)
+	}
+
+	change.New, err = diff.Apply(change.Old, patch)
+	if err != nil {
+		return "", fmt.Errorf("failed to apply patch to %s: %w", change.Path, err)
+	}
+	return e.propose(ctx, change)
+}
+
+// prepare resolves a path and reads the file's current content
+func (e *Editor) prepare(p string) (Change, error) {
+	abs, rel, err := e.FS.Resolve(p)
+	if err != nil {
+		return Change{}, err
+	}
+	if rel == "." || slices.Contains(strings.Split(rel, "/"), ".git") {
+		return Change{}, fmt.Errorf("refusing to write %s", p)
+	}
+
+	data, err := os.ReadFile(abs)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return Change{Path: rel, Created: true}, nil
+	case err != nil:
+		return Change{}, err
+	case attach.IsBinary(data):
+		return Change{}, fmt.Errorf("%s: %w", rel, attach.ErrBinary)
+	}
+	return Change{Path: rel, Old: string(data)}, nil
+}
+
+// propose asks the reviewer about a change and writes it if accepted
+func (e *Editor) propose(ctx context.Context, change Change) (string, error) {
+	if !change.Created && change.Old == change.New {
+		return fmt.Sprintf("%s already has that content; nothing changed.", change.Path), nil
+	}
+
+	reviewer := reviewerFrom(ctx)
+	if reviewer == nil {
+		return "", fmt.Errorf("%w: no one is available to review it", ErrChangeRejected)
+	}
+	review, err := reviewer(ctx, change)
+	if err != nil {
+		return "", err
+	}
+	if !review.Accepted {
+		return "", fmt.Errorf("%w: the user rejected the change to %s", ErrChangeRejected, change.Path)
+	}
+
+	edited := review.Content != change.New
+	change.New = review.Content
+	if err := e.Apply(change); err != nil {
+		return "", err
+	}
+
+	added, removed := diff.Stats(diff.Lines(diff.SplitLines(change.Old), diff.SplitLines(change.New)))
+	verb := "Updated"
+	if change.Created {
+		verb = "Created"
+	}
+	result := fmt.Sprintf("%s %s (+%d -%d lines).", verb, change.Path, added, removed)
+	if edited {
+		result += " The user edited your proposal before accepting it; read the file to see the final content."
+	}
+	return result, nil
+}
+
+// Apply backs up the file and writes the change atomically. The file must
+// still hold change.Old, so edits made during the review aren't lost.
+func (e *Editor) Apply(change Change) error {
+	abs, rel, err := e.FS.Resolve(change.Path)
+	if err != nil {
+		return err
+	}
+
+	current, err := os.ReadFile(abs)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if !change.Created {
+			return fmt.Errorf("%w: %s was deleted; read it again before editing", ErrChangeConflict, rel)
+		}
+	case err != nil:
+		return err
+	case change.Created:
+		return fmt.Errorf("%w: %s was created; read it before editing", ErrChangeConflict, rel)
+	case string(current) != change.Old:
+		return fmt.Errorf("%w: %s was modified; read it again before editing", ErrChangeConflict, rel)
+	}
+
+	edit := Edit{Path: rel, Created: change.Created, Time: time.Now()}
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(abs); err == nil {
+		mode = info.Mode().Perm()
+		edit.Created = false
+		if edit.Backup, err = e.backup(abs, rel); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := WriteFileAtomic(abs, []byte(change.New), mode); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.edits = append(e.edits, edit)
+	e.mu.Unlock()
+	return nil
+}
+
+// backup copies the current content of a file into the backup directory
+func (e *Editor) backup(abs, rel string) (string, error) {
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return "", fmt.Errorf("failed to back up %s: %w", rel, err)
+	}
+
+	dir := filepath.Join(e.BackupDir, time.Now().Format("20060102-150405.000000000"))
+	backup := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(backup), 0700); err != nil {
+		return "", fmt.Errorf("failed to back up %s: %w", rel, err)
+	}
+	if err := os.WriteFile(backup, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to back up %s: %w", rel, err)
+	}
+	return backup, nil
+}
+
+// Undo reverts the most recent change made this session, restoring the
+// backup or removing a file that was created
+func (e *Editor) Undo() (Edit, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.edits) == 0 {
+		return Edit{}, errors.New("no changes to undo")
+	}
+	edit := e.edits[len(e.edits)-1]
+
+	abs, _, err := e.FS.Resolve(edit.Path)
+	if err != nil {
+		return edit, err
+	}
+
+	if edit.Created {
+		if err := os.Remove(abs); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return edit, fmt.Errorf("failed to remove %s: %w", edit.Path, err)
+		}
+	} else {
+		data, err := os.ReadFile(edit.Backup)
+		if err != nil {
+			return edit, fmt.Errorf("failed to read backup of %s: %w", edit.Path, err)
+		}
+		mode := fs.FileMode(0644)
+		if info, err := os.Stat(abs); err == nil {
+			mode = info.Mode().Perm()
+		}
+		if err := WriteFileAtomic(abs, data, mode); err != nil {
+			return edit, err
+		}
+	}
+
+	e.edits = e.edits[:len(e.edits)-1]
+	return edit, nil
+}
+
+// Edits returns the changes applied this session, oldest first
+func (e *Editor) Edits() []Edit {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.edits)
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames
+// it into place, so readers never see a partial file. A symlink is written
+// through to its target rather than replaced.
+func WriteFileAtomic(path string, data []byte, mode fs.FileMode) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
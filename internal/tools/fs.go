@@ -0,0 +1,474 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/diiviikk5/dvkcli/internal/attach"
+)
+
+// ErrOutsideRoot is returned for paths that resolve outside the workspace
+var ErrOutsideRoot = errors.New("path is outside the working directory")
+
+// FS provides read-only file tools rooted at a directory. Paths given by the
+// model are resolved against Root and may not leave it, including through
+// symlinks. Files ignored by .gitignore are hidden.
+type FS struct {
+	Root           string
+	MaxOutputBytes int   // bytes returned by one call
+	MaxReadLines   int   // lines returned by one read_file call
+	MaxEntries     int   // entries returned by list_dir and glob
+	MaxMatches     int   // matches returned by grep
+	MaxGrepSize    int64 // files larger than this are not searched
+	MaxLineLength  int   // grep lines are cut to this many bytes
+
+	ignore *Ignorer
+}
+
+// NewFS creates file tools rooted at root with default limits
+func NewFS(root string) *FS {
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	return &FS{
+		Root:           root,
+		MaxOutputBytes: 32 * 1024,
+		MaxReadLines:   400,
+		MaxEntries:     500,
+		MaxMatches:     200,
+		MaxGrepSize:    1024 * 1024,
+		MaxLineLength:  200,
+		ignore:         NewIgnorer(root),
+	}
+}
+
+// Tools returns read_file, list_dir, glob and grep
+func (f *FS) Tools() []Tool {
+	return []Tool{
+		New("read_file",
+			"Read a text file from the current project. Returns numbered lines; use start_line and end_line to read part of a large file.",
+			`{
+				"type": "object",
+				"properties": {
+					"path": {"type": "string", "description": "File path relative to the project root"},
+					"start_line": {"type": "integer", "description": "First line to read, starting at 1"},
+					"end_line": {"type": "integer", "description": "Last line to read, inclusive"}
+				},
+				"required": ["path"]
+			}`,
+			f.readFile),
+		New("list_dir",
+			"List the files and directories in a project directory. Directories end with a slash.",
+			`{
+				"type": "object",
+				"properties": {
+					"path": {"type": "string", "description": "Directory relative to the project root; defaults to the root"},
+					"depth": {"type": "integer", "description": "How many levels to descend, from 1 to 3; defaults to 1"}
+				}
+			}`,
+			f.listDir),
+		New("glob",
+			"Find project files whose path matches a glob pattern such as **/*.go or cmd/*/main.go.",
+			`{
+				"type": "object",
+				"properties": {
+					"pattern": {"type": "string", "description": "Glob relative to the project root; ** matches any number of directories"}
+				},
+				"required": ["pattern"]
+			}`,
+			f.glob),
+		New("grep",
+			"Search project files for a regular expression. Returns matches as path:line:text.",
+			`{
+				"type": "object",
+				"properties": {
+					"pattern": {"type": "string", "description": "Regular expression (Go RE2 syntax)"},
+					"path": {"type": "string", "description": "File or directory to search; defaults to the project root"},
+					"glob": {"type": "string", "description": "Only search files whose path matches this glob, e.g. **/*.go"},
+					"ignore_case": {"type": "boolean", "description": "Match case-insensitively"}
+				},
+				"required": ["pattern"]
+			}`,
+			f.grep),
+	}
+}
+
+// Resolve returns the absolute and root-relative forms of a path given by
+// the model, rejecting paths that escape the root
+func (f *FS) Resolve(p string) (abs, rel string, err error) {
+	if p == "" {
+		p = "."
+	}
+	abs = filepath.Clean(p)
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(f.Root, abs)
+	}
+
+	if !f.inside(abs) {
+		return "", "", fmt.Errorf("%s: %w", p, ErrOutsideRoot)
+	}
+
+	// Follow symlinks so a link can't point outside the root, even for a
+	// file yet to be created below a linked directory
+	resolved, err := resolveSymlinks(abs)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve %s: %w", p, err)
+	}
+	if !f.inside(resolved) {
+		return "", "", fmt.Errorf("%s: %w", p, ErrOutsideRoot)
+	}
+
+	rel, err = filepath.Rel(f.Root, abs)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", p, ErrOutsideRoot)
+	}
+	return abs, filepath.ToSlash(rel), nil
+}
+
+// resolveSymlinks follows the symlinks of abs. When abs doesn't exist, its
+// nearest existing ancestor is resolved and the rest of the path joined on.
+// A dangling symlink is an error, since writing through it could create a
+// file anywhere.
+func resolveSymlinks(abs string) (string, error) {
+	dir, rest := abs, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if _, lerr := os.Lstat(dir); lerr == nil || !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+}
+
+// inside reports whether abs is the root or below it
+func (f *FS) inside(abs string) bool {
+	rel, err := filepath.Rel(f.Root, abs)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// Ignored reports whether a root-relative path is hidden by .gitignore
+func (f *FS) Ignored(rel string, isDir bool) bool {
+	return f.ignore.Ignored(rel, isDir)
+}
+
+// readFile implements read_file
+func (f *FS) readFile(ctx context.Context, args map[string]any) (string, error) {
+	p, err := String(args, "path")
+	if err != nil {
+		return "", err
+	}
+	abs, rel, err := f.Resolve(p)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory; use list_dir", rel)
+	}
+	if f.Ignored(rel, false) {
+		return "", fmt.Errorf("%s is ignored by .gitignore", rel)
+	}
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return "", err
+	}
+	if attach.IsBinary(data) {
+		return "", fmt.Errorf("%s: %w", rel, attach.ErrBinary)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	start := max(Int(args, "start_line", 1), 1)
+	end := Int(args, "end_line", start+f.MaxReadLines-1)
+	end = min(end, len(lines), start+f.MaxReadLines-1)
+	if start > len(lines) {
+		return "", fmt.Errorf("%s has only %d lines", rel, len(lines))
+	}
+
+	out := newOutput(f.MaxOutputBytes)
+	for i := start; i <= end; i++ {
+		if !out.line(fmt.Sprintf("%6d\t%s", i, lines[i-1])) {
+			end = i - 1
+			break
+		}
+	}
+	if start > 1 || end < len(lines) {
+		out.note(fmt.Sprintf("(lines %d-%d of %d; pass start_line to read more)", start, end, len(lines)))
+	}
+	return out.String(), nil
+}
+
+// listDir implements list_dir
+func (f *FS) listDir(ctx context.Context, args map[string]any) (string, error) {
+	abs, rel, err := f.Resolve(OptionalString(args, "path", "."))
+	if err != nil {
+		return "", err
+	}
+	depth := min(max(Int(args, "depth", 1), 1), 3)
+
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", rel)
+	}
+
+	out := newOutput(f.MaxOutputBytes)
+	count := 0
+	var walk func(dir, relDir string, level int) bool
+	walk = func(dir, relDir string, level int) bool {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return true
+		}
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].IsDir() && !entries[j].IsDir()
+		})
+
+		for _, entry := range entries {
+			entryRel := path.Join(relDir, entry.Name())
+			if f.Ignored(entryRel, entry.IsDir()) {
+				continue
+			}
+			if count >= f.MaxEntries {
+				out.note(fmt.Sprintf("(stopped after %d entries)", f.MaxEntries))
+				return false
+			}
+			count++
+
+			indent := strings.Repeat("  ", level-1)
+			line := indent + entry.Name()
+			if entry.IsDir() {
+				line += "/"
+			} else if info, err := entry.Info(); err == nil {
+				line += fmt.Sprintf(" (%s)", attach.FormatSize(info.Size()))
+			}
+			if !out.line(line) {
+				return false
+			}
+
+			if entry.IsDir() && level < depth {
+				if !walk(filepath.Join(dir, entry.Name()), entryRel, level+1) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	walk(abs, rel, 1)
+
+	if count == 0 {
+		return fmt.Sprintf("%s is empty", rel), nil
+	}
+	return out.String(), nil
+}
+
+// glob implements glob
+func (f *FS) glob(ctx context.Context, args map[string]any) (string, error) {
+	pattern, err := String(args, "pattern")
+	if err != nil {
+		return "", err
+	}
+	if filepath.IsAbs(pattern) || slices.Contains(strings.Split(filepath.ToSlash(pattern), "/"), "..") {
+		return "", fmt.Errorf("%s: %w", pattern, ErrOutsideRoot)
+	}
+
+	matches, err := attach.Glob(f.Root, pattern)
+	if err != nil {
+		return "", err
+	}
+
+	out := newOutput(f.MaxOutputBytes)
+	count := 0
+	for _, match := range matches {
+		// Glob follows symlinked directories, so a match is only listed
+		// when it resolves inside the root, as grep requires
+		abs, rel, err := f.Resolve(match)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(abs)
+		if err != nil || f.Ignored(rel, info.IsDir()) {
+			continue
+		}
+		if count >= f.MaxEntries {
+			out.note(fmt.Sprintf("(stopped after %d matches)", f.MaxEntries))
+			break
+		}
+		count++
+		if info.IsDir() {
+			rel += "/"
+		}
+		if !out.line(rel) {
+			break
+		}
+	}
+
+	if count == 0 {
+		return fmt.Sprintf("No files match %s", pattern), nil
+	}
+	return out.String(), nil
+}
+
+// grep implements grep
+func (f *FS) grep(ctx context.Context, args map[string]any) (string, error) {
+	pattern, err := String(args, "pattern")
+	if err != nil {
+		return "", err
+	}
+	if Bool(args, "ignore_case", false) {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	abs, rel, err := f.Resolve(OptionalString(args, "path", "."))
+	if err != nil {
+		return "", err
+	}
+	filter := OptionalString(args, "glob", "")
+
+	out := newOutput(f.MaxOutputBytes)
+	matches := 0
+	more := 0
+	err = filepath.WalkDir(abs, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		fileRel := filepath.ToSlash(mustRel(f.Root, p))
+		if p != abs && f.Ignored(fileRel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if filter != "" && !attach.MatchPath(filter, fileRel) && !attach.MatchPath(filter, path.Base(fileRel)) {
+			return nil
+		}
+
+		// A symlink is only searched when it resolves inside the root, as
+		// read_file requires
+		if d.Type()&fs.ModeSymlink != 0 {
+			if _, _, err := f.Resolve(p); err != nil {
+				return nil
+			}
+		}
+		if info, err := os.Stat(p); err != nil || !info.Mode().IsRegular() || info.Size() > f.MaxGrepSize {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil || attach.IsBinary(data) {
+			return nil
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), int(f.MaxGrepSize))
+		for n := 1; scanner.Scan(); n++ {
+			text := scanner.Text()
+			if !re.MatchString(text) {
+				continue
+			}
+			if matches >= f.MaxMatches {
+				more++
+				continue
+			}
+			if len(text) > f.MaxLineLength {
+				text = text[:f.MaxLineLength] + "…"
+			}
+			if !out.line(fmt.Sprintf("%s:%d:%s", fileRel, n, text)) {
+				more++
+				continue
+			}
+			matches++
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if matches == 0 && more == 0 {
+		return fmt.Sprintf("No matches for %s in %s", re, rel), nil
+	}
+	if more > 0 {
+		out.note(fmt.Sprintf("(%d more matches not shown; narrow the pattern or path)", more))
+	}
+	return out.String(), nil
+}
+
+// output accumulates tool output up to a byte limit
+type output struct {
+	b     strings.Builder
+	limit int
+	full  bool
+}
+
+func newOutput(limit int) *output {
+	return &output{limit: limit}
+}
+
+// line appends a line, returning false once the limit is reached
+func (o *output) line(s string) bool {
+	if o.full {
+		return false
+	}
+	if o.b.Len()+len(s)+1 > o.limit {
+		o.full = true
+		o.note(fmt.Sprintf("(output truncated at %s)", attach.FormatSize(int64(o.limit))))
+		return false
+	}
+	o.b.WriteString(s)
+	o.b.WriteByte('\n')
+	return true
+}
+
+// note appends a line regardless of the limit
+func (o *output) note(s string) {
+	o.b.WriteString(s)
+	o.b.WriteByte('\n')
+}
+
+func (o *output) String() string {
+	return strings.TrimSuffix(o.b.String(), "\n")
+}
+
+// mustRel returns p relative to root, or p itself if that fails
+func mustRel(root, p string) string {
+	if rel, err := filepath.Rel(root, p); err == nil {
+		return rel
+	}
+	return p
+}
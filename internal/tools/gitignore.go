@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/diiviikk5/dvkcli/internal/attach"
+)
+
+// ignoreRule is one pattern from a .gitignore file
+type ignoreRule struct {
+	base     string // directory of the .gitignore, slash-separated and relative to the root
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool // matched against the path from base rather than any basename
+}
+
+// Ignorer answers whether paths under a root are excluded by .gitignore
+// files. Files are read lazily, one directory at a time, and cached.
+type Ignorer struct {
+	root string
+
+	mu    sync.Mutex
+	rules map[string][]ignoreRule // by directory
+}
+
+// NewIgnorer creates an ignorer for the tree at root
+func NewIgnorer(root string) *Ignorer {
+	return &Ignorer{root: root, rules: make(map[string][]ignoreRule)}
+}
+
+// Ignored reports whether rel, a slash-separated path relative to the root,
+// is ignored. The .git directory is always ignored.
+func (ig *Ignorer) Ignored(rel string, isDir bool) bool {
+	rel = path.Clean(filepath.ToSlash(rel))
+	if rel == "." || rel == "" {
+		return false
+	}
+
+	// A path inside an ignored directory is ignored too
+	segments := strings.Split(rel, "/")
+	for i := range segments {
+		partial := strings.Join(segments[:i+1], "/")
+		dir := isDir || i < len(segments)-1
+		if segments[i] == ".git" && dir {
+			return true
+		}
+		if ig.match(partial, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// match applies the rules of every .gitignore from the root down to rel's
+// directory. Later rules override earlier ones, as in git.
+func (ig *Ignorer) match(rel string, isDir bool) bool {
+	ignored := false
+	for _, dir := range parents(rel) {
+		for _, rule := range ig.load(dir) {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if rule.matches(rel) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// load returns the rules of dir's .gitignore, reading it on first use
+func (ig *Ignorer) load(dir string) []ignoreRule {
+	ig.mu.Lock()
+	defer ig.mu.Unlock()
+
+	if rules, ok := ig.rules[dir]; ok {
+		return rules
+	}
+
+	rules := readIgnoreFile(filepath.Join(ig.root, filepath.FromSlash(dir), ".gitignore"), dir)
+	ig.rules[dir] = rules
+	return rules
+}
+
+// readIgnoreFile parses a .gitignore file, returning nil when it is missing
+func readIgnoreFile(file, base string) []ignoreRule {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, "\\")
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// matches reports whether the rule applies to rel
+func (r ignoreRule) matches(rel string) bool {
+	if r.base != "." {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = strings.TrimPrefix(rel, r.base+"/")
+	}
+
+	if r.anchored {
+		return attach.MatchPath(r.pattern, rel)
+	}
+	ok, err := path.Match(r.pattern, path.Base(rel))
+	return err == nil && ok
+}
+
+// parents returns "." followed by each directory above rel, outermost first
+func parents(rel string) []string {
+	dirs := []string{"."}
+	dir := path.Dir(rel)
+	if dir == "." {
+		return dirs
+	}
+
+	parts := strings.Split(dir, "/")
+	for i := range parts {
+		dirs = append(dirs, strings.Join(parts[:i+1], "/"))
+	}
+	return dirs
+}
@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/diiviikk5/dvkcli/internal/mcp"
+)
+
+// MCPSeparator joins a server name and tool name in bridged tool names
+const MCPSeparator = "__"
+
+// mcpTools wraps the tools of a ready MCP server so the model can call them.
+// Each is named server__tool to keep servers from clashing with each other
+// and with the built-in tools.
+func mcpTools(server *mcp.Server) []Tool {
+	var bridged []Tool
+	for _, t := range server.Status().Tools {
+		bridged = append(bridged, &mcpTool{server: server, tool: t})
+	}
+	return bridged
+}
+
+// RegisterMCP replaces the tools of a server in the registry, returning how
+// many were registered
+func RegisterMCP(r *Registry, server *mcp.Server) (int, error) {
+	prefix := mcpName(server.Name(), "")
+	for _, t := range r.List() {
+		if strings.HasPrefix(t.Name(), prefix) {
+			r.Unregister(t.Name())
+		}
+	}
+
+	var errs []error
+	n := 0
+	for _, t := range mcpTools(server) {
+		if err := r.Register(t); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		n++
+	}
+	return n, errors.Join(errs...)
+}
+
+// mcpTool adapts an MCP tool to the Tool interface
+type mcpTool struct {
+	server *mcp.Server
+	tool   mcp.Tool
+}
+
+func (t *mcpTool) Name() string {
+	return mcpName(t.server.Name(), t.tool.Name)
+}
+
+func (t *mcpTool) Description() string {
+	desc := t.tool.Description
+	if desc == "" {
+		desc = t.tool.Name
+	}
+	return desc + " (from MCP server " + t.server.Name() + ")"
+}
+
+func (t *mcpTool) Schema() json.RawMessage {
+	if len(t.tool.InputSchema) == 0 || string(t.tool.InputSchema) == "null" {
+		return json.RawMessage(`{"type":"object"}`)
+	}
+	return t.tool.InputSchema
+}
+
+func (t *mcpTool) Call(ctx context.Context, args map[string]any) (string, error) {
+	result, err := t.server.CallTool(ctx, t.tool.Name, args)
+	if err != nil {
+		return "", err
+	}
+	if result.IsError {
+		text := result.Text()
+		if text == "" {
+			text = "tool reported an error"
+		}
+		return "", errors.New(text)
+	}
+	return result.Text(), nil
+}
+
+// mcpName builds a bridged tool name, replacing characters tool names may
+// not contain
+func mcpName(server, tool string) string {
+	clean := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+				return r
+			}
+			return '_'
+		}, s)
+	}
+	return clean(server) + MCPSeparator + clean(tool)
+}
@@ -0,0 +1,218 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/diiviikk5/dvkcli/internal/memory"
+	"github.com/google/uuid"
+)
+
+// NotesConversationID is the conversation that notes saved through
+// save_note are appended to
+const NotesConversationID = "notes"
+
+// Embedder turns text into an embedding vector for semantic search
+type Embedder func(ctx context.Context, text string) ([]float32, error)
+
+// Memory exposes the conversation memory store as tools
+type Memory struct {
+	Store *memory.Store
+	Embed Embedder
+
+	// MaxOutputBytes caps the text returned by a call
+	MaxOutputBytes int
+	// MaxSnippet caps how much of each message a search result shows
+	MaxSnippet int
+}
+
+// NewMemory creates memory tools backed by store, embedding queries and
+// notes with embed
+func NewMemory(store *memory.Store, embed Embedder) *Memory {
+	return &Memory{
+		Store:          store,
+		Embed:          embed,
+		MaxOutputBytes: 32 * 1024,
+		MaxSnippet:     300,
+	}
+}
+
+// Tools returns semantic_search, keyword_search, get_conversation and
+// save_note
+func (m *Memory) Tools() []Tool {
+	return []Tool{
+		New("semantic_search",
+			"Search past conversations by meaning. Returns the most similar messages with their conversation IDs.",
+			`{
+				"type": "object",
+				"properties": {
+					"query": {"type": "string", "description": "What to look for, in natural language"},
+					"limit": {"type": "integer", "description": "Maximum number of results, from 1 to 50; defaults to 10"}
+				},
+				"required": ["query"]
+			}`,
+			m.semanticSearch),
+		New("keyword_search",
+			"Search past conversations for messages containing every word of the query, ignoring case. Newest first.",
+			`{
+				"type": "object",
+				"properties": {
+					"query": {"type": "string", "description": "Words that must all appear in the message"},
+					"limit": {"type": "integer", "description": "Maximum number of results, from 1 to 50; defaults to 10"}
+				},
+				"required": ["query"]
+			}`,
+			m.keywordSearch),
+		New("get_conversation",
+			"Fetch a conversation by ID, with the messages of its current branch in order.",
+			`{
+				"type": "object",
+				"properties": {
+					"id": {"type": "string", "description": "Conversation ID from a search result"}
+				},
+				"required": ["id"]
+			}`,
+			m.getConversation),
+		New("save_note",
+			"Save a note to memory so it can be found by later searches.",
+			`{
+				"type": "object",
+				"properties": {
+					"content": {"type": "string", "description": "The note to remember"}
+				},
+				"required": ["content"]
+			}`,
+			m.saveNote),
+	}
+}
+
+func (m *Memory) semanticSearch(ctx context.Context, args map[string]any) (string, error) {
+	query, err := String(args, "query")
+	if err != nil {
+		return "", err
+	}
+	limit := searchLimit(args)
+
+	embedding, err := m.Embed(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	results, err := m.Store.Search(ctx, embedding, limit)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "No matching messages.", nil
+	}
+
+	out := newOutput(m.MaxOutputBytes)
+	for _, r := range results {
+		if !out.line(m.formatMessage(r.Message, fmt.Sprintf("%.0f%% similar", r.Similarity*100))) {
+			break
+		}
+	}
+	return out.String(), nil
+}
+
+func (m *Memory) keywordSearch(ctx context.Context, args map[string]any) (string, error) {
+	query, err := String(args, "query")
+	if err != nil {
+		return "", err
+	}
+
+	messages, err := m.Store.KeywordSearch(ctx, query, searchLimit(args))
+	if err != nil {
+		return "", err
+	}
+	if len(messages) == 0 {
+		return "No matching messages.", nil
+	}
+
+	out := newOutput(m.MaxOutputBytes)
+	for _, msg := range messages {
+		if !out.line(m.formatMessage(msg, "")) {
+			break
+		}
+	}
+	return out.String(), nil
+}
+
+func (m *Memory) getConversation(ctx context.Context, args map[string]any) (string, error) {
+	id, err := String(args, "id")
+	if err != nil {
+		return "", err
+	}
+
+	conv, err := m.Store.GetConversation(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if conv == nil {
+		return "", fmt.Errorf("no conversation with ID %q", id)
+	}
+
+	out := newOutput(m.MaxOutputBytes)
+	out.line(fmt.Sprintf("# %s", conv.Title))
+	out.line(fmt.Sprintf("Conversation %s, updated %s, %d messages", conv.ID, conv.UpdatedAt.Format(time.DateTime), len(conv.Messages)))
+	for _, msg := range conv.Messages {
+		if !out.line(fmt.Sprintf("\n[%s] %s:\n%s", msg.CreatedAt.Format(time.DateTime), msg.Role, msg.Content)) {
+			break
+		}
+	}
+	return out.String(), nil
+}
+
+func (m *Memory) saveNote(ctx context.Context, args map[string]any) (string, error) {
+	content, err := String(args, "content")
+	if err != nil {
+		return "", err
+	}
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", fmt.Errorf("the note is empty")
+	}
+
+	msg := &memory.Message{
+		ID:             uuid.New().String(),
+		ConversationID: NotesConversationID,
+		Role:           "user",
+		Content:        content,
+		CreatedAt:      time.Now(),
+	}
+
+	// A note without an embedding is still found by keyword_search
+	embedded := true
+	if msg.Embedding, err = m.Embed(ctx, content); err != nil {
+		embedded = false
+	}
+	// Notes go at the end of the active branch, read as the note is saved
+	if err := m.Store.AppendMessage(ctx, msg, "Notes"); err != nil {
+		return "", err
+	}
+
+	if !embedded {
+		return fmt.Sprintf("Saved note %s. It could not be embedded, so only keyword_search will find it.", msg.ID), nil
+	}
+	return fmt.Sprintf("Saved note %s.", msg.ID), nil
+}
+
+// formatMessage renders a search result on one line plus a snippet
+func (m *Memory) formatMessage(msg memory.Message, detail string) string {
+	header := fmt.Sprintf("conversation %s · %s · %s", msg.ConversationID, msg.Role, msg.CreatedAt.Format(time.DateTime))
+	if detail != "" {
+		header += " · " + detail
+	}
+
+	snippet := []rune(strings.Join(strings.Fields(msg.Content), " "))
+	if len(snippet) > m.MaxSnippet {
+		snippet = append(snippet[:m.MaxSnippet], '…')
+	}
+	return header + "\n  " + string(snippet)
+}
+
+// searchLimit reads the limit argument, clamped to 1–50
+func searchLimit(args map[string]any) int {
+	return min(max(Int(args, "limit", 10), 1), 50)
+}
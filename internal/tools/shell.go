@@ -0,0 +1,173 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/diiviikk5/dvkcli/internal/shell"
+)
+
+// Approval is the user's answer to a command the model wants to run
+type Approval int
+
+const (
+	// Reject refuses the command
+	Reject Approval = iota
+	// Once runs the command this time only
+	Once
+	// Always runs the command and stops asking about it for the session
+	Always
+)
+
+// String names the approval for the audit log
+func (a Approval) String() string {
+	switch a {
+	case Once:
+		return "approved"
+	case Always:
+		return "always"
+	default:
+		return "rejected"
+	}
+}
+
+// Approver asks the user whether a command may run
+type Approver func(ctx context.Context, command string) (Approval, error)
+
+type approverKey struct{}
+
+// WithApprover returns a context whose tool calls ask approver before
+// running commands. Without one, commands that need approval are refused.
+func WithApprover(ctx context.Context, approver Approver) context.Context {
+	return context.WithValue(ctx, approverKey{}, approver)
+}
+
+// approverFrom returns the approver carried by ctx, if any
+func approverFrom(ctx context.Context) Approver {
+	approver, _ := ctx.Value(approverKey{}).(Approver)
+	return approver
+}
+
+// ErrCommandDenied is returned for commands refused by policy or the user
+var ErrCommandDenied = errors.New("command denied")
+
+// Audit records a command decision. result is nil when nothing ran.
+type Audit func(ctx context.Context, command, source, decision string, result *shell.Result)
+
+// Shell provides the run_shell tool and runs /run commands
+type Shell struct {
+	Runner *shell.Runner
+	Policy shell.Policy
+	Audit  Audit
+
+	mu     sync.Mutex
+	always map[string]bool // commands approved with "always" this session
+}
+
+// NewShell creates the shell tool
+func NewShell(runner *shell.Runner, policy shell.Policy, audit Audit) *Shell {
+	return &Shell{
+		Runner: runner,
+		Policy: policy,
+		Audit:  audit,
+		always: make(map[string]bool),
+	}
+}
+
+// Tool returns run_shell
+func (s *Shell) Tool() Tool {
+	return New("run_shell",
+		"Run a shell command in the project directory and return its exit code, stdout and stderr. The user is asked to approve each command, so explain why it is needed. Commands time out after "+s.Runner.Timeout.String()+".",
+		`{
+			"type": "object",
+			"properties": {
+				"command": {"type": "string", "description": "The command line to run with sh -c"}
+			},
+			"required": ["command"]
+		}`,
+		s.call)
+}
+
+// call implements run_shell, asking for approval unless the policy allows
+// the command
+func (s *Shell) call(ctx context.Context, args map[string]any) (string, error) {
+	command, err := String(args, "command")
+	if err != nil {
+		return "", err
+	}
+
+	decision := s.Policy.Decide(command)
+	if decision == shell.Deny {
+		s.audit(ctx, command, "model", "denied", nil)
+		return "", fmt.Errorf("%w: %q matches the deny list", ErrCommandDenied, command)
+	}
+
+	verdict := "allowed"
+	if decision == shell.Ask && s.approved(command) {
+		verdict = Always.String()
+	} else if decision == shell.Ask && s.Runner.DryRun {
+		// Nothing runs, so no one is asked; the log mustn't claim approval
+		verdict = "dry-run"
+	} else if decision == shell.Ask {
+		approver := approverFrom(ctx)
+		if approver == nil {
+			s.audit(ctx, command, "model", "rejected", nil)
+			return "", fmt.Errorf("%w: no one is available to approve it", ErrCommandDenied)
+		}
+
+		approval, err := approver(ctx, command)
+		if err != nil {
+			return "", err
+		}
+		verdict = approval.String()
+		switch approval {
+		case Reject:
+			s.audit(ctx, command, "model", verdict, nil)
+			return "", fmt.Errorf("%w: the user declined to run it", ErrCommandDenied)
+		case Always:
+			s.mu.Lock()
+			s.always[command] = true
+			s.mu.Unlock()
+		}
+	}
+
+	result, err := s.Runner.Run(ctx, command)
+	if err != nil {
+		// Commands that fail to start are logged as not having run
+		s.audit(ctx, command, "model", verdict, nil)
+		return "", err
+	}
+	s.audit(ctx, command, "model", verdict, &result)
+	return result.Format(), nil
+}
+
+// Run executes a command typed by the user. The deny list still applies.
+func (s *Shell) Run(ctx context.Context, command string) (shell.Result, error) {
+	if s.Policy.Decide(command) == shell.Deny {
+		s.audit(ctx, command, "user", "denied", nil)
+		return shell.Result{}, fmt.Errorf("%w: %q matches the deny list", ErrCommandDenied, command)
+	}
+
+	result, err := s.Runner.Run(ctx, command)
+	if err != nil {
+		s.audit(ctx, command, "user", "user", nil)
+		return result, err
+	}
+	s.audit(ctx, command, "user", "user", &result)
+	return result, nil
+}
+
+// approved reports whether the user chose "always" for this command
+func (s *Shell) approved(command string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.always[command]
+}
+
+func (s *Shell) audit(ctx context.Context, command, source, decision string, result *shell.Result) {
+	if s.Audit != nil {
+		s.Audit(ctx, command, source, decision, result)
+	}
+}
@@ -0,0 +1,248 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/diiviikk5/dvkcli/internal/mcp"
+)
+
+// Tool is a function the model can call
+type Tool interface {
+	// Name identifies the tool in the model's tool list
+	Name() string
+	// Description tells the model when to use the tool
+	Description() string
+	// Schema is the JSON schema of the arguments object
+	Schema() json.RawMessage
+	// Call runs the tool and returns text for the model
+	Call(ctx context.Context, args map[string]any) (string, error)
+}
+
+// Handler implements a tool's behaviour
+type Handler func(ctx context.Context, args map[string]any) (string, error)
+
+// funcTool adapts a Handler into a Tool
+type funcTool struct {
+	name        string
+	description string
+	schema      json.RawMessage
+	handler     Handler
+}
+
+// New creates a tool from a name, description, JSON schema and handler
+func New(name, description, schema string, handler Handler) Tool {
+	return &funcTool{
+		name:        name,
+		description: description,
+		schema:      json.RawMessage(schema),
+		handler:     handler,
+	}
+}
+
+func (t *funcTool) Name() string            { return t.name }
+func (t *funcTool) Description() string     { return t.description }
+func (t *funcTool) Schema() json.RawMessage { return t.schema }
+
+func (t *funcTool) Call(ctx context.Context, args map[string]any) (string, error) {
+	return t.handler(ctx, args)
+}
+
+// Registry holds the tools offered to the model
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{tools: make(map[string]Tool)}
+}
+
+// Register adds a tool, failing if the name is taken or the schema is invalid
+func (r *Registry) Register(t Tool) error {
+	if _, err := parameters(t); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tools[t.Name()]; exists {
+		return fmt.Errorf("tool %q is already registered", t.Name())
+	}
+	r.tools[t.Name()] = t
+	return nil
+}
+
+// Unregister removes a tool by name
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tools, name)
+}
+
+// Get returns a tool by name
+func (r *Registry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tools[name]
+	return t, ok
+}
+
+// List returns all tools sorted by name
+func (r *Registry) List() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]Tool, 0, len(r.tools))
+	for _, t := range r.tools {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+// Len returns the number of registered tools
+func (r *Registry) Len() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.tools)
+}
+
+// Definition describes a tool to the model
+type Definition struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage // JSON schema of the arguments object
+}
+
+// Definitions returns the tools to offer the model. Tools with an invalid
+// schema are left out.
+func (r *Registry) Definitions() []Definition {
+	var defs []Definition
+	for _, t := range r.List() {
+		params, err := parameters(t)
+		if err != nil {
+			continue
+		}
+		defs = append(defs, Definition{Name: t.Name(), Description: t.Description(), Parameters: params})
+	}
+	return defs
+}
+
+// Call runs the named tool
+func (r *Registry) Call(ctx context.Context, name string, args map[string]any) (string, error) {
+	t, ok := r.Get(name)
+	if !ok {
+		return "", fmt.Errorf("unknown tool %q", name)
+	}
+	if args == nil {
+		args = map[string]any{}
+	}
+	return t.Call(ctx, args)
+}
+
+// parameters checks a tool's JSON schema, filling in the object type and
+// properties that APIs require
+func parameters(t Tool) (json.RawMessage, error) {
+	var params map[string]any
+	if err := json.Unmarshal(t.Schema(), &params); err != nil {
+		return nil, fmt.Errorf("tool %q has an invalid schema: %w", t.Name(), err)
+	}
+	if params == nil {
+		return nil, fmt.Errorf("tool %q has no schema", t.Name())
+	}
+	if _, ok := params["type"]; !ok {
+		params["type"] = "object"
+	}
+	if _, ok := params["properties"]; !ok {
+		params["properties"] = map[string]any{}
+	}
+	return json.Marshal(params)
+}
+
+// String returns a required string argument
+func String(args map[string]any, key string) (string, error) {
+	v, ok := args[key]
+	if !ok {
+		return "", fmt.Errorf("missing required argument %q", key)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("argument %q must be a string", key)
+	}
+	return s, nil
+}
+
+// OptionalString returns a string argument or def when it is absent
+func OptionalString(args map[string]any, key, def string) string {
+	if s, ok := args[key].(string); ok && s != "" {
+		return s
+	}
+	return def
+}
+
+// Int returns an integer argument or def when it is absent. Models send
+// numbers as JSON numbers or occasionally as strings.
+func Int(args map[string]any, key string, def int) int {
+	switch v := args[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case string:
+		var n int
+		if _, err := fmt.Sscanf(strings.TrimSpace(v), "%d", &n); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+// Bool returns a boolean argument or def when it is absent
+func Bool(args map[string]any, key string, def bool) bool {
+	switch v := args[key].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return def
+}
+
+// FormatArgs renders arguments compactly for display, e.g. path="a.go", n=3
+func FormatArgs(args map[string]any) string {
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v, err := json.Marshal(args[k])
+		if err != nil {
+			v = []byte(fmt.Sprint(args[k]))
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s", k, v))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Toolbox groups the registry with the built-in tools that also back slash
+// commands such as /run and /undo, and the MCP servers whose tools are
+// bridged into the registry
+type Toolbox struct {
+	Registry *Registry
+	Shell    *Shell
+	Editor   *Editor
+	MCP      *mcp.Manager
+}
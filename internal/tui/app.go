@@ -2,8 +2,10 @@ package tui
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -12,11 +14,15 @@ import (
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/diiviikk5/dvkcli/internal/attach"
+	"github.com/diiviikk5/dvkcli/internal/chat"
 	"github.com/diiviikk5/dvkcli/internal/config"
+	"github.com/diiviikk5/dvkcli/internal/history"
 	"github.com/diiviikk5/dvkcli/internal/memory"
-	"github.com/diiviikk5/dvkcli/internal/ollama"
+	"github.com/diiviikk5/dvkcli/internal/plugin"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+	"github.com/diiviikk5/dvkcli/internal/tools"
 	"github.com/google/uuid"
-	ollamaapi "github.com/ollama/ollama/api"
 )
 
 // Message roles
@@ -24,34 +30,115 @@ const (
 	RoleUser      = "user"
 	RoleAssistant = "assistant"
 	RoleSystem    = "system"
+	RoleTool      = "tool"
 )
 
 // ChatMessage represents a message in the chat
 type ChatMessage struct {
-	Role    string
-	Content string
-	Time    time.Time
+	ID       string
+	ParentID string
+	Role     string
+	Content  string
+	Time     time.Time
+
+	// Attachments are sent to the model after Content and shown as chips
+	Attachments []attach.Attachment
+
+	// Transient marks UI-only output such as command results, which is
+	// never sent to the model or saved to memory
+	Transient bool
+	// Failed marks a transient message reporting that a reply failed
+	Failed bool
+
+	// Tool is set on tool-role messages recording a call the model made,
+	// which are collapsed to one line unless Expanded
+	Tool     *provider.ToolCall
+	Expanded bool
+
+	// Thinking is the reasoning a model did before its reply, shown as a
+	// collapsed block unless ThinkingShown
+	Thinking      string
+	ThinkingShown bool
+
+	// Backend and model that wrote an assistant reply, and what it used
+	Backend string
+	Model   string
+	Usage   provider.Usage
+
+	saved bool
 }
 
 // Model is the main Bubbletea model
 type Model struct {
 	// Core components
-	client *ollama.Client
-	store  *memory.Store
-	cfg    *config.Config
+	backends *provider.Backends
+	backend  string // name of the active backend
+	client   provider.Provider
+	store    *memory.Store
+	cfg      *config.Config
+	tools    *tools.Toolbox
+	engine   *chat.Engine
 
 	// UI components
 	textarea textarea.Model
 	viewport viewport.Model
 	spinner  spinner.Model
 
+	// Input focus and prompt history
+	focus       focusArea
+	history     *history.History
+	searching   bool
+	searchQuery string
+	searchMatch int
+	searchDraft string
+	historyErr  error // first failure to save history, shown until the next prompt
+	historyWarn bool  // a history failure has been shown, so later ones aren't
+
+	// Message selection, editing and branches
+	selecting bool
+	selected  int
+	selection string // a selected message's text, kept for the command typed next
+	editing   *ChatMessage
+	branches  map[string][]string
+
+	// Command waiting for the user's approval, and file change under review
+	approval *approvalMsg
+	review   *reviewState
+
+	// Model download in progress, configured models offered for download
+	// and the model /rm asks about removing
+	pull         *pullState
+	pullPrompt   []string
+	pullPromptAt time.Time
+	removePrompt string
+
+	// Attachments waiting for the next message
+	loader  *attach.Loader
+	pending []attach.Attachment
+
+	// Slash commands, and the popup completing them in the input
+	commands   *commandRegistry
+	completion completer
+
 	// State
 	messages       []ChatMessage
 	conversationID string
 	streaming      bool
 	streamContent  string
-	connected      bool
 	memoryCount    int
+	lastUsage      provider.Usage // of the latest reply, for the status bar
+
+	// JSON schema replies must follow, or provider.FormatJSON; nil for text
+	format     json.RawMessage
+	formatName string
+
+	// Backend health, checked periodically
+	connected   bool
+	lost        bool // the connection dropped and hasn't come back yet
+	ps          bool // the backend reports loaded models in running
+	running     []provider.RunningModel
+	healthDelay time.Duration
+	nextCheck   time.Time
 
 	// Layout
 	width  int
@@ -71,14 +158,18 @@ type (
 	streamChunkMsg  string
 	streamDoneMsg   struct{}
 	streamErrorMsg  error
-	streamResultMsg struct{ content string }
-	connectionMsg   bool
-	memoryCountMsg  int
-	tickMsg         time.Time
+	streamResultMsg struct {
+		content, parentID, backend, model string
+		thinking                          string
+		usage                             provider.Usage
+		err                               error
+	}
+	memoryCountMsg int
+	tickMsg        time.Time
 )
 
-// New creates a new TUI model
-func New(client *ollama.Client, store *memory.Store, cfg *config.Config) *Model {
+// New creates a new TUI model talking to the active backend
+func New(backends *provider.Backends, store *memory.Store, cfg *config.Config, toolbox *tools.Toolbox, plugins []plugin.Plugin) *Model {
 	ta := textarea.New()
 	ta.Placeholder = "Type your message..."
 	ta.Focus()
@@ -93,22 +184,52 @@ func New(client *ollama.Client, store *memory.Store, cfg *config.Config) *Model
 	s.Spinner = spinner.Dot
 	s.Style = SpinnerStyle
 
-	return &Model{
+	// Attachments are resolved relative to the working directory
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "."
+	}
+
+	// Load prompt history, keeping it in memory only if the file is unusable
+	hist := history.New("")
+	if historyPath, err := config.GetHistoryPath(); err == nil {
+		if loaded, err := history.Load(historyPath); err == nil {
+			hist = loaded
+		}
+	}
+
+	backend, _ := backends.Active()
+	client := backends.Chain(backend)
+	engine := chat.NewEngine(client, store, cfg.SystemPrompt, cfg.Personas, cfg.ContextLimit)
+	engine.Backend = backend
+
+	m := &Model{
+		backends:       backends,
+		backend:        backend,
 		client:         client,
 		store:          store,
 		cfg:            cfg,
+		tools:          toolbox,
+		engine:         engine,
 		textarea:       ta,
 		spinner:        s,
+		history:        hist,
+		loader:         attach.NewLoader(cwd),
 		messages:       []ChatMessage{},
 		conversationID: uuid.New().String(),
+		commands:       newCommandRegistry(),
 	}
+	m.registerPlugins(plugins)
+	m.refreshTemplateCommands()
+	return m
 }
 
 // Init initializes the model
 func (m *Model) Init() tea.Cmd {
 	return tea.Batch(
 		textarea.Blink,
-		m.checkConnection(),
+		m.checkStatus(true),
+		m.checkModels(),
 		m.loadMemoryCount(),
 		m.tickCmd(),
 	)
@@ -120,67 +241,12 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c":
-			return m, tea.Quit
-		case "enter":
-			if !m.streaming && strings.TrimSpace(m.textarea.Value()) != "" {
-				input := strings.TrimSpace(m.textarea.Value())
-				// Handle special commands
-				if strings.HasPrefix(input, "/") {
-					return m, m.handleCommand(input)
-				}
-				return m, m.sendMessage()
-			}
-		case "ctrl+n":
-			// New conversation
-			m.messages = []ChatMessage{}
-			m.conversationID = uuid.New().String()
-			m.textarea.Reset()
-			m.viewport.SetContent(m.renderMessages())
-			return m, nil
-		case "ctrl+l":
-			// Load last conversation
-			return m, m.loadLastConversation()
-		case "ctrl+e":
-			// Export conversation
-			return m, m.exportConversation()
-		case "up", "k":
-			// Scroll up
-			m.viewport.LineUp(3)
-			return m, nil
-		case "down", "j":
-			// Scroll down
-			m.viewport.LineDown(3)
-			return m, nil
-		case "pgup":
-			m.viewport.HalfViewUp()
-			return m, nil
-		case "pgdown":
-			m.viewport.HalfViewDown()
-			return m, nil
-		}
+		return m.handleKey(msg)
 
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-
-		headerHeight := 4
-		inputHeight := 5
-		statusHeight := 1
-		viewportHeight := m.height - headerHeight - inputHeight - statusHeight - 2
-
-		if !m.ready {
-			m.viewport = viewport.New(m.width-4, viewportHeight)
-			m.viewport.HighPerformanceRendering = false
-			m.ready = true
-		} else {
-			m.viewport.Width = m.width - 4
-			m.viewport.Height = viewportHeight
-		}
-
-		m.textarea.SetWidth(m.width - 6)
-		m.viewport.SetContent(m.renderMessages())
+		m.resize()
 
 	case streamChunkMsg:
 		m.streamContent += string(msg)
@@ -192,9 +258,11 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.streaming = false
 		// Save the complete assistant message
 		m.messages = append(m.messages, ChatMessage{
-			Role:    RoleAssistant,
-			Content: m.streamContent,
-			Time:    time.Now(),
+			ID:       uuid.New().String(),
+			ParentID: m.lastID(),
+			Role:     RoleAssistant,
+			Content:  m.streamContent,
+			Time:     time.Now(),
 		})
 		m.streamContent = ""
 		m.viewport.SetContent(m.renderMessages())
@@ -208,52 +276,124 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case streamResultMsg:
 		m.streaming = false
+		if msg.err != nil {
+			m.addError(msg.err, msg.model)
+			return m, m.checkConnection()
+		}
 		// Save the complete assistant message
 		m.messages = append(m.messages, ChatMessage{
-			Role:    RoleAssistant,
-			Content: msg.content,
-			Time:    time.Now(),
+			ID:       uuid.New().String(),
+			ParentID: msg.parentID,
+			Role:     RoleAssistant,
+			Content:  msg.content,
+			Thinking: msg.thinking,
+			Time:     time.Now(),
+			Backend:  msg.backend,
+			Model:    msg.model,
+			Usage:    msg.usage,
 		})
+		m.lastUsage = msg.usage
 		m.viewport.SetContent(m.renderMessages())
 		m.viewport.GotoBottom()
-		return m, m.saveToMemory()
+		// The reply loaded the model, so its status has changed
+		return m, tea.Batch(m.saveToMemory(), m.checkConnection())
+
+	case toolCallMsg:
+		m.addToolCall(msg)
+		return m, waitForEvent(msg.events)
+
+	case fallbackMsg:
+		f := msg.fallback
+		m.addNotice(fmt.Sprintf("⚠ %s failed: %v\nFalling back to %s.", f.From, f.Err, f.To))
+		return m, waitForEvent(msg.events)
+
+	case approvalMsg:
+		m.approval = &msg
+		m.resize()
+		m.viewport.GotoBottom()
+		return m, waitForEvent(msg.events)
+
+	case pullProgressMsg:
+		m.updatePull(msg)
+		return m, waitForEvent(msg.events)
+
+	case pullDoneMsg:
+		return m, m.finishPull(msg)
+
+	case missingModelsMsg:
+		m.openPullPrompt(msg)
+		return m, nil
+
+	case commandOutputMsg:
+		m.showCommandOutput(msg)
+		return m, nil
+
+	case reviewMsg:
+		m.startReview(msg)
+		return m, waitForEvent(msg.events)
+
+	case reviewEditedMsg:
+		m.applyReviewEdit(msg)
+		return m, nil
+
+	case pluginResultMsg:
+		return m, m.applyPluginResult(msg)
+
+	case argValuesMsg:
+		m.showArgValues(msg)
+		return m, nil
 
 	case commandResultMsg:
 		// Show command result as assistant message
-		m.messages = append(m.messages, ChatMessage{
-			Role:    RoleAssistant,
-			Content: msg.content,
-			Time:    time.Now(),
-		})
-		m.viewport.SetContent(m.renderMessages())
-		m.viewport.GotoBottom()
+		m.addNotice(msg.content)
 		return m, nil
 
 	case loadConversationMsg:
 		// Load messages from conversation
 		if msg.conversation != nil && len(msg.conversation.Messages) > 0 {
 			m.conversationID = msg.conversation.ID
-			m.messages = []ChatMessage{}
-			for _, memMsg := range msg.conversation.Messages {
-				m.messages = append(m.messages, ChatMessage{
-					Role:    memMsg.Role,
-					Content: memMsg.Content,
-					Time:    memMsg.CreatedAt,
-				})
-			}
+			m.messages = fromMemory(msg.conversation.Messages)
+			m.editing = nil
 			m.viewport.SetContent(m.renderMessages())
 			m.viewport.GotoBottom()
+			return m, tea.Batch(m.loadBranches(), m.followBackend())
 		}
 		return m, nil
 
-	case connectionMsg:
-		m.connected = bool(msg)
+	case branchSwitchMsg:
+		m.applyBranchSwitch(msg)
 		return m, nil
 
+	case branchesMsg:
+		m.branches = msg
+		m.viewport.SetContent(m.renderMessages())
+		return m, nil
+
+	case healthTickMsg:
+		return m, m.checkStatus(true)
+
+	case statusMsg:
+		return m, m.applyStatus(msg)
+
 	case memoryCountMsg:
 		m.memoryCount = int(msg)
 		return m, nil
 
+	case memorySavedMsg:
+		m.memoryCount = msg.count
+		m.branches = msg.branches
+		if msg.err != nil {
+			// Messages that weren't stored are saved again with the next ones
+			for i := range m.messages {
+				if slices.Contains(msg.unsaved, m.messages[i].ID) {
+					m.messages[i].saved = false
+				}
+			}
+			m.addNotice(fmt.Sprintf("Error: failed to save to memory: %v\nThe unsaved messages will be saved again with the next one.", msg.err))
+		}
+		m.viewport.SetContent(m.renderMessages())
+		return m, nil
+
 	case tickMsg:
 		if m.streaming {
 			m.typingFrame = (m.typingFrame + 1) % len(TypingFrames)
@@ -267,14 +407,14 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, cmd
 	}
 
-	// Update textarea
+	// Update textarea (key presses are routed by handleKey)
 	if !m.streaming {
 		var cmd tea.Cmd
 		m.textarea, cmd = m.textarea.Update(msg)
 		cmds = append(cmds, cmd)
 	}
 
-	// Update viewport
+	// Update viewport (mouse wheel scrolling)
 	var cmd tea.Cmd
 	m.viewport, cmd = m.viewport.Update(msg)
 	cmds = append(cmds, cmd)
@@ -295,19 +435,45 @@ func (m *Model) View() string {
 	b.WriteString(header)
 	b.WriteString("\n")
 
-	// Chat viewport
+	// Chat viewport, or the diff under review
+	chatView := m.viewport.View()
+	if m.review != nil {
+		chatView = m.review.view.View()
+	}
 	chatBox := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
 		BorderForeground(Subtle).
 		Width(m.width - 2).
-		Render(m.viewport.View())
+		Render(chatView)
 	b.WriteString(chatBox)
 	b.WriteString("\n")
 
-	// Input area
+	// Attachments waiting for the next message
+	if len(m.pending) > 0 {
+		b.WriteString(renderChips(m.pending, m.width))
+		b.WriteString("\n")
+	}
+
+	// Completion popup for a command being typed
+	if m.completionHeight() > 0 {
+		b.WriteString(m.renderCompletion())
+		b.WriteString("\n")
+	}
+
+	// Input area, replaced by the approval modal while a command waits
 	inputBox := InputStyle.
 		Width(m.width - 4).
 		Render(m.textarea.View())
+	switch {
+	case m.approval != nil:
+		inputBox = m.renderApproval()
+	case m.review != nil:
+		inputBox = m.renderReviewHelp()
+	case m.pullPrompt != nil:
+		inputBox = m.renderPullPrompt()
+	case m.removePrompt != "":
+		inputBox = m.renderRemovePrompt()
+	}
 	b.WriteString(inputBox)
 	b.WriteString("\n")
 
@@ -317,11 +483,47 @@ func (m *Model) View() string {
 	return b.String()
 }
 
+// resize lays out the components for the current window size
+func (m *Model) resize() {
+	if m.width == 0 {
+		return
+	}
+
+	headerHeight := 4
+	inputHeight := 5
+	statusHeight := 1
+	chipsHeight := 0
+	if len(m.pending) > 0 {
+		chipsHeight = 1
+	}
+	if m.approval != nil {
+		// The approval modal grows to show the whole command
+		inputHeight = max(inputHeight, lipgloss.Height(m.renderApproval()))
+	}
+	viewportHeight := max(m.height-headerHeight-inputHeight-statusHeight-chipsHeight-m.completionHeight()-2, 1)
+
+	if !m.ready {
+		m.viewport = viewport.New(m.width-4, viewportHeight)
+		m.viewport.HighPerformanceRendering = false
+		m.ready = true
+	} else {
+		m.viewport.Width = m.width - 4
+		m.viewport.Height = viewportHeight
+	}
+	if m.review != nil {
+		m.review.view.Width = m.viewport.Width
+		m.review.view.Height = m.viewport.Height
+	}
+
+	m.textarea.SetWidth(m.width - 6)
+	m.viewport.SetContent(m.renderMessages())
+}
+
 // renderHeader renders the header with logo and status
 func (m *Model) renderHeader() string {
 	logo := RenderCompactLogo()
 
-	modelStatus := fmt.Sprintf("%s %s", ModelIcon(m.connected), m.client.Model)
+	modelStatus := fmt.Sprintf("%s %s · %s", ModelIcon(m.connected), m.backend, m.client.Model())
 	modelStyled := lipgloss.NewStyle().Foreground(Muted).Render(modelStatus)
 
 	memoryStatus := ""
@@ -356,8 +558,12 @@ func (m *Model) renderMessages() string {
 	b.WriteString(logo + "\n" + tagline + "\n\n")
 
 	// Show messages if any
-	for _, msg := range m.messages {
-		b.WriteString(m.renderMessage(msg))
+	for i, msg := range m.messages {
+		rendered := m.renderMessage(msg)
+		if m.selecting && i == m.selected {
+			rendered = SelectedMessageStyle.Render(rendered)
+		}
+		b.WriteString(rendered)
 		b.WriteString("\n\n")
 	}
 
@@ -372,11 +578,22 @@ func (m *Model) renderMessages() string {
 			Render(fmt.Sprintf("  %s your slave is thinking...", indicator)))
 	}
 
+	if m.pull != nil {
+		if m.streaming {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(m.renderPull())
+	}
+
 	return b.String()
 }
 
 // renderMessage renders a single message
 func (m *Model) renderMessage(msg ChatMessage) string {
+	if msg.Tool != nil {
+		return m.renderToolMessage(msg)
+	}
+
 	var prefix string
 	var style lipgloss.Style
 
@@ -391,11 +608,30 @@ func (m *Model) renderMessage(msg ChatMessage) string {
 		prefix = "◇ System"
 		style = SystemMessageStyle
 	}
+	if msg.Failed {
+		prefix = "✗ Error"
+		style = ErrorMessageStyle
+	}
 
 	header := lipgloss.NewStyle().Bold(true).Foreground(style.GetForeground()).Render(prefix)
 	timestamp := lipgloss.NewStyle().Foreground(Subtle).Render(msg.Time.Format("15:04"))
+	if msg.Model != "" {
+		timestamp += " " + lipgloss.NewStyle().Foreground(Subtle).Render(msg.Backend+" · "+msg.Model)
+	}
+	if branch := m.branchLabel(msg); branch != "" {
+		timestamp += " " + BranchStyle.Render(branch)
+	}
 
 	content := style.Render(msg.Content)
+	if msg.Thinking != "" {
+		content = renderThinking(msg) + "\n" + content
+	}
+	if len(msg.Attachments) > 0 {
+		content = renderChips(msg.Attachments, m.viewport.Width) + "\n" + content
+	}
+	if stats := formatUsage(msg.Usage); stats != "" {
+		content += "\n" + UsageStyle.Render(stats)
+	}
 
 	return fmt.Sprintf("%s %s\n%s", header, timestamp, content)
 }
@@ -411,17 +647,73 @@ func (m *Model) renderStreamingMessage() string {
 
 // renderStatusBar renders the bottom status bar
 func (m *Model) renderStatusBar() string {
+	if m.searching {
+		return m.renderSearchBar()
+	}
+
 	// Left side: help
 	help := HelpStyle.Render("Enter ") + HelpKeyStyle.Render("send") +
 		HelpStyle.Render(" • /help ") + HelpKeyStyle.Render("cmds") +
-		HelpStyle.Render(" • Ctrl+C ") + HelpKeyStyle.Render("quit")
+		HelpStyle.Render(" • Ctrl+R ") + HelpKeyStyle.Render("history")
+	switch {
+	case m.approval != nil:
+		help = HelpStyle.Render("Approve command? y ") + HelpKeyStyle.Render("run") +
+			HelpStyle.Render(" • n ") + HelpKeyStyle.Render("deny") +
+			HelpStyle.Render(" • a ") + HelpKeyStyle.Render("always")
+	case m.pullPrompt != nil:
+		help = HelpStyle.Render("Pull missing models? y ") + HelpKeyStyle.Render("pull") +
+			HelpStyle.Render(" • n ") + HelpKeyStyle.Render("skip")
+	case m.removePrompt != "":
+		help = HelpStyle.Render("Remove model? y ") + HelpKeyStyle.Render("remove") +
+			HelpStyle.Render(" • n ") + HelpKeyStyle.Render("keep")
+	case m.review != nil:
+		help = HelpStyle.Render("Review change • y ") + HelpKeyStyle.Render("accept") +
+			HelpStyle.Render(" • n ") + HelpKeyStyle.Render("reject") +
+			HelpStyle.Render(" • e ") + HelpKeyStyle.Render("edit")
+	case m.selecting:
+		help = HelpStyle.Render("↑/↓ ") + HelpKeyStyle.Render("select") +
+			HelpStyle.Render(" • e ") + HelpKeyStyle.Render("edit") +
+			HelpStyle.Render(" • r ") + HelpKeyStyle.Render("retry") +
+			HelpStyle.Render(" • f ") + HelpKeyStyle.Render("fork") +
+			HelpStyle.Render(" • ←/→ ") + HelpKeyStyle.Render("branch") +
+			HelpStyle.Render(" • / ") + HelpKeyStyle.Render("command") +
+			HelpStyle.Render(" • Esc ") + HelpKeyStyle.Render("done")
+		if m.selected < len(m.messages) && m.messages[m.selected].Thinking != "" {
+			help = HelpStyle.Render("↑/↓ ") + HelpKeyStyle.Render("select") +
+				HelpStyle.Render(" • o/Space ") + HelpKeyStyle.Render("thinking") +
+				HelpStyle.Render(" • r ") + HelpKeyStyle.Render("retry") +
+				HelpStyle.Render(" • f ") + HelpKeyStyle.Render("fork") +
+				HelpStyle.Render(" • ←/→ ") + HelpKeyStyle.Render("branch") +
+				HelpStyle.Render(" • Esc ") + HelpKeyStyle.Render("done")
+		}
+		if m.selected < len(m.messages) && m.messages[m.selected].Tool != nil {
+			help = HelpStyle.Render("↑/↓ ") + HelpKeyStyle.Render("select") +
+				HelpStyle.Render(" • o/Space ") + HelpKeyStyle.Render("expand") +
+				HelpStyle.Render(" • Esc ") + HelpKeyStyle.Render("done")
+		}
+	case m.editing != nil:
+		help = HelpStyle.Render("Editing • Enter ") + HelpKeyStyle.Render("resend") +
+			HelpStyle.Render(" • Esc ") + HelpKeyStyle.Render("cancel")
+	case m.pull != nil:
+		help = HelpStyle.Render("Pulling "+m.pull.model+" • Esc ") + HelpKeyStyle.Render("cancel")
+	case m.focus == focusChat:
+		help = HelpStyle.Render("j/k ") + HelpKeyStyle.Render("scroll") +
+			HelpStyle.Render(" • Esc ") + HelpKeyStyle.Render("back to input")
+	case m.historyErr != nil:
+		help = ErrorMessageStyle.Render(truncate("⚠ History not saved: "+m.historyErr.Error(), max(m.width/2, 20)))
+	}
+	if m.pull == nil {
+		help += HelpStyle.Render(" • Ctrl+C ") + HelpKeyStyle.Render("quit")
+	}
 
-	// Right side: connection status
-	var status string
-	if m.connected {
-		status = StatusActiveStyle.Render("● connected")
-	} else {
-		status = StatusErrorStyle.Render("○ disconnected")
+	// Right side: the latest reply's stats, then connection and model status
+	status := m.renderHealth()
+	if m.format != nil {
+		status = HelpStyle.Render("{} "+m.formatName+" │ ") + status
+	}
+	if stats := HelpStyle.Render(formatUsage(m.lastUsage) + " │ "); m.lastUsage.CompletionTokens > 0 &&
+		lipgloss.Width(help)+lipgloss.Width(stats)+lipgloss.Width(status)+4 <= m.width {
+		status = stats + status
 	}
 
 	// Calculate spacing
@@ -433,76 +725,191 @@ func (m *Model) renderStatusBar() string {
 	return StatusBarStyle.Width(m.width).Render(help + strings.Repeat(" ", spaces) + status)
 }
 
-// sendMessage sends the current input to Ollama
+// sendMessage sends the current input to the model
 func (m *Model) sendMessage() tea.Cmd {
 	content := strings.TrimSpace(m.textarea.Value())
 	if content == "" {
 		return nil
 	}
 
+	// Resending an edited message starts a new branch beside the original,
+	// keeping its attachments
+	parentID := m.lastID()
+	var kept []attach.Attachment
+	if m.editing != nil {
+		parentID = m.editing.ParentID
+		kept = m.editing.Attachments
+		m.truncateAt(m.editing.ID)
+		m.editing = nil
+	}
+
 	// Add user message
+	atts := mergeAttachments(kept, m.takeAttachments(content))
 	m.messages = append(m.messages, ChatMessage{
-		Role:    RoleUser,
-		Content: content,
-		Time:    time.Now(),
+		ID:          uuid.New().String(),
+		ParentID:    parentID,
+		Role:        RoleUser,
+		Content:     content,
+		Time:        time.Now(),
+		Attachments: atts,
 	})
 
 	m.textarea.Reset()
+	if attach.HasImages(atts) {
+		return tea.Batch(m.requestResponse(provider.ChatOptions{}), m.checkVision())
+	}
+	return m.requestResponse(provider.ChatOptions{})
+}
+
+// requestResponse asks the model to reply to the current conversation
+func (m *Model) requestResponse(opts provider.ChatOptions) tea.Cmd {
+	return m.requestResponseAs(m.cfg.SystemPrompt, opts)
+}
+
+// requestResponseAs asks for a reply under another system prompt
+func (m *Model) requestResponseAs(system string, opts provider.ChatOptions) tea.Cmd {
 	m.streaming = true
 	m.streamContent = ""
 	m.viewport.SetContent(m.renderMessages())
 	m.viewport.GotoBottom()
 
-	return m.streamResponse(content)
+	// Build the request here so the command never reads m.messages
+	messages := m.buildChatMessages(system)
+	parentID := m.lastID()
+	if m.cfg.ToolsEnabled {
+		opts.Tools = m.tools.Registry
+	}
+	opts.Format = m.format
+
+	return m.streamResponse(messages, parentID, opts)
 }
 
-// streamResponse gets the response from Ollama (non-streaming for reliability)
-func (m *Model) streamResponse(prompt string) tea.Cmd {
-	return func() tea.Msg {
-		// Use a timeout context
-		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-		defer cancel()
+// buildChatMessages converts the conversation into the model's message format
+func (m *Model) buildChatMessages(system string) []provider.Message {
+	var turns []chat.Turn
+	for _, msg := range m.messages {
+		if msg.Transient {
+			continue
+		}
+		turns = append(turns, chat.Turn{Role: msg.Role, Content: msg.Content, Attachments: msg.Attachments, Tool: msg.Tool})
+	}
+	return m.engine.BuildMessages(system, turns)
+}
 
-		// Build messages for context
-		messages := []ollamaapi.Message{}
+// streamResponse gets the response from the provider (non-streaming for reliability).
+// Tool calls made along the way arrive as toolCallMsg before the result.
+func (m *Model) streamResponse(messages []provider.Message, parentID string, opts provider.ChatOptions) tea.Cmd {
+	// Switching backends mid-reply must not change who answers
+	client, backend := m.client, m.backend
+	if opts.Model == "" {
+		opts.Model = client.Model()
+	}
+	model := opts.Model
+
+	events := make(chan tea.Msg)
+	// Tool calls are kept in the branch between the prompt and the reply
+	opts.OnToolCall = func(call provider.ToolCall) {
+		id := uuid.New().String()
+		events <- toolCallMsg{call: call, id: id, parentID: parentID, events: events}
+		parentID = id
+	}
+	// The reply is credited to whichever backend ends up answering
+	opts.OnFallback = func(fallback provider.Fallback) {
+		backend, model = fallback.To.Backend, fallback.To.Model
+		events <- fallbackMsg{fallback: fallback, events: events}
+	}
+	var usage provider.Usage
+	opts.OnUsage = func(u provider.Usage) {
+		usage = u
+	}
+	// Each round of tool calls can think again
+	var thinking []string
+	opts.OnThinking = func(text string) {
+		thinking = append(thinking, strings.TrimSpace(text))
+	}
 
-		// Add system prompt
-		if m.cfg.SystemPrompt != "" {
-			messages = append(messages, ollamaapi.Message{
-				Role:    "system",
-				Content: m.cfg.SystemPrompt,
-			})
-		}
+	go func() {
+		defer close(events)
 
-		// Add conversation history
-		for _, msg := range m.messages {
-			messages = append(messages, ollamaapi.Message{
-				Role:    msg.Role,
-				Content: msg.Content,
-			})
-		}
+		// Use a timeout context, long enough for tool calls awaiting approval
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+		ctx = tools.WithApprover(ctx, approver(events))
+		ctx = tools.WithReviewer(ctx, reviewer(events))
 
 		// Use non-streaming Chat for reliability
-		response, err := m.client.Chat(ctx, messages)
-		if err != nil {
-			return streamResultMsg{content: fmt.Sprintf("Error: %v", err)}
+		var response string
+		var err error
+		if opts.Format != nil {
+			response, err = chat.ChatJSON(ctx, client, messages, opts)
+		} else {
+			response, err = client.Chat(ctx, messages, opts)
 		}
-
-		if response == "" {
-			return streamResultMsg{content: "No response from AI. The model might be loading..."}
+		if err == nil && response == "" {
+			err = errEmptyReply
 		}
+		result := streamResultMsg{content: response, parentID: parentID, backend: backend, model: model,
+			thinking: strings.Join(thinking, "\n\n"), usage: usage, err: err}
+		events <- result
+	}()
 
-		return streamResultMsg{content: response}
+	return waitForEvent(events)
+}
+
+// lastID returns the ID of the last message that belongs to the conversation
+func (m *Model) lastID() string {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if !m.messages[i].Transient {
+			return m.messages[i].ID
+		}
 	}
+	return ""
 }
 
-// checkConnection checks if Ollama is connected
-func (m *Model) checkConnection() tea.Cmd {
-	return func() tea.Msg {
-		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-		defer cancel()
-		return connectionMsg(m.client.IsConnected(ctx))
+// addNotice shows UI-only output in the chat
+func (m *Model) addNotice(content string) {
+	m.messages = append(m.messages, ChatMessage{
+		Role:      RoleAssistant,
+		Content:   content,
+		Time:      time.Now(),
+		Transient: true,
+	})
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+}
+
+// fromMemory converts stored messages into chat messages
+func fromMemory(msgs []memory.Message) []ChatMessage {
+	out := make([]ChatMessage, 0, len(msgs))
+	for _, memMsg := range msgs {
+		if memMsg.ToolName != "" {
+			call := chat.ToolCallFromMemory(memMsg)
+			out = append(out, ChatMessage{
+				ID:       memMsg.ID,
+				ParentID: memMsg.ParentID,
+				Role:     memMsg.Role,
+				Content:  memMsg.Content,
+				Time:     memMsg.CreatedAt,
+				Tool:     &call,
+				saved:    true,
+			})
+			continue
+		}
+		out = append(out, ChatMessage{
+			ID:          memMsg.ID,
+			ParentID:    memMsg.ParentID,
+			Role:        memMsg.Role,
+			Content:     memMsg.Content,
+			Thinking:    memMsg.Thinking,
+			Time:        memMsg.CreatedAt,
+			Attachments: fromMemoryAttachments(memMsg.Attachments),
+			Backend:     memMsg.Backend,
+			Model:       memMsg.Model,
+			Usage:       chat.UsageFromMemory(memMsg),
+			saved:       true,
+		})
 	}
+	return out
 }
 
 // loadMemoryCount loads the memory count from store
@@ -516,52 +923,59 @@ func (m *Model) loadMemoryCount() tea.Cmd {
 	}
 }
 
-// saveToMemory saves the last exchange to memory
+// saveToMemory saves messages that haven't been persisted yet
 func (m *Model) saveToMemory() tea.Cmd {
-	return func() tea.Msg {
-		if m.store == nil || !m.cfg.MemoryEnabled {
-			return nil
+	if m.store == nil || !m.cfg.MemoryEnabled {
+		return nil
+	}
+
+	// Collect pending messages here so the command never reads m.messages
+	var pending []ChatMessage
+	for i := range m.messages {
+		msg := &m.messages[i]
+		if !msg.Transient && !msg.saved {
+			msg.saved = true
+			pending = append(pending, *msg)
 		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	title := m.conversationTitle()
+	conversationID := m.conversationID
 
+	return func() tea.Msg {
 		ctx := context.Background()
 
-		// Ensure conversation exists
-		conv, _ := m.store.GetConversation(ctx, m.conversationID)
-		if conv == nil {
-			title := "New Chat"
-			if len(m.messages) > 0 {
-				title = truncate(m.messages[0].Content, 50)
+		stored := make([]memory.Message, 0, len(pending))
+		for _, msg := range pending {
+			if msg.Tool != nil {
+				stored = append(stored, memory.Message{ID: msg.ID, ParentID: msg.ParentID, Role: msg.Role, CreatedAt: msg.Time})
+				chat.SetToolCall(&stored[len(stored)-1], *msg.Tool)
+				continue
 			}
-			m.store.CreateConversation(ctx, m.conversationID, title)
+			stored = append(stored, memory.Message{
+				ID:          msg.ID,
+				ParentID:    msg.ParentID,
+				Role:        msg.Role,
+				Content:     msg.Content,
+				Thinking:    msg.Thinking,
+				CreatedAt:   msg.Time,
+				Attachments: toMemoryAttachments(msg.Attachments),
+				Backend:     msg.Backend,
+				Model:       msg.Model,
+			})
+			chat.SetUsage(&stored[len(stored)-1], msg.Usage)
 		}
-
-		// Save last two messages (user + assistant)
-		if len(m.messages) >= 2 {
-			for i := len(m.messages) - 2; i < len(m.messages); i++ {
-				msg := m.messages[i]
-
-				// Generate embedding for user messages
-				var embedding []float32
-				if msg.Role == RoleUser {
-					embedding, _ = m.client.Embed(ctx, msg.Content)
-				}
-
-				err := m.store.SaveMessage(ctx, &memory.Message{
-					ID:             uuid.New().String(),
-					ConversationID: m.conversationID,
-					Role:           msg.Role,
-					Content:        msg.Content,
-					Embedding:      embedding,
-					CreatedAt:      msg.Time,
-				})
-				if err != nil {
-					continue
-				}
-			}
+		saved, err := m.engine.Save(ctx, conversationID, title, stored)
+		var unsaved []string
+		for _, msg := range stored[saved:] {
+			unsaved = append(unsaved, msg.ID)
 		}
 
 		count, _ := m.store.GetMessageCount(ctx)
-		return memoryCountMsg(count)
+		branches, _ := m.store.GetBranchPoints(ctx, conversationID)
+		return memorySavedMsg{count: count, branches: branches, err: err, unsaved: unsaved}
 	}
 }
 
@@ -580,78 +994,7 @@ func truncate(s string, max int) string {
 	return s[:max-3] + "..."
 }
 
-// handleCommand handles slash commands
-func (m *Model) handleCommand(input string) tea.Cmd {
-	m.textarea.Reset()
-
-	parts := strings.Fields(input)
-	if len(parts) == 0 {
-		return nil
-	}
-
-	cmd := strings.ToLower(parts[0])
-
-	switch cmd {
-	case "/help":
-		helpText := `Available commands:
-  /help     - Show this help
-  /models   - List available Ollama models
-  /search   - Search past conversations
-  /clear    - Clear current conversation
-  /export   - Export conversation to markdown
-  
-Shortcuts:
-  Enter     - Send message
-  Ctrl+N    - New conversation
-  Ctrl+L    - Load last conversation
-  Ctrl+E    - Export conversation
-  Ctrl+C    - Quit
-  ↑/↓       - Scroll`
-		m.messages = append(m.messages, ChatMessage{
-			Role:    RoleAssistant,
-			Content: helpText,
-			Time:    time.Now(),
-		})
-		m.viewport.SetContent(m.renderMessages())
-		m.viewport.GotoBottom()
-
-	case "/models":
-		return m.listModels()
-
-	case "/search":
-		if len(parts) < 2 {
-			m.messages = append(m.messages, ChatMessage{
-				Role:    RoleAssistant,
-				Content: "Usage: /search <query>",
-				Time:    time.Now(),
-			})
-		} else {
-			query := strings.Join(parts[1:], " ")
-			return m.searchMemory(query)
-		}
-		m.viewport.SetContent(m.renderMessages())
-
-	case "/clear":
-		m.messages = []ChatMessage{}
-		m.conversationID = uuid.New().String()
-		m.viewport.SetContent(m.renderMessages())
-
-	case "/export":
-		return m.exportConversation()
-
-	default:
-		m.messages = append(m.messages, ChatMessage{
-			Role:    RoleAssistant,
-			Content: fmt.Sprintf("Unknown command: %s. Type /help for available commands.", cmd),
-			Time:    time.Now(),
-		})
-		m.viewport.SetContent(m.renderMessages())
-	}
-
-	return nil
-}
-
-// listModels lists available Ollama models
+// listModels lists the models the provider offers
 func (m *Model) listModels() tea.Cmd {
 	return func() tea.Msg {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -659,19 +1002,19 @@ func (m *Model) listModels() tea.Cmd {
 
 		models, err := m.client.ListModels(ctx)
 		if err != nil {
-			return streamResultMsg{content: fmt.Sprintf("Error listing models: %v", err)}
+			return commandResultMsg{content: fmt.Sprintf("Error listing models: %v", err)}
 		}
 
 		var sb strings.Builder
 		sb.WriteString("Available models:\n\n")
 		for i, model := range models {
 			marker := "  "
-			if model.Name == m.client.Model {
+			if model.Name == m.client.Model() {
 				marker = "► "
 			}
 			sb.WriteString(fmt.Sprintf("%s%d. %s\n", marker, i+1, model.Name))
 		}
-		sb.WriteString("\nCurrent model: " + m.client.Model)
+		sb.WriteString("\nCurrent model: " + m.client.Model())
 
 		return commandResultMsg{content: sb.String()}
 	}
@@ -712,6 +1055,73 @@ func (m *Model) searchMemory(query string) tea.Cmd {
 	}
 }
 
+// newConversation clears the chat and starts a new conversation
+func (m *Model) newConversation() {
+	m.messages = []ChatMessage{}
+	m.conversationID = uuid.New().String()
+	m.editing = nil
+	m.branches = nil
+	m.textarea.Reset()
+	m.history.Reset()
+	m.viewport.SetContent(m.renderMessages())
+}
+
+// openConversation handles /open, loading the saved conversation whose title
+// or ID starts with query, or listing recent conversations without one
+func (m *Model) openConversation(query string) tea.Cmd {
+	return func() tea.Msg {
+		if m.store == nil {
+			return commandResultMsg{content: "Memory is not enabled."}
+		}
+
+		ctx := context.Background()
+		convs, err := m.store.ListConversations(ctx, 100)
+		if err != nil {
+			return commandResultMsg{content: fmt.Sprintf("Error listing conversations: %v", err)}
+		}
+		if len(convs) == 0 {
+			return commandResultMsg{content: "No saved conversations yet."}
+		}
+
+		if query == "" {
+			var sb strings.Builder
+			sb.WriteString("Recent conversations (/open <title>):\n")
+			for i, conv := range convs[:min(len(convs), 20)] {
+				sb.WriteString(fmt.Sprintf("\n%2d. %s  %s", i+1, truncate(conv.Title, 60),
+					lipgloss.NewStyle().Foreground(Subtle).Render(conv.UpdatedAt.Format("2006-01-02 15:04"))))
+			}
+			return commandResultMsg{content: sb.String()}
+		}
+
+		conv, ok := findConversation(convs, query)
+		if !ok {
+			return commandResultMsg{content: fmt.Sprintf("No conversation matches %q. Type /open to list them.", query)}
+		}
+		full, err := m.store.GetConversation(ctx, conv.ID)
+		if err != nil || full == nil {
+			return commandResultMsg{content: fmt.Sprintf("Error opening %q: %v", conv.Title, err)}
+		}
+		return loadConversationMsg{conversation: full}
+	}
+}
+
+// findConversation picks the conversation titled query, or else the most
+// recent one whose title or ID starts with it, ignoring case
+func findConversation(convs []memory.Conversation, query string) (memory.Conversation, bool) {
+	query = strings.ToLower(query)
+	for _, conv := range convs {
+		if strings.ToLower(conv.Title) == query {
+			return conv, true
+		}
+	}
+	for _, conv := range convs {
+		if strings.HasPrefix(strings.ToLower(conv.Title), query) || strings.HasPrefix(conv.ID, query) {
+			return conv, true
+		}
+	}
+	return memory.Conversation{}, false
+}
+
 // loadLastConversation loads the most recent conversation
 func (m *Model) loadLastConversation() tea.Cmd {
 	return func() tea.Msg {
@@ -731,6 +1141,8 @@ func (m *Model) loadLastConversation() tea.Cmd {
 
 // exportConversation exports the current conversation to markdown
 func (m *Model) exportConversation() tea.Cmd {
+	// Thinking is left out unless the config asks for it
+	exportThinking := m.cfg.ExportThinking
 	return func() tea.Msg {
 		if len(m.messages) == 0 {
 			return commandResultMsg{content: "No messages to export."}
@@ -742,11 +1154,25 @@ func (m *Model) exportConversation() tea.Cmd {
 		sb.WriteString("---\n\n")
 
 		for _, msg := range m.messages {
+			if msg.Transient {
+				continue
+			}
 			role := "**Master**"
-			if msg.Role == RoleAssistant {
+			switch {
+			case msg.Tool != nil:
+				role = fmt.Sprintf("**Tool %s**", msg.Tool.Name)
+			case msg.Role == RoleAssistant:
 				role = "**Slave**"
 			}
-			sb.WriteString(fmt.Sprintf("%s (%s):\n\n%s\n\n---\n\n", role, msg.Time.Format("15:04"), msg.Content))
+			sb.WriteString(fmt.Sprintf("%s (%s):\n\n", role, msg.Time.Format("15:04")))
+			if msg.Thinking != "" && exportThinking {
+				sb.WriteString(fmt.Sprintf("<details><summary>Thinking</summary>\n\n%s\n\n</details>\n\n", msg.Thinking))
+			}
+			sb.WriteString(msg.Content + "\n\n")
+			if len(msg.Attachments) > 0 {
+				sb.WriteString(fmt.Sprintf("*%s*\n\n", attachmentSummary(msg.Attachments)))
+			}
+			sb.WriteString("---\n\n")
 		}
 
 		// Save to file
@@ -0,0 +1,253 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/diiviikk5/dvkcli/internal/attach"
+	"github.com/diiviikk5/dvkcli/internal/memory"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+)
+
+// attachFiles handles /file: each argument may be a file, a directory or a
+// glob, quoted when it has spaces. Attachments wait as chips until the next
+// message is sent.
+func (m *Model) attachFiles(text string) {
+	args, err := splitPaths(text)
+	if err != nil {
+		m.addNotice(fmt.Sprintf("Error: %v", err))
+		return
+	}
+	if len(args) == 0 {
+		if len(m.pending) == 0 {
+			m.addNotice("Usage: /file <path|dir|glob>...  (or mention @path in a message)")
+			return
+		}
+		var sb strings.Builder
+		sb.WriteString("Attached to the next message:\n")
+		for _, att := range m.pending {
+			sb.WriteString("  " + att.Label() + "\n")
+		}
+		sb.WriteString("\nUse /detach to remove them.")
+		m.addNotice(sb.String())
+		return
+	}
+
+	var problems []string
+	for _, arg := range args {
+		atts, err := m.loader.Load(arg)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		m.pending = mergeAttachments(m.pending, atts)
+	}
+
+	if len(problems) > 0 {
+		m.addNotice("Could not attach everything:\n  " + strings.Join(problems, "\n  "))
+	}
+	m.resize()
+}
+
+// attachImages handles /image, attaching PNG or JPEG files for vision models
+func (m *Model) attachImages(text string) tea.Cmd {
+	args, err := splitPaths(text)
+	if err != nil {
+		m.addNotice(fmt.Sprintf("Error: %v", err))
+		return nil
+	}
+	if len(args) == 0 {
+		m.addNotice("Usage: /image <path>...")
+		return nil
+	}
+
+	var problems []string
+	for _, arg := range args {
+		att, err := m.loader.LoadImage(arg)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		m.pending = mergeAttachments(m.pending, []attach.Attachment{att})
+	}
+
+	if len(problems) > 0 {
+		m.addNotice("Could not attach everything:\n  " + strings.Join(problems, "\n  "))
+	}
+	m.resize()
+
+	if !attach.HasImages(m.pending) {
+		return nil
+	}
+	return m.checkVision()
+}
+
+// splitPaths splits command arguments into paths. Single or double quotes
+// keep spaces in a path; backslashes are left alone for Windows paths.
+func splitPaths(text string) ([]string, error) {
+	var (
+		paths   []string
+		current strings.Builder
+		quote   rune
+		inPath  bool
+	)
+	for _, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inPath = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inPath {
+				paths = append(paths, current.String())
+				current.Reset()
+				inPath = false
+			}
+		default:
+			current.WriteRune(r)
+			inPath = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inPath {
+		paths = append(paths, current.String())
+	}
+	return paths, nil
+}
+
+// checkVision warns when the active model doesn't advertise vision support
+func (m *Model) checkVision() tea.Cmd {
+	model := m.client.Model()
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		ok, err := m.client.HasCapability(ctx, model, provider.CapabilityVision)
+		if err != nil || ok {
+			return nil
+		}
+		return commandResultMsg{content: fmt.Sprintf("⚠ %s has no vision capability according to the provider, so images will likely be ignored. Try a vision model such as llava or qwen2.5vl.", model)}
+	}
+}
+
+// detachFiles handles /detach, dropping all pending attachments
+func (m *Model) detachFiles() {
+	if len(m.pending) == 0 {
+		m.addNotice("Nothing is attached.")
+		return
+	}
+	m.pending = nil
+	m.resize()
+}
+
+// takeAttachments returns the pending attachments plus any @path mentions in
+// content, clearing the pending list. Mentions that don't name an existing
+// path are left alone since "@" also appears in ordinary prose.
+func (m *Model) takeAttachments(content string) []attach.Attachment {
+	atts := m.pending
+	m.pending = nil
+
+	var problems []string
+	for _, mention := range attach.ParseMentions(content) {
+		loaded, err := m.loader.Load(mention)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, attach.ErrNoMatch) {
+			problems = append(problems, err.Error())
+		}
+		atts = mergeAttachments(atts, loaded)
+	}
+
+	if len(problems) > 0 {
+		m.addNotice("Some mentions were not attached:\n  " + strings.Join(problems, "\n  "))
+	}
+	m.resize()
+	return atts
+}
+
+// mergeAttachments appends attachments whose path isn't already present
+func mergeAttachments(existing, added []attach.Attachment) []attach.Attachment {
+	for _, att := range added {
+		duplicate := false
+		for _, e := range existing {
+			if e.Path == att.Path {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			existing = append(existing, att)
+		}
+	}
+	return existing
+}
+
+// renderChips renders attachments as a row of chips
+func renderChips(atts []attach.Attachment, width int) string {
+	var chips []string
+	for _, att := range atts {
+		icon := "📎 "
+		if att.Kind == attach.KindImage {
+			icon = "🖼 "
+		}
+		chips = append(chips, AttachmentChipStyle.Render(icon+att.Label()))
+	}
+	return lipgloss.NewStyle().MaxWidth(width).Render(strings.Join(chips, " "))
+}
+
+// toMemoryAttachments converts attachments for storage. Images are stored
+// as references to their file rather than their bytes.
+func toMemoryAttachments(atts []attach.Attachment) []memory.Attachment {
+	var stored []memory.Attachment
+	for _, att := range atts {
+		stored = append(stored, memory.Attachment{
+			Kind:      att.Kind,
+			Path:      att.Path,
+			Size:      att.Size,
+			Content:   att.Content,
+			Truncated: att.Truncated,
+		})
+	}
+	return stored
+}
+
+// fromMemoryAttachments converts stored attachments back, re-reading images
+// that still exist so continued conversations keep them
+func fromMemoryAttachments(stored []memory.Attachment) []attach.Attachment {
+	var atts []attach.Attachment
+	for _, att := range stored {
+		converted := attach.Attachment{
+			Kind:      att.Kind,
+			Path:      att.Path,
+			Size:      att.Size,
+			Content:   att.Content,
+			Truncated: att.Truncated,
+		}
+		if att.Kind == attach.KindImage {
+			if data, err := os.ReadFile(att.Path); err == nil && attach.ImageType(data) != "" {
+				converted.Data = data
+			}
+		}
+		atts = append(atts, converted)
+	}
+	return atts
+}
+
+// attachmentSummary lists attachment labels for exports
+func attachmentSummary(atts []attach.Attachment) string {
+	var labels []string
+	for _, att := range atts {
+		labels = append(labels, att.Label())
+	}
+	return fmt.Sprintf("Attachments: %s", strings.Join(labels, ", "))
+}
@@ -0,0 +1,80 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+)
+
+// listBackends handles /backend without arguments
+func (m *Model) listBackends() {
+	var sb strings.Builder
+	sb.WriteString("Backends:\n\n")
+	for _, name := range m.backends.Names() {
+		p, _ := m.backends.Get(name)
+		marker := "  "
+		if name == m.backend {
+			marker = "► "
+		}
+		sb.WriteString(fmt.Sprintf("%s%-16s %-7s %s\n", marker, name, p.Name(), p.Model()))
+	}
+	sb.WriteString("\nUse /backend <name> to switch.")
+	m.addNotice(sb.String())
+}
+
+// useBackend handles /backend <name>, sending later messages to that backend
+func (m *Model) useBackend(name string) tea.Cmd {
+	if name == m.backend {
+		m.addNotice(fmt.Sprintf("Already using %s.", name))
+		return nil
+	}
+	if err := m.switchBackend(name); err != nil {
+		m.addNotice(fmt.Sprintf("%v. Use /backend to list them.", err))
+		return nil
+	}
+	m.addNotice(fmt.Sprintf("Switched to %s (%s).", name, m.client.Model()))
+	return m.checkConnection()
+}
+
+// switchBackend makes the named backend active and remembers the choice
+func (m *Model) switchBackend(name string) error {
+	if _, err := m.backends.Use(name); err != nil {
+		return err
+	}
+	p := m.backends.Chain(name)
+	m.backend, m.client = name, p
+	m.engine.Backend, m.engine.Client = name, p
+	m.cfg.Backend = name
+	m.connected, m.lost = false, false
+	m.ps, m.running = false, nil
+	return nil
+}
+
+// fallbackMsg reports that a reply moved on to another backend
+type fallbackMsg struct {
+	fallback provider.Fallback
+	events   chan tea.Msg
+}
+
+// followBackend switches to the backend that wrote the last reply of a
+// loaded conversation, so it carries on where it left off
+func (m *Model) followBackend() tea.Cmd {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		msg := m.messages[i]
+		if msg.Role != RoleAssistant || msg.Backend == "" {
+			continue
+		}
+		if msg.Backend == m.backend {
+			return nil
+		}
+		if _, ok := m.backends.Get(msg.Backend); !ok {
+			return nil
+		}
+		m.switchBackend(msg.Backend)
+		m.addNotice(fmt.Sprintf("Switched to %s, which wrote the last reply.", msg.Backend))
+		return m.checkConnection()
+	}
+	return nil
+}
@@ -0,0 +1,390 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/diiviikk5/dvkcli/internal/memory"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+	"github.com/google/uuid"
+)
+
+// Messages for branch navigation
+type (
+	branchesMsg     map[string][]string
+	branchSwitchMsg struct {
+		thread   []memory.Message
+		selectID string
+	}
+	memorySavedMsg struct {
+		count    int
+		branches map[string][]string
+		err      error
+		unsaved  []string // IDs of messages to save again
+	}
+)
+
+// startSelection enters message-selection mode on the newest message
+func (m *Model) startSelection() tea.Cmd {
+	if len(m.messages) == 0 {
+		return nil
+	}
+
+	m.selecting = true
+	m.selected = len(m.messages) - 1
+	m.textarea.Blur()
+	m.viewport.SetContent(m.renderMessages())
+	return nil
+}
+
+// stopSelection leaves message-selection mode and refocuses the input
+func (m *Model) stopSelection() tea.Cmd {
+	m.selecting = false
+	m.focus = focusInput
+	m.viewport.SetContent(m.renderMessages())
+	return m.textarea.Focus()
+}
+
+// handleSelectKey handles keys while a message is selected
+func (m *Model) handleSelectKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "ctrl+c":
+		return tea.Quit
+	case "esc", "q", "ctrl+b":
+		return m.stopSelection()
+	case "up", "k":
+		if m.selected > 0 {
+			m.selected--
+			m.viewport.SetContent(m.renderMessages())
+		}
+	case "down", "j":
+		if m.selected < len(m.messages)-1 {
+			m.selected++
+			m.viewport.SetContent(m.renderMessages())
+		}
+	case "e", "enter":
+		return m.editSelected()
+	case "r":
+		if m.streaming {
+			return nil
+		}
+		idx := m.selected
+		m.selecting = false
+		m.focus = focusInput
+		return tea.Batch(m.textarea.Focus(), m.retryFrom(idx, provider.ChatOptions{}))
+	case "f":
+		idx := m.selected
+		m.stopSelection()
+		return m.forkAt(idx)
+	case "o", " ", "ctrl+o":
+		m.toggleTool(m.selected)
+		m.toggleThinking(m.selected)
+	case "/":
+		// Start a command that receives the message, e.g. a plugin
+		m.selection = m.messages[m.selected].Content
+		cmd := m.stopSelection()
+		m.setInput("/")
+		return cmd
+	case "left", "h":
+		return m.switchBranch(-1)
+	case "right", "l":
+		return m.switchBranch(1)
+	}
+	return nil
+}
+
+// editSelected loads the selected user message into the input for editing.
+// Sending it creates a sibling branch; the original path stays in memory.
+func (m *Model) editSelected() tea.Cmd {
+	if m.streaming || m.selected >= len(m.messages) {
+		return nil
+	}
+
+	msg := m.messages[m.selected]
+	if msg.Role != RoleUser || msg.Transient {
+		return nil
+	}
+
+	edit := msg
+	m.editing = &edit
+	m.setInput(msg.Content)
+	return m.stopSelection()
+}
+
+// cancelEdit abandons an in-progress edit
+func (m *Model) cancelEdit() {
+	m.editing = nil
+	m.textarea.Reset()
+}
+
+// retry regenerates the last assistant reply, or asks again after a failed
+// one. Arguments may name a model and/or a temperature for this attempt only.
+func (m *Model) retry(args []string) tea.Cmd {
+	opts, err := parseRetryArgs(args)
+	if err != nil {
+		m.addNotice(fmt.Sprintf("Invalid arguments: %v. Usage: /retry [model] [temperature]", err))
+		return nil
+	}
+
+	// A failed reply leaves the user message last, which is retried too
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		msg := m.messages[i]
+		if msg.Transient || msg.Tool != nil {
+			continue
+		}
+		if msg.Role != RoleAssistant && msg.Role != RoleUser {
+			break
+		}
+		return m.retryFrom(i, opts)
+	}
+
+	m.addNotice("Nothing to retry yet.")
+	return nil
+}
+
+// retryFrom regenerates the reply to the user message at or before index i.
+// Messages after it are dropped from view but remain in memory as a branch.
+func (m *Model) retryFrom(i int, opts provider.ChatOptions) tea.Cmd {
+	for ; i >= 0; i-- {
+		if m.messages[i].Role == RoleUser && !m.messages[i].Transient {
+			break
+		}
+	}
+	if i < 0 {
+		m.addNotice("Nothing to retry yet.")
+		return nil
+	}
+
+	m.messages = m.messages[:i+1]
+	m.editing = nil
+	return m.requestResponse(opts)
+}
+
+// parseRetryArgs reads an optional model name and temperature in any order
+func parseRetryArgs(args []string) (provider.ChatOptions, error) {
+	var opts provider.ChatOptions
+	for _, arg := range args {
+		if temp, err := strconv.ParseFloat(arg, 64); err == nil {
+			if temp < 0 || temp > 2 {
+				return opts, fmt.Errorf("temperature must be between 0 and 2, got %s", arg)
+			}
+			opts.Temperature = &temp
+			continue
+		}
+		if opts.Model != "" {
+			return opts, fmt.Errorf("more than one model given")
+		}
+		opts.Model = arg
+	}
+	return opts, nil
+}
+
+// truncateAt drops the message with the given ID and everything after it
+func (m *Model) truncateAt(id string) {
+	for i, msg := range m.messages {
+		if msg.ID == id {
+			m.messages = m.messages[:i]
+			return
+		}
+	}
+}
+
+// switchBranch moves the selected message to its previous or next sibling
+// and loads the newest path below it
+func (m *Model) switchBranch(delta int) tea.Cmd {
+	if m.streaming || m.selected >= len(m.messages) {
+		return nil
+	}
+	if m.store == nil || !m.cfg.MemoryEnabled {
+		m.addNotice("Branches need memory enabled.")
+		return nil
+	}
+
+	msg := m.messages[m.selected]
+	siblings := m.branches[msg.ParentID]
+	pos := indexOf(siblings, msg.ID)
+	if msg.Transient || pos < 0 {
+		return nil
+	}
+
+	target := siblings[(pos+delta+len(siblings))%len(siblings)]
+	store, conversationID := m.store, m.conversationID
+	return func() tea.Msg {
+		ctx := context.Background()
+		leaf, err := store.GetLatestLeaf(ctx, target)
+		if err != nil {
+			return commandResultMsg{content: fmt.Sprintf("Error switching branch: %v", err)}
+		}
+		thread, err := store.GetThread(ctx, leaf)
+		if err != nil {
+			return commandResultMsg{content: fmt.Sprintf("Error switching branch: %v", err)}
+		}
+		store.SetActiveLeaf(ctx, conversationID, leaf)
+		return branchSwitchMsg{thread: thread, selectID: target}
+	}
+}
+
+// applyBranchSwitch replaces the visible path with the chosen branch
+func (m *Model) applyBranchSwitch(msg branchSwitchMsg) {
+	if len(msg.thread) == 0 {
+		return
+	}
+
+	m.messages = fromMemory(msg.thread)
+	m.editing = nil
+	for i, chat := range m.messages {
+		if chat.ID == msg.selectID {
+			m.selected = i
+		}
+	}
+	m.viewport.SetContent(m.renderMessages())
+}
+
+// fork copies the conversation up to the nth message (1-based, default the
+// last one) into a new conversation and switches to it
+func (m *Model) fork(args []string) tea.Cmd {
+	var kept []int
+	for i, msg := range m.messages {
+		if !msg.Transient {
+			kept = append(kept, i)
+		}
+	}
+	if len(kept) == 0 {
+		m.addNotice("Nothing to fork yet.")
+		return nil
+	}
+
+	n := len(kept)
+	if len(args) > 0 {
+		var err error
+		n, err = strconv.Atoi(args[0])
+		if err != nil || n < 1 || n > len(kept) {
+			m.addNotice(fmt.Sprintf("Usage: /fork [n] where n is a message number from 1 to %d", len(kept)))
+			return nil
+		}
+	}
+
+	return m.forkAt(kept[n-1])
+}
+
+// forkAt forks the conversation at message index i
+func (m *Model) forkAt(i int) tea.Cmd {
+	if m.store == nil || !m.cfg.MemoryEnabled {
+		m.addNotice("Forking needs memory enabled.")
+		return nil
+	}
+	if i < 0 || i >= len(m.messages) || m.messages[i].Transient || !m.messages[i].saved {
+		m.addNotice("That message hasn't been saved yet.")
+		return nil
+	}
+
+	messageID := m.messages[i].ID
+	title := "Fork: " + truncate(m.conversationTitle(), 44)
+	return func() tea.Msg {
+		conv, err := m.store.ForkConversation(context.Background(), messageID, uuid.New().String(), title)
+		if err != nil {
+			return commandResultMsg{content: fmt.Sprintf("Error forking conversation: %v", err)}
+		}
+		return loadConversationMsg{conversation: conv}
+	}
+}
+
+// showTree renders every branch of the current conversation, marking the
+// path being viewed
+func (m *Model) showTree() tea.Cmd {
+	if m.store == nil || !m.cfg.MemoryEnabled {
+		m.addNotice("The conversation tree needs memory enabled.")
+		return nil
+	}
+
+	conversationID := m.conversationID
+	active := make(map[string]bool)
+	for _, msg := range m.messages {
+		active[msg.ID] = !msg.Transient
+	}
+
+	return func() tea.Msg {
+		roots, err := m.store.GetTree(context.Background(), conversationID)
+		if err != nil {
+			return commandResultMsg{content: fmt.Sprintf("Error loading tree: %v", err)}
+		}
+		if len(roots) == 0 {
+			return commandResultMsg{content: "This conversation hasn't been saved yet."}
+		}
+
+		var sb strings.Builder
+		sb.WriteString("Conversation tree (● = current branch):\n\n")
+		for _, root := range roots {
+			root.Walk(func(node *memory.Node, depth int) bool {
+				marker := "○"
+				if active[node.Message.ID] {
+					marker = "●"
+				}
+				role := "Master"
+				switch node.Message.Role {
+				case RoleAssistant:
+					role = "Slave"
+				case RoleTool:
+					role = "Tool " + node.Message.ToolName
+				}
+				content := strings.ReplaceAll(node.Message.Content, "\n", " ")
+				sb.WriteString(fmt.Sprintf("%s%s %s: %s\n", strings.Repeat("  ", depth), marker, role, truncate(content, 60)))
+				return true
+			})
+		}
+
+		return commandResultMsg{content: sb.String()}
+	}
+}
+
+// conversationTitle derives a title from the first message
+func (m *Model) conversationTitle() string {
+	for _, msg := range m.messages {
+		if !msg.Transient {
+			return truncate(msg.Content, 50)
+		}
+	}
+	return "New Chat"
+}
+
+// loadBranches refreshes the branch points of the current conversation
+func (m *Model) loadBranches() tea.Cmd {
+	if m.store == nil || !m.cfg.MemoryEnabled {
+		return nil
+	}
+
+	conversationID := m.conversationID
+	return func() tea.Msg {
+		branches, err := m.store.GetBranchPoints(context.Background(), conversationID)
+		if err != nil {
+			return nil
+		}
+		return branchesMsg(branches)
+	}
+}
+
+// branchLabel returns "‹i/n›" for messages that have alternatives
+func (m *Model) branchLabel(msg ChatMessage) string {
+	if msg.Transient {
+		return ""
+	}
+	siblings := m.branches[msg.ParentID]
+	pos := indexOf(siblings, msg.ID)
+	if pos < 0 {
+		return ""
+	}
+	return fmt.Sprintf("‹%d/%d›", pos+1, len(siblings))
+}
+
+// indexOf returns the position of id in ids, or -1
+func indexOf(ids []string, id string) int {
+	for i, v := range ids {
+		if v == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,461 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// argKind says what values a command argument takes, for completion
+type argKind int
+
+const (
+	argText         argKind = iota // free text, not completed
+	argModel                       // an installed model of the active backend
+	argBackend                     // a configured backend
+	argConversation                // a saved conversation's title; takes the rest of the line
+	argFile                        // a path relative to the working directory
+	argTemplate                    // a prompt template
+	argCommand                     // a slash command
+)
+
+// commandArg describes one argument of a command
+type commandArg struct {
+	name     string
+	kind     argKind
+	optional bool
+	repeat   bool     // takes any number of values
+	choices  []string // offered before the values of kind
+}
+
+// command is a slash command. Commands come from the built-in list, prompt
+// templates and plugins; source tells them apart.
+type command struct {
+	name        string // without the slash
+	aliases     []string
+	args        []commandArg
+	description string
+	source      string // "" for built-ins
+
+	// run handles the command, given its arguments split into words and as
+	// the text after the command name
+	run func(m *Model, args []string, text string) tea.Cmd
+}
+
+// usage shows how to call the command, e.g. /retry [model] [temperature]
+func (c *command) usage() string {
+	parts := []string{"/" + c.name}
+	for _, arg := range c.args {
+		name := arg.name
+		if arg.repeat {
+			name += "..."
+		}
+		if arg.optional {
+			parts = append(parts, "["+name+"]")
+		} else {
+			parts = append(parts, "<"+name+">")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// needsArgs reports whether the command has a required argument
+func (c *command) needsArgs() bool {
+	for _, arg := range c.args {
+		if !arg.optional {
+			return true
+		}
+	}
+	return false
+}
+
+// argAt returns the argument at position i, counting repeated arguments as
+// taking every later position
+func (c *command) argAt(i int) (commandArg, bool) {
+	if n := len(c.args); n > 0 && i >= n && c.args[n-1].repeat {
+		return c.args[n-1], true
+	}
+	if i >= len(c.args) {
+		return commandArg{}, false
+	}
+	return c.args[i], true
+}
+
+// commandRegistry holds the slash commands in the order /help lists them
+type commandRegistry struct {
+	commands []*command
+	byName   map[string]*command // by name and alias
+}
+
+// newCommandRegistry creates a registry holding the built-in commands
+func newCommandRegistry() *commandRegistry {
+	r := &commandRegistry{byName: make(map[string]*command)}
+	for _, cmd := range builtinCommands() {
+		if err := r.register(cmd); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// register adds a command, refusing names or aliases already taken
+func (r *commandRegistry) register(cmd command) error {
+	for _, name := range append([]string{cmd.name}, cmd.aliases...) {
+		if existing, ok := r.byName[name]; ok {
+			return fmt.Errorf("/%s is taken by /%s", name, existing.name)
+		}
+	}
+	c := &cmd
+	r.commands = append(r.commands, c)
+	for _, name := range append([]string{cmd.name}, cmd.aliases...) {
+		r.byName[name] = c
+	}
+	return nil
+}
+
+// replace swaps the commands from one source for new ones. Commands whose
+// names are taken are skipped and returned.
+func (r *commandRegistry) replace(source string, cmds []command) []string {
+	kept := r.commands[:0]
+	for _, c := range r.commands {
+		if c.source == source {
+			for _, name := range append([]string{c.name}, c.aliases...) {
+				delete(r.byName, name)
+			}
+			continue
+		}
+		kept = append(kept, c)
+	}
+	r.commands = kept
+
+	var skipped []string
+	for _, cmd := range cmds {
+		cmd.source = source
+		if err := r.register(cmd); err != nil {
+			skipped = append(skipped, cmd.name)
+		}
+	}
+	return skipped
+}
+
+// lookup finds a command by name or alias, without the slash
+func (r *commandRegistry) lookup(name string) (*command, bool) {
+	c, ok := r.byName[strings.ToLower(name)]
+	return c, ok
+}
+
+// matching returns the commands with a name or alias starting with prefix,
+// built-ins first
+func (r *commandRegistry) matching(prefix string) []*command {
+	prefix = strings.ToLower(prefix)
+	var out []*command
+	for _, c := range r.commands {
+		for _, name := range append([]string{c.name}, c.aliases...) {
+			if strings.HasPrefix(name, prefix) {
+				out = append(out, c)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// names returns every command name, sorted
+func (r *commandRegistry) names() []string {
+	names := make([]string, 0, len(r.commands))
+	for _, c := range r.commands {
+		names = append(names, c.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// handleCommand runs a slash command typed into the input
+func (m *Model) handleCommand(input string) tea.Cmd {
+	m.textarea.Reset()
+
+	parts := strings.Fields(input)
+	if len(parts) == 0 {
+		return nil
+	}
+
+	name := strings.TrimPrefix(parts[0], "/")
+	m.refreshTemplateCommands()
+	cmd, ok := m.commands.lookup(name)
+	if !ok {
+		hint := "Type /help for available commands."
+		if matches := m.commands.matching(name); len(matches) > 0 {
+			hint = fmt.Sprintf("Did you mean %s?", "/"+matches[0].name)
+		}
+		m.addNotice(fmt.Sprintf("Unknown command: %s. %s", parts[0], hint))
+		return nil
+	}
+	return cmd.run(m, parts[1:], strings.TrimSpace(input[len(parts[0]):]))
+}
+
+// showHelp handles /help, listing every command, or describing one
+func (m *Model) showHelp(args []string) {
+	if len(args) > 0 {
+		cmd, ok := m.commands.lookup(strings.TrimPrefix(args[0], "/"))
+		if !ok {
+			m.addNotice(fmt.Sprintf("Unknown command: %s. Type /help for available commands.", args[0]))
+			return
+		}
+		help := fmt.Sprintf("%s\n\n%s", cmd.usage(), cmd.description)
+		if len(cmd.aliases) > 0 {
+			help += "\n\nAlso: /" + strings.Join(cmd.aliases, ", /")
+		}
+		m.addNotice(help)
+		return
+	}
+
+	width := 0
+	for _, c := range m.commands.commands {
+		width = max(width, len(c.usage()))
+	}
+
+	var sb strings.Builder
+	sections := []struct{ title, source string }{
+		{"Available commands:", ""},
+		{"Templates:", sourceTemplate},
+		{"Plugins:", sourcePlugin},
+	}
+	for _, section := range sections {
+		var lines []string
+		for _, c := range m.commands.commands {
+			if c.source == section.source {
+				lines = append(lines, fmt.Sprintf("  %-*s  %s", width, c.usage(), c.description))
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		sb.WriteString(section.title + "\n" + strings.Join(lines, "\n") + "\n\n")
+	}
+	sb.WriteString(shortcutHelp)
+	m.addNotice(sb.String())
+}
+
+// Sources of commands that aren't built in
+const (
+	sourceTemplate = "template"
+	sourcePlugin   = "plugin"
+)
+
+// shortcutHelp lists the keyboard shortcuts under /help
+const shortcutHelp = `Shortcuts:
+  Enter     - Send message
+  Tab       - Complete a command, or focus the chat
+  Ctrl+N    - New conversation
+  Ctrl+L    - Load last conversation
+  Ctrl+E    - Export conversation
+  Ctrl+R    - Search prompt history
+  Ctrl+B    - Select a message to edit, retry, fork, switch branch or pass to a command (/)
+  Ctrl+O    - Expand/collapse tool calls
+  Ctrl+T    - Show/hide model thinking
+  ↑/↓       - Previous/next prompt
+  Esc       - Focus chat to scroll (j/k, g/G)
+  PgUp/PgDn - Page scroll
+  Ctrl+C    - Quit`
+
+// builtinCommands lists the commands dvkcli always has
+func builtinCommands() []command {
+	optional := func(name string, kind argKind) commandArg {
+		return commandArg{name: name, kind: kind, optional: true}
+	}
+	required := func(name string, kind argKind) commandArg {
+		return commandArg{name: name, kind: kind}
+	}
+
+	return []command{
+		{
+			name: "help", aliases: []string{"?"},
+			args:        []commandArg{optional("command", argCommand)},
+			description: "Show this help, or how to use a command",
+			run: func(m *Model, args []string, _ string) tea.Cmd {
+				m.showHelp(args)
+				return nil
+			},
+		},
+		{
+			name:        "models",
+			description: "List available models",
+			run:         func(m *Model, _ []string, _ string) tea.Cmd { return m.listModels() },
+		},
+		{
+			name:        "backend",
+			args:        []commandArg{optional("name", argBackend)},
+			description: "List backends, or switch to one",
+			run: func(m *Model, args []string, _ string) tea.Cmd {
+				if len(args) == 0 {
+					m.listBackends()
+					return nil
+				}
+				return m.useBackend(args[0])
+			},
+		},
+		{
+			name:        "pull",
+			args:        []commandArg{{name: "model", repeat: true}},
+			description: "Download models, showing progress",
+			run:         func(m *Model, args []string, _ string) tea.Cmd { return m.pullModels(args) },
+		},
+		{
+			name:        "rm",
+			args:        []commandArg{required("model", argModel)},
+			description: "Remove a downloaded model",
+			run:         func(m *Model, args []string, _ string) tea.Cmd { return m.removeModel(firstArg(args)) },
+		},
+		{
+			name:        "show",
+			args:        []commandArg{optional("model", argModel)},
+			description: "Show a model's details",
+			run:         func(m *Model, args []string, _ string) tea.Cmd { return m.showModel(firstArg(args)) },
+		},
+		{
+			name:        "ps",
+			description: "Show models loaded in memory",
+			run:         func(m *Model, _ []string, _ string) tea.Cmd { return m.showRunning() },
+		},
+		{
+			name:        "stats",
+			args:        []commandArg{optional("days", argText)},
+			description: "Show token usage per model",
+			run:         func(m *Model, args []string, _ string) tea.Cmd { return m.showStats(args) },
+		},
+		{
+			name:        "format",
+			args:        []commandArg{{name: "json|schema.json|off", kind: argFile, optional: true, choices: []string{"json", "off"}}},
+			description: "Reply in JSON, optionally following a JSON schema",
+			run: func(m *Model, args []string, _ string) tea.Cmd {
+				m.setFormat(args)
+				return nil
+			},
+		},
+		{
+			name: "t", aliases: []string{"template"},
+			args:        []commandArg{optional("name", argTemplate), {name: "name=value", optional: true, repeat: true}},
+			description: "Send a prompt template, or list them",
+			run:         func(m *Model, _ []string, text string) tea.Cmd { return m.runTemplate(text) },
+		},
+		{
+			name:        "search",
+			args:        []commandArg{required("query", argText)},
+			description: "Search past conversations",
+			run: func(m *Model, _ []string, text string) tea.Cmd {
+				if text == "" {
+					m.addNotice("Usage: /search <query>")
+					return nil
+				}
+				return m.searchMemory(text)
+			},
+		},
+		{
+			name:        "open",
+			aliases:     []string{"load"},
+			args:        []commandArg{optional("conversation", argConversation)},
+			description: "Open a saved conversation, or list recent ones",
+			run:         func(m *Model, _ []string, text string) tea.Cmd { return m.openConversation(text) },
+		},
+		{
+			name:        "clear",
+			aliases:     []string{"new"},
+			description: "Clear the conversation and start a new one",
+			run: func(m *Model, _ []string, _ string) tea.Cmd {
+				m.newConversation()
+				return nil
+			},
+		},
+		{
+			name:        "export",
+			description: "Export the conversation to markdown",
+			run:         func(m *Model, _ []string, _ string) tea.Cmd { return m.exportConversation() },
+		},
+		{
+			name:        "retry",
+			args:        []commandArg{optional("model", argModel), optional("temperature", argText)},
+			description: "Regenerate the last reply",
+			run:         func(m *Model, args []string, _ string) tea.Cmd { return m.retry(args) },
+		},
+		{
+			name:        "fork",
+			args:        []commandArg{optional("n", argText)},
+			description: "Copy the conversation up to message n into a new one",
+			run:         func(m *Model, args []string, _ string) tea.Cmd { return m.fork(args) },
+		},
+		{
+			name:        "tree",
+			description: "Show every branch of this conversation",
+			run:         func(m *Model, _ []string, _ string) tea.Cmd { return m.showTree() },
+		},
+		{
+			name:        "file",
+			args:        []commandArg{{name: "path", kind: argFile, repeat: true}},
+			description: "Attach files, directories or globs (or write @path)",
+			run: func(m *Model, _ []string, text string) tea.Cmd {
+				m.attachFiles(text)
+				return nil
+			},
+		},
+		{
+			name:        "image",
+			args:        []commandArg{{name: "path", kind: argFile, repeat: true}},
+			description: "Attach PNG/JPEG images for vision models",
+			run:         func(m *Model, _ []string, text string) tea.Cmd { return m.attachImages(text) },
+		},
+		{
+			name:        "detach",
+			description: "Remove pending attachments",
+			run: func(m *Model, _ []string, _ string) tea.Cmd {
+				m.detachFiles()
+				return nil
+			},
+		},
+		{
+			name:        "tools",
+			description: "List tools the model can call",
+			run: func(m *Model, _ []string, _ string) tea.Cmd {
+				m.listTools()
+				return nil
+			},
+		},
+		{
+			name:        "run",
+			args:        []commandArg{required("command", argText)},
+			description: "Run a shell command and attach its output",
+			run:         func(m *Model, _ []string, text string) tea.Cmd { return m.runCommand(text) },
+		},
+		{
+			name:        "audit",
+			description: "Show recently executed commands",
+			run:         func(m *Model, _ []string, _ string) tea.Cmd { return m.showAudit() },
+		},
+		{
+			name:        "undo",
+			description: "Revert the last file change made by the assistant",
+			run: func(m *Model, _ []string, _ string) tea.Cmd {
+				m.undoEdit()
+				return nil
+			},
+		},
+		{
+			name:        "mcp",
+			args:        []commandArg{optional("server", argText)},
+			description: "Show MCP server status",
+			run: func(m *Model, args []string, _ string) tea.Cmd {
+				m.showMCP(args)
+				return nil
+			},
+		},
+	}
+}
+
+// firstArg returns the first argument, or "" without any
+func firstArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
@@ -0,0 +1,357 @@
+package tui
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/diiviikk5/dvkcli/internal/prompts"
+)
+
+// maxCompletionRows is how many items the popup shows at once
+const maxCompletionRows = 6
+
+// completion is one item offered by the popup
+type completion struct {
+	value  string // replaces the text being completed
+	label  string
+	detail string
+}
+
+// completer is the popup completing slash commands and their arguments
+// while they are typed
+type completer struct {
+	input    string // the input the items were found for
+	items    []completion
+	hint     string // usage of the command whose arguments are completed
+	naming   bool   // the command name itself is being completed
+	start    int    // where in the input the text an item replaces starts
+	selected int
+	hidden   bool                 // dismissed with Esc until the input changes
+	typed    bool                 // the input was typed rather than recalled, so ↑/↓ select items
+	values   map[argKind][]string // values fetched since the popup opened
+	fetching map[argKind]bool     // values being fetched in the background
+}
+
+// argValuesMsg carries argument values fetched for completion
+type argValuesMsg struct {
+	kind   argKind
+	values []string
+}
+
+// updateCompletion refreshes the popup after the input changed, resizing the
+// layout when the popup grows or shrinks
+func (m *Model) updateCompletion() tea.Cmd {
+	c := &m.completion
+	value := m.textarea.Value()
+	if value == c.input {
+		return nil
+	}
+	height := m.completionHeight()
+
+	var cmd tea.Cmd
+	if !strings.HasPrefix(value, "/") || strings.Contains(value, "\n") {
+		*c = completer{input: value}
+		// A selected message is only kept while a command is typed
+		m.selection = ""
+	} else {
+		if !strings.HasPrefix(c.input, "/") {
+			// The popup opens: pick up template edits and fetch values afresh
+			m.refreshTemplateCommands()
+			c.values = make(map[argKind][]string)
+			c.fetching = make(map[argKind]bool)
+		}
+		c.input, c.hidden, c.selected = value, false, 0
+		cmd = m.findCompletions(value)
+	}
+
+	if m.completionHeight() != height {
+		m.resize()
+	}
+	return cmd
+}
+
+// findCompletions fills the popup for a command line being typed
+func (m *Model) findCompletions(value string) tea.Cmd {
+	c := &m.completion
+	c.items, c.hint, c.naming, c.start = nil, "", false, 0
+
+	name, rest, hasArgs := strings.Cut(value[1:], " ")
+	if !hasArgs {
+		c.naming = true
+		for _, cmd := range m.commands.matching(name) {
+			item := completion{value: "/" + cmd.name, label: cmd.usage(), detail: cmd.description}
+			if len(cmd.args) > 0 {
+				item.value += " "
+			}
+			// An exact match goes first so Enter runs what was typed
+			if cmd.name == strings.ToLower(name) {
+				c.items = append([]completion{item}, c.items...)
+				continue
+			}
+			c.items = append(c.items, item)
+		}
+		return nil
+	}
+
+	cmd, ok := m.commands.lookup(name)
+	if !ok {
+		return nil
+	}
+	c.hint = cmd.usage()
+
+	// Work out which argument is being typed, and the part typed so far
+	fields := strings.Fields(rest)
+	index, partial := len(fields), ""
+	if len(fields) > 0 && !strings.HasSuffix(rest, " ") {
+		index, partial = len(fields)-1, fields[len(fields)-1]
+	}
+	if len(cmd.args) > 0 && cmd.args[0].kind == argConversation {
+		index, partial = 0, strings.TrimLeft(rest, " ")
+	}
+	arg, ok := cmd.argAt(index)
+	if !ok {
+		return nil
+	}
+	c.start = len(value) - len(partial)
+
+	values, fetch := m.argValues(arg.kind, partial)
+	seen := make(map[string]bool)
+	for _, v := range append(append([]string{}, arg.choices...), values...) {
+		if seen[v] || v == partial || !strings.HasPrefix(strings.ToLower(v), strings.ToLower(partial)) {
+			continue
+		}
+		seen[v] = true
+		item := completion{value: v, label: v}
+		if arg.kind != argConversation && !strings.HasSuffix(v, "/") {
+			item.value += " "
+		}
+		c.items = append(c.items, item)
+	}
+	return fetch
+}
+
+// argValues returns the values an argument of kind may take. Installed
+// models and saved conversations are listed in the background; the returned
+// command fetches them.
+func (m *Model) argValues(kind argKind, partial string) ([]string, tea.Cmd) {
+	c := &m.completion
+	if values, ok := c.values[kind]; ok {
+		return values, nil
+	}
+	if c.fetching[kind] {
+		return nil, nil
+	}
+
+	switch kind {
+	case argModel:
+		c.fetching[kind] = true
+		return nil, m.fetchModelNames()
+	case argBackend:
+		c.values[kind] = m.backends.Names()
+	case argConversation:
+		c.fetching[kind] = true
+		return nil, m.fetchConversationTitles()
+	case argTemplate:
+		templates, _ := m.loadTemplates()
+		c.values[kind] = prompts.Complete(templates, "")
+	case argCommand:
+		c.values[kind] = m.commands.names()
+	case argFile:
+		// Listings depend on the directory typed so far, so they aren't kept
+		return m.filePaths(partial), nil
+	}
+	return c.values[kind], nil
+}
+
+// fetchModelNames lists the active backend's installed models
+func (m *Model) fetchModelNames() tea.Cmd {
+	client := m.client
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		models, err := client.ListModels(ctx)
+		if err != nil {
+			return argValuesMsg{kind: argModel}
+		}
+		names := make([]string, len(models))
+		for i, model := range models {
+			names[i] = model.Name
+		}
+		return argValuesMsg{kind: argModel, values: names}
+	}
+}
+
+// fetchConversationTitles lists the titles of recent saved conversations
+func (m *Model) fetchConversationTitles() tea.Cmd {
+	store := m.store
+	return func() tea.Msg {
+		msg := argValuesMsg{kind: argConversation}
+		if store == nil {
+			return msg
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		convs, err := store.ListConversations(ctx, 50)
+		if err != nil {
+			return msg
+		}
+		for _, conv := range convs {
+			msg.values = append(msg.values, conv.Title)
+		}
+		return msg
+	}
+}
+
+// showArgValues offers the fetched values if the popup is still open
+func (m *Model) showArgValues(msg argValuesMsg) {
+	c := &m.completion
+	if c.values == nil {
+		return
+	}
+	delete(c.fetching, msg.kind)
+	c.values[msg.kind] = msg.values
+	selected := c.selected
+	m.findCompletions(c.input)
+	c.selected = min(selected, max(len(c.items)-1, 0))
+	m.resize()
+}
+
+// filePaths lists the entries of the directory partial points into,
+// relative to the working directory. Directories end with a slash, and
+// hidden entries are only offered once a dot is typed.
+func (m *Model) filePaths(partial string) []string {
+	dir, base := "", partial
+	if i := strings.LastIndex(partial, "/"); i >= 0 {
+		dir, base = partial[:i+1], partial[i+1:]
+	}
+	root := filepath.Join(m.loader.Root, dir)
+	if filepath.IsAbs(dir) {
+		root = dir
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		if entry.IsDir() {
+			name += "/"
+		}
+		paths = append(paths, dir+name)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// completionVisible reports whether the popup is showing items
+func (m *Model) completionVisible() bool {
+	return !m.completion.hidden && len(m.completion.items) > 0
+}
+
+// acceptCompletion puts the selected item into the input. It reports
+// whether there was one to accept.
+func (m *Model) acceptCompletion() bool {
+	if !m.completionVisible() {
+		return false
+	}
+	c := &m.completion
+	m.setInput(c.input[:c.start] + c.items[c.selected].value)
+	c.typed = true
+	return true
+}
+
+// handleCompletionKey handles the keys the popup takes while it shows items.
+// It reports whether the key was used.
+func (m *Model) handleCompletionKey(msg tea.KeyMsg) bool {
+	if !m.completionVisible() {
+		return false
+	}
+	c := &m.completion
+	switch msg.String() {
+	case "up", "down":
+		// A recalled command leaves ↑/↓ to history
+		if !c.typed {
+			return false
+		}
+		if msg.String() == "up" {
+			c.selected = (c.selected - 1 + len(c.items)) % len(c.items)
+		} else {
+			c.selected = (c.selected + 1) % len(c.items)
+		}
+	case "esc":
+		c.hidden = true
+		m.resize()
+	case "enter":
+		// Enter picks a command name; arguments are sent as typed
+		if !c.naming {
+			return false
+		}
+		name := strings.TrimSpace(c.items[c.selected].value)
+		cmd, ok := m.commands.lookup(strings.TrimPrefix(name, "/"))
+		if ok && cmd.needsArgs() {
+			m.acceptCompletion()
+			return true
+		}
+		// Leave the command in the input for Enter to run
+		m.setInput(name)
+		return false
+	default:
+		return false
+	}
+	return true
+}
+
+// completionHeight is how many lines the popup takes
+func (m *Model) completionHeight() int {
+	c := &m.completion
+	if c.hidden || (len(c.items) == 0 && c.hint == "") {
+		return 0
+	}
+	height := min(len(c.items), maxCompletionRows)
+	if c.hint != "" {
+		height++
+	}
+	return height
+}
+
+// renderCompletion renders the popup, scrolled to keep the selection in view
+func (m *Model) renderCompletion() string {
+	c := &m.completion
+	var lines []string
+	if c.hint != "" {
+		lines = append(lines, HelpStyle.Render("  "+c.hint))
+	}
+
+	first := max(0, c.selected-maxCompletionRows+1)
+	visible := c.items[first:min(len(c.items), first+maxCompletionRows)]
+	width := 0
+	for _, item := range visible {
+		width = max(width, lipgloss.Width(item.label))
+	}
+	for i, item := range visible {
+		label := item.label + strings.Repeat(" ", width-lipgloss.Width(item.label))
+		detail := ""
+		if room := m.width - width - 10; item.detail != "" && room >= 10 {
+			detail = "  " + truncate(item.detail, room)
+		}
+		if first+i == c.selected {
+			lines = append(lines, CompletionSelectedStyle.Width(m.width-2).Render(label+detail))
+			continue
+		}
+		lines = append(lines, CompletionStyle.Render(label)+HelpStyle.Render(detail))
+	}
+	return strings.Join(lines, "\n")
+}
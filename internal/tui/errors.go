@@ -0,0 +1,51 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/diiviikk5/dvkcli/internal/provider"
+)
+
+// errEmptyReply is reported when the model answers with nothing
+var errEmptyReply = errors.New("the model returned an empty reply")
+
+// addError shows why a reply failed, with a hint on what to do about it.
+// The message is never sent to the model or saved to memory.
+func (m *Model) addError(err error, model string) {
+	content := err.Error()
+	if hint := errorHint(err, model); hint != "" {
+		content += "\n→ " + hint
+	}
+	m.messages = append(m.messages, ChatMessage{
+		Role:      RoleSystem,
+		Content:   content,
+		Time:      time.Now(),
+		Transient: true,
+		Failed:    true,
+	})
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+}
+
+// errorHint suggests how to recover from a failed reply
+func errorHint(err error, model string) string {
+	switch {
+	case errors.Is(err, provider.ErrUnreachable):
+		return "Is the backend running? Start Ollama with `ollama serve`, check the URL in ~/.dvkcli/config.json, or /backend to switch."
+	case errors.Is(err, provider.ErrModelNotFound):
+		return fmt.Sprintf("Download it with /pull %s, or pick an installed model from /models.", model)
+	case errors.Is(err, provider.ErrContextOverflow):
+		return "The conversation no longer fits the model's context. /clear to start over, or /fork an earlier message."
+	case errors.Is(err, provider.ErrUnauthorized):
+		return "Check the API key in the environment variable named by the backend's api_key_env."
+	case errors.Is(err, provider.ErrTimeout):
+		return "The model may still be loading. /retry to try again."
+	case errors.Is(err, provider.ErrUnavailable):
+		return "The backend is busy or failing. /retry in a moment, or /backend to switch."
+	case errors.Is(err, errEmptyReply):
+		return "The model might still be loading. /retry to ask again."
+	}
+	return ""
+}
@@ -0,0 +1,45 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/diiviikk5/dvkcli/internal/provider"
+	"github.com/diiviikk5/dvkcli/internal/schema"
+)
+
+// setFormat handles /format, which makes replies JSON, optionally matching a
+// schema file, until turned off
+func (m *Model) setFormat(args []string) {
+	if len(args) == 0 {
+		if m.format == nil {
+			m.addNotice("Replies are free text. Use /format json for JSON, or /format <schema.json> to follow a JSON schema.")
+			return
+		}
+		m.addNotice(fmt.Sprintf("Replies are JSON (%s). Use /format off for free text.", m.formatName))
+		return
+	}
+
+	switch arg := strings.Join(args, " "); arg {
+	case "off", "text":
+		m.format, m.formatName = nil, ""
+		m.addNotice("Replies are free text again.")
+	case "json":
+		m.format, m.formatName = provider.FormatJSON, "json"
+		m.addNotice("Replies are JSON.")
+	default:
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			m.addNotice(fmt.Sprintf("Error: %v", err))
+			return
+		}
+		if _, err := schema.Parse(data); err != nil {
+			m.addNotice(fmt.Sprintf("Error: %s: %v", arg, err))
+			return
+		}
+		m.format, m.formatName = data, filepath.Base(arg)
+		m.addNotice(fmt.Sprintf("Replies follow the JSON schema in %s and are checked against it.", arg))
+	}
+}
@@ -0,0 +1,198 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/diiviikk5/dvkcli/internal/attach"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+)
+
+// How often the backend is checked: steadily while it answers, backing off
+// from healthRetry to healthMaxRetry while it doesn't
+const (
+	healthInterval = 10 * time.Second
+	healthRetry    = time.Second
+	healthMaxRetry = 30 * time.Second
+)
+
+// healthTickMsg starts a scheduled health check
+type healthTickMsg struct{}
+
+// statusMsg reports whether a backend is reachable and, for backends that
+// can tell, which models it has loaded
+type statusMsg struct {
+	backend   string
+	connected bool
+	ps        bool // running was reported
+	running   []provider.RunningModel
+	scheduled bool // part of the periodic checks, which schedule the next one
+}
+
+// checkConnection checks the active backend once, outside the periodic
+// checks
+func (m *Model) checkConnection() tea.Cmd {
+	return m.checkStatus(false)
+}
+
+// checkStatus checks whether the active backend is reachable and what it has
+// loaded
+func (m *Model) checkStatus(scheduled bool) tea.Cmd {
+	client, backend := m.client, m.backend
+	lister, _ := m.processLister()
+
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		status := statusMsg{backend: backend, connected: client.IsConnected(ctx), scheduled: scheduled}
+		if status.connected && lister != nil {
+			running, err := lister.ListRunning(ctx)
+			status.running, status.ps = running, err == nil
+		}
+		return status
+	}
+}
+
+// applyStatus records a health check and schedules the next periodic one
+func (m *Model) applyStatus(msg statusMsg) tea.Cmd {
+	// Checks of a backend switched away from only keep the schedule going
+	if msg.backend == m.backend {
+		switch {
+		case m.connected && !msg.connected:
+			m.lost = true
+			m.addNotice(fmt.Sprintf("⚠ Lost connection to %s. Retrying in the background.", m.backend))
+		case !m.connected && msg.connected && m.lost:
+			m.lost = false
+			m.addNotice(fmt.Sprintf("Reconnected to %s.", m.backend))
+		}
+		m.connected = msg.connected
+		m.ps, m.running = msg.ps, msg.running
+	}
+	if !msg.scheduled {
+		return nil
+	}
+
+	delay := healthInterval
+	if !m.connected {
+		delay = min(max(m.healthDelay*2, healthRetry), healthMaxRetry)
+		m.healthDelay = delay
+	} else {
+		m.healthDelay = 0
+	}
+	m.nextCheck = time.Now().Add(delay)
+	return tea.Tick(delay, func(time.Time) tea.Msg {
+		return healthTickMsg{}
+	})
+}
+
+// processLister returns the active backend if it can report loaded models
+func (m *Model) processLister() (provider.ProcessLister, bool) {
+	p, ok := m.backends.Get(m.backend)
+	if !ok {
+		return nil, false
+	}
+	lister, ok := p.(provider.ProcessLister)
+	return lister, ok
+}
+
+// activeRunning returns the active model if it is loaded
+func (m *Model) activeRunning() *provider.RunningModel {
+	model := m.client.Model()
+	for i := range m.running {
+		if provider.SameModel(m.running[i].Name, model) {
+			return &m.running[i]
+		}
+	}
+	return nil
+}
+
+// renderHealth renders the connection segment of the status bar
+func (m *Model) renderHealth() string {
+	if !m.connected {
+		status := "○ disconnected"
+		if wait := time.Until(m.nextCheck); wait > 0 {
+			status += fmt.Sprintf(" · retry in %s", wait.Round(time.Second))
+		}
+		return StatusErrorStyle.Render(status)
+	}
+	if !m.ps {
+		return StatusActiveStyle.Render("● connected")
+	}
+	running := m.activeRunning()
+	if running == nil {
+		return StatusActiveStyle.Render("● connected · not loaded")
+	}
+	return StatusActiveStyle.Render(fmt.Sprintf("● loaded %s %s · %s",
+		attach.FormatSize(running.Size), processor(*running), expiry(running.ExpiresAt)))
+}
+
+// showRunning handles /ps, listing the models the backend has loaded
+func (m *Model) showRunning() tea.Cmd {
+	lister, ok := m.processLister()
+	if !ok {
+		m.addNotice(fmt.Sprintf("The %s backend doesn't report loaded models.", m.backend))
+		return nil
+	}
+	backend := m.backend
+
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		running, err := lister.ListRunning(ctx)
+		if err != nil {
+			return commandResultMsg{content: fmt.Sprintf("Error: %v", err)}
+		}
+		if len(running) == 0 {
+			return commandResultMsg{content: fmt.Sprintf("%s has no models loaded. They load on first use.", backend)}
+		}
+
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("Loaded on %s:\n\n", backend))
+		sb.WriteString(fmt.Sprintf("  %-24s %-10s %-16s %-9s %s\n", "NAME", "SIZE", "PROCESSOR", "CONTEXT", "UNLOAD"))
+		for _, r := range running {
+			window := "-"
+			if r.ContextLength > 0 {
+				window = fmt.Sprint(r.ContextLength)
+			}
+			sb.WriteString(fmt.Sprintf("  %-24s %-10s %-16s %-9s %s\n",
+				r.Name, attach.FormatSize(r.Size), processor(r), window, expiry(r.ExpiresAt)))
+		}
+		return commandResultMsg{content: strings.TrimRight(sb.String(), "\n")}
+	}
+}
+
+// processor describes where a model is loaded, like `ollama ps`
+func processor(r provider.RunningModel) string {
+	switch {
+	case r.SizeVRAM <= 0:
+		return "100% CPU"
+	case r.SizeVRAM >= r.Size:
+		return "100% GPU"
+	}
+	gpu := r.SizeVRAM * 100 / r.Size
+	return fmt.Sprintf("%d%%/%d%% CPU/GPU", 100-gpu, gpu)
+}
+
+// expiry describes when a loaded model will be unloaded
+func expiry(at time.Time) string {
+	left := time.Until(at)
+	switch {
+	case at.IsZero():
+		return "-"
+	case left <= 0:
+		return "unloading"
+	case left > 365*24*time.Hour:
+		// A negative keep-alive keeps the model loaded indefinitely
+		return "kept loaded"
+	case left < time.Minute:
+		return fmt.Sprintf("unloads in %ds", int(left.Seconds()))
+	case left < time.Hour:
+		return fmt.Sprintf("unloads in %dm", int(left.Minutes()))
+	}
+	return fmt.Sprintf("unloads in %dh", int(left.Hours()))
+}
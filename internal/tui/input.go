@@ -0,0 +1,284 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// focusArea identifies which component receives key presses
+type focusArea int
+
+const (
+	focusInput focusArea = iota
+	focusChat
+)
+
+// handleKey handles a key press, then refreshes the completion popup for
+// whatever the key did to the input
+func (m *Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	model, cmd := m.routeKey(msg)
+	return model, tea.Batch(cmd, m.updateCompletion())
+}
+
+// routeKey routes a key press based on the current focus. Letters always go
+// to the textarea while it is focused; scrolling keys only apply to the chat.
+func (m *Model) routeKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.approval != nil {
+		return m, m.handleApprovalKey(msg)
+	}
+	if m.review != nil {
+		return m, m.handleReviewKey(msg)
+	}
+	if m.pullPrompt != nil {
+		return m, m.handlePullPromptKey(msg)
+	}
+	if m.removePrompt != "" {
+		return m, m.handleRemovePromptKey(msg)
+	}
+	if m.searching {
+		return m, m.handleSearchKey(msg)
+	}
+	if m.selecting {
+		return m, m.handleSelectKey(msg)
+	}
+
+	// Esc and Ctrl+C cancel a download before they do anything else, unless
+	// Esc is closing the popup or an edit
+	if key := msg.String(); m.pull != nil && (key == "ctrl+c" || key == "esc" && m.editing == nil && !m.completionVisible()) {
+		m.cancelPull()
+		return m, nil
+	}
+
+	// Global shortcuts
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "ctrl+n":
+		m.newConversation()
+		return m, nil
+	case "ctrl+l":
+		// Load last conversation
+		return m, m.loadLastConversation()
+	case "ctrl+e":
+		// Export conversation
+		return m, m.exportConversation()
+	case "pgup":
+		m.viewport.HalfViewUp()
+		return m, nil
+	case "pgdown":
+		m.viewport.HalfViewDown()
+		return m, nil
+	case "tab":
+		if m.focus == focusInput && m.acceptCompletion() {
+			return m, nil
+		}
+		return m, m.toggleFocus()
+	case "ctrl+b":
+		return m, m.startSelection()
+	case "ctrl+o":
+		m.toggleAllTools()
+		return m, nil
+	case "ctrl+t":
+		m.toggleAllThinking()
+		return m, nil
+	}
+
+	if m.focus == focusChat {
+		return m, m.handleChatKey(msg)
+	}
+	return m, m.handleInputKey(msg)
+}
+
+// handleChatKey handles keys while the chat viewport has focus
+func (m *Model) handleChatKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc", "i":
+		return m.toggleFocus()
+	case "up", "k":
+		m.viewport.LineUp(3)
+	case "down", "j":
+		m.viewport.LineDown(3)
+	case "g", "home":
+		m.viewport.GotoTop()
+	case "G", "end":
+		m.viewport.GotoBottom()
+	default:
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		return cmd
+	}
+	return nil
+}
+
+// handleInputKey handles keys while the textarea has focus
+func (m *Model) handleInputKey(msg tea.KeyMsg) tea.Cmd {
+	if m.handleCompletionKey(msg) {
+		return nil
+	}
+
+	switch msg.String() {
+	case "enter":
+		if !m.streaming && strings.TrimSpace(m.textarea.Value()) != "" {
+			input := strings.TrimSpace(m.textarea.Value())
+			m.recordHistory(input)
+			// Handle special commands
+			if strings.HasPrefix(input, "/") {
+				return m.handleCommand(input)
+			}
+			return m.sendMessage()
+		}
+		return nil
+	case "esc":
+		if m.editing != nil {
+			m.cancelEdit()
+			return nil
+		}
+		return m.toggleFocus()
+	case "ctrl+r":
+		m.startSearch()
+		return nil
+	case "up":
+		if m.atInputTop() {
+			if entry, ok := m.history.Prev(m.textarea.Value()); ok {
+				m.setInput(entry)
+			}
+			return nil
+		}
+	case "down":
+		if m.atInputBottom() {
+			if entry, ok := m.history.Next(); ok {
+				m.setInput(entry)
+			}
+			return nil
+		}
+	}
+
+	if m.streaming {
+		return nil
+	}
+
+	m.completion.typed = true
+	var cmd tea.Cmd
+	m.textarea, cmd = m.textarea.Update(msg)
+	return cmd
+}
+
+// toggleFocus switches focus between the input and the chat viewport
+func (m *Model) toggleFocus() tea.Cmd {
+	if m.focus == focusInput {
+		m.focus = focusChat
+		m.textarea.Blur()
+		return nil
+	}
+	m.focus = focusInput
+	return m.textarea.Focus()
+}
+
+// atInputTop reports whether the cursor is on the first visual row
+func (m *Model) atInputTop() bool {
+	return m.textarea.Line() == 0 && m.textarea.LineInfo().RowOffset == 0
+}
+
+// atInputBottom reports whether the cursor is on the last visual row
+func (m *Model) atInputBottom() bool {
+	info := m.textarea.LineInfo()
+	return m.textarea.Line() == m.textarea.LineCount()-1 && info.RowOffset >= info.Height-1
+}
+
+// setInput replaces the textarea content and moves the cursor to the end.
+// The completion popup leaves ↑/↓ to history until the user types again.
+func (m *Model) setInput(value string) {
+	m.textarea.SetValue(value)
+	m.textarea.CursorEnd()
+	m.completion.typed = false
+}
+
+// recordHistory stores a submitted prompt and persists the history. The
+// first failure to save is shown in the status bar until the next prompt;
+// later ones are not repeated.
+func (m *Model) recordHistory(input string) {
+	m.history.Add(input)
+	m.historyErr = nil
+	if err := m.history.Save(); err != nil && !m.historyWarn {
+		m.historyErr, m.historyWarn = err, true
+	}
+}
+
+// startSearch enters Ctrl+R reverse incremental search
+func (m *Model) startSearch() {
+	m.searching = true
+	m.searchQuery = ""
+	m.searchMatch = -1
+	m.searchDraft = m.textarea.Value()
+}
+
+// handleSearchKey handles keys during reverse incremental search
+func (m *Model) handleSearchKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return tea.Quit
+	case tea.KeyEsc, tea.KeyCtrlG:
+		// Cancel and restore the draft
+		m.searching = false
+		m.setInput(m.searchDraft)
+		return nil
+	case tea.KeyEnter, tea.KeyTab, tea.KeyRight:
+		// Accept the match for editing
+		m.searching = false
+		m.history.Reset()
+		return nil
+	case tea.KeyCtrlR:
+		// Find the next older match
+		from := m.searchMatch
+		if from < 0 {
+			from = m.history.Len()
+		}
+		m.runSearch(from)
+		return nil
+	case tea.KeyBackspace:
+		if m.searchQuery != "" {
+			runes := []rune(m.searchQuery)
+			m.searchQuery = string(runes[:len(runes)-1])
+			m.runSearch(m.history.Len())
+		}
+		return nil
+	case tea.KeyRunes, tea.KeySpace:
+		m.searchQuery += string(msg.Runes)
+		m.runSearch(m.history.Len())
+		return nil
+	}
+	return nil
+}
+
+// runSearch looks for the query in history before index from and previews it
+func (m *Model) runSearch(from int) {
+	if m.searchQuery == "" {
+		m.searchMatch = -1
+		m.setInput(m.searchDraft)
+		return
+	}
+
+	if idx, ok := m.history.Search(m.searchQuery, from); ok {
+		m.searchMatch = idx
+		m.setInput(m.history.At(idx))
+	} else if from == m.history.Len() {
+		m.searchMatch = -1
+	}
+}
+
+// renderSearchBar renders the reverse-i-search prompt shown in the status bar
+func (m *Model) renderSearchBar() string {
+	label := "reverse-i-search"
+	if m.searchMatch < 0 && m.searchQuery != "" {
+		label = "failing reverse-i-search"
+	}
+
+	prompt := HelpKeyStyle.Render(fmt.Sprintf("(%s)`%s': ", label, m.searchQuery))
+	match := lipgloss.NewStyle().Foreground(Text).Render(truncate(strings.ReplaceAll(m.history.At(m.searchMatch), "\n", " "), max(m.width/2, 20)))
+	hint := HelpStyle.Render("  Ctrl+R older • Enter accept • Esc cancel")
+
+	return StatusBarStyle.Width(m.width).Render(prompt + match + hint)
+}
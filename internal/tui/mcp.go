@@ -0,0 +1,105 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/diiviikk5/dvkcli/internal/mcp"
+	"github.com/diiviikk5/dvkcli/internal/tools"
+)
+
+// showMCP handles /mcp, listing the configured MCP servers or, given a name,
+// the details of one server
+func (m *Model) showMCP(args []string) {
+	servers := m.tools.MCP.Servers()
+	if len(servers) == 0 {
+		m.addNotice("No MCP servers are configured. Add them under \"mcp_servers\" in ~/.dvkcli/config.json.")
+		return
+	}
+
+	if len(args) > 0 {
+		for _, s := range servers {
+			if s.Name() == args[0] {
+				m.addNotice(describeMCPServer(s.Status()))
+				return
+			}
+		}
+		m.addNotice(fmt.Sprintf("No MCP server named %q.", args[0]))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("MCP servers:\n\n")
+	for _, s := range servers {
+		status := s.Status()
+		sb.WriteString(fmt.Sprintf("  %s %-16s %-9s", mcpStateIcon(status.State), status.Name, status.State))
+		switch status.State {
+		case mcp.StateReady:
+			sb.WriteString(fmt.Sprintf(" %d tools, %d resources, %d prompts",
+				len(status.Tools), len(status.Resources), len(status.Prompts)))
+			if status.ToolsErr != nil {
+				sb.WriteString(fmt.Sprintf(" (%d tools usable)", status.Registered))
+			}
+		case mcp.StateFailed:
+			sb.WriteString(" " + firstLine(status.Err.Error()))
+		}
+		sb.WriteString("\n")
+	}
+	if !m.cfg.ToolsEnabled {
+		sb.WriteString("\nTools are disabled, so the model cannot call MCP tools.")
+	}
+	sb.WriteString("\nUse /mcp <server> for details.")
+	m.addNotice(sb.String())
+}
+
+// describeMCPServer lists what a server offers, or why it failed
+func describeMCPServer(status mcp.Status) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s %s: %s", mcpStateIcon(status.State), status.Name, status.State))
+	if status.Info.Name != "" {
+		sb.WriteString(fmt.Sprintf(" (%s %s)", status.Info.Name, status.Info.Version))
+	}
+	sb.WriteString("\n")
+
+	if status.Err != nil {
+		sb.WriteString(fmt.Sprintf("\nError: %v\n", status.Err))
+	}
+	if status.Err != nil && status.Stderr != "" {
+		sb.WriteString("\nStderr:\n" + status.Stderr + "\n")
+	}
+	if status.ToolsErr != nil {
+		sb.WriteString(fmt.Sprintf("\n%d of %d tools are usable: %v\n", status.Registered, len(status.Tools), status.ToolsErr))
+	}
+
+	if len(status.Tools) > 0 {
+		sb.WriteString("\nTools:\n")
+		for _, t := range status.Tools {
+			sb.WriteString(fmt.Sprintf("  %-24s %s\n", status.Name+tools.MCPSeparator+t.Name, firstLine(t.Description)))
+		}
+	}
+	if len(status.Resources) > 0 {
+		sb.WriteString("\nResources:\n")
+		for _, r := range status.Resources {
+			sb.WriteString(fmt.Sprintf("  %-30s %s\n", r.URI, r.Name))
+		}
+	}
+	if len(status.Prompts) > 0 {
+		sb.WriteString("\nPrompts:\n")
+		for _, p := range status.Prompts {
+			sb.WriteString(fmt.Sprintf("  %-16s %s\n", p.Name, firstLine(p.Description)))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// mcpStateIcon marks a server's state
+func mcpStateIcon(state mcp.State) string {
+	switch state {
+	case mcp.StateReady:
+		return "●"
+	case mcp.StateFailed:
+		return "✗"
+	default:
+		return "○"
+	}
+}
@@ -0,0 +1,346 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/diiviikk5/dvkcli/internal/attach"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+)
+
+// pullState tracks a model download shown below the chat
+type pullState struct {
+	model    string
+	progress provider.PullProgress
+	cancel   context.CancelFunc
+}
+
+// pullProgressMsg reports a step of a model download
+type pullProgressMsg struct {
+	model    string
+	progress provider.PullProgress
+	cancel   context.CancelFunc
+	events   chan tea.Msg
+}
+
+// pullPromptDelay is how long the offer to pull missing models ignores keys
+// after it opens, so keys typed before it appeared don't answer it
+const pullPromptDelay = 500 * time.Millisecond
+
+// pullDoneMsg reports that a model download finished
+type pullDoneMsg struct {
+	model  string
+	err    error
+	events chan tea.Msg
+}
+
+// missingModelsMsg lists configured models the backend doesn't have
+type missingModelsMsg []string
+
+// modelManager returns the active backend if it can pull and remove models.
+// The failover chain in m.client hides it, so it is looked up by name.
+func (m *Model) modelManager() (provider.ModelManager, bool) {
+	p, ok := m.backends.Get(m.backend)
+	if !ok {
+		return nil, false
+	}
+	manager, ok := p.(provider.ModelManager)
+	return manager, ok
+}
+
+// pullModels handles /pull, downloading each model in turn
+func (m *Model) pullModels(models []string) tea.Cmd {
+	if len(models) == 0 {
+		m.addNotice("Usage: /pull <model>")
+		return nil
+	}
+	if m.pull != nil {
+		m.addNotice(fmt.Sprintf("Already pulling %s.", m.pull.model))
+		return nil
+	}
+	manager, ok := m.modelManager()
+	if !ok {
+		m.addNotice(fmt.Sprintf("The %s backend can't pull models.", m.backend))
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.pull = &pullState{model: models[0], cancel: cancel}
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+
+	events := make(chan tea.Msg)
+	go func() {
+		defer close(events)
+		defer cancel()
+		for _, model := range models {
+			// Downloads report many times a second; redraw at most every 100ms
+			var last time.Time
+			var status string
+			err := manager.Pull(ctx, model, func(progress provider.PullProgress) {
+				if progress.Status == status && time.Since(last) < 100*time.Millisecond {
+					return
+				}
+				status, last = progress.Status, time.Now()
+				events <- pullProgressMsg{model: model, progress: progress, cancel: cancel, events: events}
+			})
+			events <- pullDoneMsg{model: model, err: err, events: events}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return waitForEvent(events)
+}
+
+// updatePull shows the latest step of a download
+func (m *Model) updatePull(msg pullProgressMsg) {
+	m.pull = &pullState{model: msg.model, progress: msg.progress, cancel: msg.cancel}
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+}
+
+// cancelPull stops the download in progress. Its goroutine reports back
+// once the pull has stopped.
+func (m *Model) cancelPull() {
+	if m.pull == nil {
+		return
+	}
+	m.pull.cancel()
+	m.pull.progress = provider.PullProgress{Status: "cancelling"}
+	m.viewport.SetContent(m.renderMessages())
+}
+
+// finishPull reports a finished download and checks the backend again
+func (m *Model) finishPull(msg pullDoneMsg) tea.Cmd {
+	m.pull = nil
+	if errors.Is(msg.err, context.Canceled) {
+		m.addNotice(fmt.Sprintf("Cancelled pulling %s.", msg.model))
+		return nil
+	}
+	if msg.err != nil {
+		m.addNotice(fmt.Sprintf("Error pulling %s: %v", msg.model, msg.err))
+		return nil
+	}
+	m.addNotice(fmt.Sprintf("Pulled %s.", msg.model))
+	return tea.Batch(waitForEvent(msg.events), m.checkConnection())
+}
+
+// renderPull renders the download progress bar
+func (m *Model) renderPull() string {
+	p := m.pull.progress
+	label := lipgloss.NewStyle().Foreground(Primary).Render("⇣ " + m.pull.model)
+	status := p.Status
+	if status == "" {
+		status = "starting"
+	}
+	if p.Total <= 0 {
+		return fmt.Sprintf("  %s %s", label, HelpStyle.Render(status))
+	}
+
+	percent := float64(p.Completed) / float64(p.Total)
+	width := max(min(m.viewport.Width-40, 40), 10)
+	filled := min(int(percent*float64(width)), width)
+	bar := ProgressFillStyle.Render(strings.Repeat("█", filled)) +
+		ProgressEmptyStyle.Render(strings.Repeat("░", width-filled))
+	size := fmt.Sprintf("%3.0f%% %s/%s", percent*100, attach.FormatSize(p.Completed), attach.FormatSize(p.Total))
+	return fmt.Sprintf("  %s %s %s\n  %s", label, bar, HelpStyle.Render(size), HelpStyle.Render(status))
+}
+
+// removeModel handles /rm, asking before the model is deleted
+func (m *Model) removeModel(model string) tea.Cmd {
+	if model == "" {
+		m.addNotice("Usage: /rm <model>")
+		return nil
+	}
+	if _, ok := m.modelManager(); !ok {
+		m.addNotice(fmt.Sprintf("The %s backend can't remove models.", m.backend))
+		return nil
+	}
+	m.removePrompt = model
+	return nil
+}
+
+// handleRemovePromptKey answers the question whether to remove a model
+func (m *Model) handleRemovePromptKey(msg tea.KeyMsg) tea.Cmd {
+	model := m.removePrompt
+	switch msg.String() {
+	case "ctrl+c":
+		m.removePrompt = ""
+		return tea.Quit
+	case "y", "Y":
+		m.removePrompt = ""
+		return m.deleteModel(model)
+	case "n", "N", "esc":
+		m.removePrompt = ""
+		m.addNotice(fmt.Sprintf("Kept %s.", model))
+	}
+	return nil
+}
+
+// renderRemovePrompt renders the question whether to remove a model in
+// place of the input
+func (m *Model) renderRemovePrompt() string {
+	title := lipgloss.NewStyle().Bold(true).Foreground(Warning).Render(
+		fmt.Sprintf("⚠ Remove %s from %s?", m.removePrompt, m.backend))
+	keys := HelpKeyStyle.Render("y") + HelpStyle.Render(" remove  ") +
+		HelpKeyStyle.Render("n") + HelpStyle.Render(" keep")
+
+	return ApprovalStyle.Width(m.width - 4).Render(title + "\n" + keys)
+}
+
+// deleteModel removes a model from the active backend
+func (m *Model) deleteModel(model string) tea.Cmd {
+	manager, ok := m.modelManager()
+	if !ok {
+		m.addNotice(fmt.Sprintf("The %s backend can't remove models.", m.backend))
+		return nil
+	}
+
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := manager.Delete(ctx, model); err != nil {
+			return commandResultMsg{content: fmt.Sprintf("Error removing %s: %v", model, err)}
+		}
+		return commandResultMsg{content: fmt.Sprintf("Removed %s.", model)}
+	}
+}
+
+// showModel handles /show
+func (m *Model) showModel(model string) tea.Cmd {
+	if model == "" {
+		model = m.client.Model()
+	}
+	manager, ok := m.modelManager()
+	if !ok {
+		m.addNotice(fmt.Sprintf("The %s backend can't describe models.", m.backend))
+		return nil
+	}
+
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		info, err := manager.Show(ctx, model)
+		if err != nil {
+			return commandResultMsg{content: fmt.Sprintf("Error showing %s: %v", model, err)}
+		}
+		return commandResultMsg{content: formatModelInfo(info)}
+	}
+}
+
+// formatModelInfo describes a model on a few lines
+func formatModelInfo(info *provider.ModelInfo) string {
+	var sb strings.Builder
+	sb.WriteString(info.Name + "\n\n")
+	row := func(label, value string) {
+		if value != "" {
+			sb.WriteString(fmt.Sprintf("  %-14s %s\n", label, value))
+		}
+	}
+	row("Family", info.Family)
+	row("Parameters", info.ParameterSize)
+	row("Quantization", info.Quantization)
+	if info.ContextLength > 0 {
+		row("Context", fmt.Sprintf("%d tokens", info.ContextLength))
+	}
+	row("Capabilities", strings.Join(info.Capabilities, ", "))
+	if !info.ModifiedAt.IsZero() {
+		row("Modified", info.ModifiedAt.Format("2006-01-02 15:04"))
+	}
+	if params := strings.TrimSpace(info.Parameters); params != "" {
+		sb.WriteString("\n  " + strings.ReplaceAll(params, "\n", "\n  ") + "\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// checkModels looks for configured models the active backend doesn't have,
+// so the user can be offered to pull them
+func (m *Model) checkModels() tea.Cmd {
+	if _, ok := m.modelManager(); !ok {
+		return nil
+	}
+	wanted := []string{m.client.Model()}
+	if m.cfg.MemoryEnabled {
+		wanted = append(wanted, m.client.EmbedModel())
+	}
+	client := m.client
+
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		// An unreachable backend is already shown as disconnected
+		models, err := client.ListModels(ctx)
+		if err != nil {
+			return nil
+		}
+		var missing []string
+		for _, name := range wanted {
+			if name != "" && !provider.HasModel(models, name) && !slices.Contains(missing, name) {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+		return missingModelsMsg(missing)
+	}
+}
+
+// openPullPrompt offers to pull missing models. The offer arrives while the
+// user may be typing, so it takes focus from the input and only a y or n
+// typed after it appeared answers it.
+func (m *Model) openPullPrompt(models []string) {
+	m.pullPrompt = models
+	m.pullPromptAt = time.Now()
+	m.textarea.Blur()
+}
+
+// refocus gives the input its focus back when a modal closes
+func (m *Model) refocus() tea.Cmd {
+	if m.focus != focusInput {
+		return nil
+	}
+	return m.textarea.Focus()
+}
+
+// handlePullPromptKey answers the startup offer to pull missing models
+func (m *Model) handlePullPromptKey(msg tea.KeyMsg) tea.Cmd {
+	if msg.String() == "ctrl+c" {
+		return tea.Quit
+	}
+	if time.Since(m.pullPromptAt) < pullPromptDelay {
+		return nil
+	}
+	switch msg.String() {
+	case "y", "Y":
+		models := m.pullPrompt
+		m.pullPrompt = nil
+		return tea.Batch(m.refocus(), m.pullModels(models))
+	case "n", "N", "esc":
+		m.pullPrompt = nil
+		m.addNotice("Skipped. Use /pull <model> to download it later.")
+		return m.refocus()
+	}
+	return nil
+}
+
+// renderPullPrompt renders the offer to pull missing models in place of the
+// input
+func (m *Model) renderPullPrompt() string {
+	title := lipgloss.NewStyle().Bold(true).Foreground(Warning).Render(
+		fmt.Sprintf("⚠ %s doesn't have the configured models:", m.backend))
+	models := lipgloss.NewStyle().Foreground(Secondary).Render(strings.Join(m.pullPrompt, ", "))
+	keys := HelpKeyStyle.Render("y") + HelpStyle.Render(" pull them now  ") +
+		HelpKeyStyle.Render("n") + HelpStyle.Render(" skip")
+
+	return ApprovalStyle.Width(m.width - 4).Render(title + "\n" + models + "\n" + keys)
+}
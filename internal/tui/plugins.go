@@ -0,0 +1,147 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/diiviikk5/dvkcli/internal/plugin"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+	"github.com/google/uuid"
+)
+
+// pluginResultMsg carries what a plugin returned, with the conversation and
+// leaf message it was run on
+type pluginResultMsg struct {
+	name           string
+	resp           plugin.Response
+	err            error
+	conversationID string
+	leafID         string
+}
+
+// registerPlugins adds a command for each plugin. Plugins named like a
+// built-in command are left out.
+func (m *Model) registerPlugins(plugins []plugin.Plugin) {
+	cmds := make([]command, 0, len(plugins))
+	for _, p := range plugins {
+		description := p.Description
+		if description == "" {
+			description = "Run the " + p.Name + " plugin"
+		}
+		cmds = append(cmds, command{
+			name:        p.Name,
+			args:        []commandArg{{name: "args", optional: true, repeat: true}},
+			description: description,
+			run: func(m *Model, args []string, text string) tea.Cmd {
+				return m.runPlugin(p, args, text)
+			},
+		})
+	}
+	if skipped := m.commands.replace(sourcePlugin, cmds); len(skipped) > 0 {
+		m.addNotice(fmt.Sprintf("Plugins named like a built-in command are unavailable: %s", strings.Join(skipped, ", ")))
+	}
+}
+
+// runPlugin runs a plugin in the background with the conversation and the
+// selected message
+func (m *Model) runPlugin(p plugin.Plugin, args []string, text string) tea.Cmd {
+	conv := &plugin.Conversation{ID: m.conversationID, Title: m.conversationTitle(), Messages: []plugin.Message{}}
+	for _, msg := range m.messages {
+		if msg.Transient || msg.Failed || msg.Tool != nil {
+			continue
+		}
+		conv.Messages = append(conv.Messages, plugin.Message{Role: msg.Role, Content: msg.Content, Model: msg.Model})
+	}
+
+	req := plugin.Request{
+		Source:       "tui",
+		Args:         args,
+		Text:         text,
+		Dir:          m.loader.Root,
+		Backend:      m.backend,
+		Model:        m.client.Model(),
+		Conversation: conv,
+		Selection:    m.selection,
+		Config:       plugin.NewConfig(m.cfg),
+	}
+	m.selection = ""
+
+	conversationID, leafID := m.conversationID, m.lastID()
+	return func() tea.Msg {
+		resp, err := p.Run(context.Background(), req)
+		return pluginResultMsg{name: p.Name, resp: resp, err: err, conversationID: conversationID, leafID: leafID}
+	}
+}
+
+// applyPluginResult adds a plugin's messages to the chat, replaces the draft
+// and sends its prompt, as the plugin asked. If the chat moved on while the
+// plugin ran, to another conversation, branch or reply, only its notices
+// are shown, since the rest was meant for what the user has left.
+func (m *Model) applyPluginResult(msg pluginResultMsg) tea.Cmd {
+	if msg.err != nil {
+		m.addNotice(fmt.Sprintf("Error: %v", msg.err))
+		return nil
+	}
+	resp := msg.resp
+	if resp.Error != "" {
+		m.addNotice(fmt.Sprintf("Error: %s: %s", msg.name, resp.Error))
+		return nil
+	}
+
+	if m.conversationID != msg.conversationID || m.lastID() != msg.leafID || m.streaming {
+		dropped := resp.Draft != nil || resp.Prompt != ""
+		for _, pm := range resp.Messages {
+			if pm.Role != RoleUser && pm.Role != RoleAssistant {
+				m.addNotice(pm.Content)
+			} else {
+				dropped = true
+			}
+		}
+		if dropped {
+			m.addNotice(fmt.Sprintf("%s finished after the conversation changed; its messages, draft and prompt were dropped.", msg.name))
+		}
+		return nil
+	}
+
+	kept := false
+	for _, pm := range resp.Messages {
+		if pm.Role != RoleUser && pm.Role != RoleAssistant {
+			m.addNotice(pm.Content)
+			continue
+		}
+		m.messages = append(m.messages, ChatMessage{
+			ID:       uuid.New().String(),
+			ParentID: m.lastID(),
+			Role:     pm.Role,
+			Content:  pm.Content,
+			Time:     time.Now(),
+			Model:    pm.Model,
+		})
+		kept = true
+	}
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+
+	if resp.Draft != nil {
+		m.setInput(*resp.Draft)
+	}
+
+	var cmds []tea.Cmd
+	if kept {
+		cmds = append(cmds, m.saveToMemory())
+	}
+	if resp.Prompt != "" {
+		m.messages = append(m.messages, ChatMessage{
+			ID:       uuid.New().String(),
+			ParentID: m.lastID(),
+			Role:     RoleUser,
+			Content:  resp.Prompt,
+			Time:     time.Now(),
+		})
+		cmds = append(cmds, m.requestResponse(provider.ChatOptions{Model: resp.Model}))
+	}
+	return tea.Batch(cmds...)
+}
@@ -0,0 +1,223 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/diiviikk5/dvkcli/internal/diff"
+	"github.com/diiviikk5/dvkcli/internal/tools"
+)
+
+// reviewMsg asks the user to review a file change proposed by the model.
+// The verdict is sent on reply; events delivers the rest of the reply.
+type reviewMsg struct {
+	change tools.Change
+	reply  chan<- tools.Review
+	events <-chan tea.Msg
+}
+
+// reviewEditedMsg reports that the external editor has closed
+type reviewEditedMsg struct {
+	path string
+	err  error
+}
+
+// reviewState is the change being reviewed. content starts as the model's
+// proposal and changes if the user edits it.
+type reviewState struct {
+	reviewMsg
+	content string
+	view    viewport.Model
+}
+
+// reviewer returns a Reviewer that opens the review pane by sending a
+// reviewMsg through the reply's event channel
+func reviewer(events chan tea.Msg) tools.Reviewer {
+	return func(ctx context.Context, change tools.Change) (tools.Review, error) {
+		reply := make(chan tools.Review, 1)
+		select {
+		case events <- reviewMsg{change: change, reply: reply, events: events}:
+		case <-ctx.Done():
+			return tools.Review{}, ctx.Err()
+		}
+
+		select {
+		case review := <-reply:
+			return review, nil
+		case <-ctx.Done():
+			return tools.Review{}, ctx.Err()
+		}
+	}
+}
+
+// startReview opens the review pane over the chat
+func (m *Model) startReview(msg reviewMsg) {
+	m.review = &reviewState{
+		reviewMsg: msg,
+		content:   msg.change.New,
+		view:      viewport.New(m.viewport.Width, m.viewport.Height),
+	}
+	m.refreshReview()
+}
+
+// refreshReview re-renders the diff after the content changed
+func (m *Model) refreshReview() {
+	change := m.review.change
+	change.New = m.review.content
+
+	text := change.Diff()
+	if text == "" {
+		text = "(no changes)"
+	}
+	m.review.view.SetContent(renderDiff(text))
+}
+
+// handleReviewKey handles keys while the review pane is open
+func (m *Model) handleReviewKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "ctrl+c":
+		m.finishReview(false)
+		return tea.Quit
+	case "y", "a", "enter":
+		m.finishReview(true)
+	case "n", "esc":
+		m.finishReview(false)
+	case "e":
+		return m.editReview()
+	case "up", "k":
+		m.review.view.ScrollUp(1)
+	case "down", "j":
+		m.review.view.ScrollDown(1)
+	case "pgup":
+		m.review.view.HalfViewUp()
+	case "pgdown", " ":
+		m.review.view.HalfViewDown()
+	case "g", "home":
+		m.review.view.GotoTop()
+	case "G", "end":
+		m.review.view.GotoBottom()
+	}
+	return nil
+}
+
+// finishReview sends the verdict and closes the pane
+func (m *Model) finishReview(accepted bool) {
+	if m.review == nil {
+		return
+	}
+	m.review.reply <- tools.Review{Accepted: accepted, Content: m.review.content}
+	m.review = nil
+	m.viewport.SetContent(m.renderMessages())
+}
+
+// editReview opens the proposed content in $VISUAL or $EDITOR
+func (m *Model) editReview() tea.Cmd {
+	tmp, err := os.CreateTemp("", "dvkcli-*"+filepath.Ext(m.review.change.Path))
+	if err != nil {
+		m.addNotice(fmt.Sprintf("Error: %v", err))
+		return nil
+	}
+	_, err = tmp.WriteString(m.review.content)
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		m.addNotice(fmt.Sprintf("Error: %v", err))
+		return nil
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], tmp.Name())...)
+
+	path := tmp.Name()
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return reviewEditedMsg{path: path, err: err}
+	})
+}
+
+// applyReviewEdit loads the content saved in the external editor
+func (m *Model) applyReviewEdit(msg reviewEditedMsg) {
+	defer os.Remove(msg.path)
+	if m.review == nil {
+		return
+	}
+	if msg.err != nil {
+		m.addNotice(fmt.Sprintf("Editor failed: %v", msg.err))
+		return
+	}
+
+	data, err := os.ReadFile(msg.path)
+	if err != nil {
+		m.addNotice(fmt.Sprintf("Error: %v", err))
+		return
+	}
+	m.review.content = string(data)
+	m.refreshReview()
+}
+
+// renderReviewHelp renders the review keys in place of the input
+func (m *Model) renderReviewHelp() string {
+	change := m.review.change
+	change.New = m.review.content
+	added, removed := diff.Stats(diff.Lines(diff.SplitLines(change.Old), diff.SplitLines(change.New)))
+
+	verb := "edit"
+	if change.Created {
+		verb = "create"
+	}
+	title := HelpKeyStyle.Render(fmt.Sprintf("The model wants to %s %s", verb, change.Path)) +
+		" " + DiffAddStyle.Render(fmt.Sprintf("+%d", added)) + " " + DiffDeleteStyle.Render(fmt.Sprintf("-%d", removed))
+	if m.review.content != m.review.change.New {
+		title += HelpStyle.Render(" (edited)")
+	}
+	keys := HelpKeyStyle.Render("y") + HelpStyle.Render(" accept  ") +
+		HelpKeyStyle.Render("n") + HelpStyle.Render(" reject  ") +
+		HelpKeyStyle.Render("e") + HelpStyle.Render(" edit in $EDITOR  ") +
+		HelpKeyStyle.Render("j/k") + HelpStyle.Render(" scroll")
+
+	return ApprovalStyle.Width(m.width - 4).Render(title + "\n" + keys + "\n")
+}
+
+// renderDiff colours a unified diff
+func renderDiff(text string) string {
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			lines[i] = HelpKeyStyle.Render(line)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = DiffHunkStyle.Render(line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = DiffAddStyle.Render(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = DiffDeleteStyle.Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// undoEdit handles /undo, reverting the last file change made by the model
+func (m *Model) undoEdit() {
+	edit, err := m.tools.Editor.Undo()
+	if err != nil {
+		m.addNotice(fmt.Sprintf("Nothing undone: %v", err))
+		return
+	}
+	if edit.Created {
+		m.addNotice(fmt.Sprintf("Removed %s, which the assistant had created.", edit.Path))
+		return
+	}
+	m.addNotice(fmt.Sprintf("Restored %s to its previous content.", edit.Path))
+}
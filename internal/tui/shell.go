@@ -0,0 +1,178 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/diiviikk5/dvkcli/internal/attach"
+	"github.com/diiviikk5/dvkcli/internal/shell"
+	"github.com/diiviikk5/dvkcli/internal/tools"
+)
+
+// approvalMsg asks the user whether a command proposed by the model may run.
+// The answer is sent on reply; events delivers the rest of the reply.
+type approvalMsg struct {
+	command string
+	reply   chan<- tools.Approval
+	events  <-chan tea.Msg
+}
+
+// commandOutputMsg carries the result of /run
+type commandOutputMsg struct {
+	result shell.Result
+	err    error
+}
+
+// approver returns an Approver that shows the approval modal by sending an
+// approvalMsg through the reply's event channel
+func approver(events chan tea.Msg) tools.Approver {
+	return func(ctx context.Context, command string) (tools.Approval, error) {
+		reply := make(chan tools.Approval, 1)
+		select {
+		case events <- approvalMsg{command: command, reply: reply, events: events}:
+		case <-ctx.Done():
+			return tools.Reject, ctx.Err()
+		}
+
+		select {
+		case approval := <-reply:
+			return approval, nil
+		case <-ctx.Done():
+			return tools.Reject, ctx.Err()
+		}
+	}
+}
+
+// handleApprovalKey answers the approval modal
+func (m *Model) handleApprovalKey(msg tea.KeyMsg) tea.Cmd {
+	var approval tools.Approval
+	switch msg.String() {
+	case "ctrl+c":
+		m.answerApproval(tools.Reject)
+		return tea.Quit
+	case "y", "Y", "enter":
+		approval = tools.Once
+	case "a", "A":
+		approval = tools.Always
+	case "n", "N", "esc":
+		approval = tools.Reject
+	default:
+		return nil
+	}
+
+	m.answerApproval(approval)
+	return nil
+}
+
+// answerApproval sends the user's answer and closes the modal
+func (m *Model) answerApproval(approval tools.Approval) {
+	if m.approval == nil {
+		return
+	}
+	m.approval.reply <- approval
+	m.approval = nil
+	m.resize()
+}
+
+// renderApproval renders the approval modal in place of the input. The
+// command is wrapped, never cut, so every part of it that y or a approves
+// is on screen.
+func (m *Model) renderApproval() string {
+	title := lipgloss.NewStyle().Bold(true).Foreground(Warning).Render("⚠ The model wants to run:")
+	command := lipgloss.NewStyle().Foreground(Secondary).Width(max(m.width-8, 20)).Render("$ " + visible(m.approval.command))
+	keys := HelpKeyStyle.Render("y") + HelpStyle.Render(" run  ") +
+		HelpKeyStyle.Render("n") + HelpStyle.Render(" deny  ") +
+		HelpKeyStyle.Render("a") + HelpStyle.Render(" always allow this command")
+
+	return ApprovalStyle.Width(m.width - 4).Render(title + "\n" + command + "\n" + keys)
+}
+
+// visible escapes control characters other than newlines, so a command
+// can't move the cursor or recolor the terminal to hide part of itself
+func visible(command string) string {
+	var b strings.Builder
+	for _, r := range command {
+		if r != '\n' && unicode.IsControl(r) {
+			q := strconv.QuoteRune(r)
+			b.WriteString(q[1 : len(q)-1])
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// runCommand handles /run: the command runs without a prompt since the user
+// typed it, and its output is attached to the next message
+func (m *Model) runCommand(args string) tea.Cmd {
+	command := strings.TrimSpace(args)
+	if command == "" {
+		m.addNotice("Usage: /run <command>")
+		return nil
+	}
+
+	m.addNotice("$ " + command)
+	sh := m.tools.Shell
+	return func() tea.Msg {
+		result, err := sh.Run(context.Background(), command)
+		return commandOutputMsg{result: result, err: err}
+	}
+}
+
+// showCommandOutput shows /run output and attaches it to the next message
+func (m *Model) showCommandOutput(msg commandOutputMsg) {
+	if msg.err != nil {
+		m.addNotice(fmt.Sprintf("Error: %v", msg.err))
+		return
+	}
+
+	output := msg.result.Format()
+	m.addNotice(output + "\n\nThe output will be attached to your next message (/detach to drop it).")
+	m.pending = mergeAttachments(m.pending, []attach.Attachment{{
+		Path:    msg.result.Command,
+		Kind:    attach.KindCommand,
+		Size:    int64(len(output)),
+		Content: output,
+	}})
+	m.resize()
+}
+
+// showAudit handles /audit, listing recently executed commands
+func (m *Model) showAudit() tea.Cmd {
+	if m.store == nil {
+		m.addNotice("Memory is disabled, so no command audit log is kept.")
+		return nil
+	}
+
+	return func() tea.Msg {
+		records, err := m.store.RecentCommands(context.Background(), 20)
+		if err != nil {
+			return commandResultMsg{content: fmt.Sprintf("Error: %v", err)}
+		}
+		if len(records) == 0 {
+			return commandResultMsg{content: "No commands have been run yet."}
+		}
+
+		var sb strings.Builder
+		sb.WriteString("Recent commands:\n\n")
+		for _, rec := range records {
+			status := rec.Decision
+			switch {
+			case rec.DryRun:
+				status += ", dry run"
+			case rec.TimedOut:
+				status += ", timed out"
+			case rec.ExitCode != nil:
+				status += fmt.Sprintf(", exit %d", *rec.ExitCode)
+			}
+			sb.WriteString(fmt.Sprintf("  %s  [%s] $ %s  (%s)\n",
+				rec.CreatedAt.Format("01-02 15:04"), rec.Source, truncate(rec.Command, 60), status))
+		}
+		return commandResultMsg{content: sb.String()}
+	}
+}
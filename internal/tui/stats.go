@@ -0,0 +1,122 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+)
+
+// sparkBars draws daily usage, lowest to highest
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// formatUsage summarises a reply's tokens and speed, or returns "" when
+// nothing was recorded
+func formatUsage(u provider.Usage) string {
+	if u.CompletionTokens == 0 {
+		return ""
+	}
+	parts := []string{fmt.Sprintf("%d tok", u.CompletionTokens)}
+	if tps := u.TokensPerSecond(); tps > 0 {
+		parts = append(parts, fmt.Sprintf("%.1f tok/s", tps))
+	}
+	if u.FirstToken > 0 {
+		parts = append(parts, fmt.Sprintf("TTFT %.2fs", u.FirstToken.Seconds()))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// showStats handles /stats, summarising token usage per model over the last
+// days (30 by default)
+func (m *Model) showStats(args []string) tea.Cmd {
+	days := 30
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			m.addNotice("Usage: /stats [days]")
+			return nil
+		}
+		days = n
+	}
+	if m.store == nil {
+		m.addNotice("Memory is disabled, so no usage is recorded.")
+		return nil
+	}
+	store := m.store
+	width := max(m.viewport.Width-20, 10)
+
+	return func() tea.Msg {
+		ctx := context.Background()
+		today := time.Now()
+		since := today.AddDate(0, 0, 1-days)
+
+		models, err := store.UsageByModel(ctx, since)
+		if err != nil {
+			return commandResultMsg{content: fmt.Sprintf("Error: %v", err)}
+		}
+		if len(models) == 0 {
+			return commandResultMsg{content: fmt.Sprintf("No replies with token counts in the last %d days.", days)}
+		}
+		daily, err := store.UsageByDay(ctx, since)
+		if err != nil {
+			return commandResultMsg{content: fmt.Sprintf("Error: %v", err)}
+		}
+
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("Token usage, last %d days:\n\n", days))
+		sb.WriteString(fmt.Sprintf("  %-24s %7s %8s %8s %7s %6s\n", "MODEL", "REPLIES", "PROMPT", "OUTPUT", "TOK/S", "TTFT"))
+		var total int
+		for _, u := range models {
+			name := u.Model
+			if name == "" {
+				name = "(unknown)"
+			}
+			speed, ttft := "-", "-"
+			if tps := u.TokensPerSecond(); tps > 0 {
+				speed = fmt.Sprintf("%.1f", tps)
+			}
+			if u.FirstToken > 0 {
+				ttft = fmt.Sprintf("%.2fs", u.FirstToken.Seconds())
+			}
+			sb.WriteString(fmt.Sprintf("  %-24s %7d %8s %8s %7s %6s\n",
+				truncate(name, 24), u.Replies, formatCount(u.PromptTokens), formatCount(u.CompletionTokens), speed, ttft))
+			total += u.PromptTokens + u.CompletionTokens
+		}
+
+		// One bar per day, leaving out the oldest days when they don't fit
+		tokens := make(map[string]int, len(daily))
+		peak := 0
+		for _, d := range daily {
+			tokens[d.Day] = d.Tokens
+			peak = max(peak, d.Tokens)
+		}
+		var spark strings.Builder
+		for i := min(days, width) - 1; i >= 0; i-- {
+			n := tokens[today.AddDate(0, 0, -i).Format(time.DateOnly)]
+			if n == 0 {
+				spark.WriteRune('·')
+				continue
+			}
+			spark.WriteRune(sparkBars[n*(len(sparkBars)-1)/peak])
+		}
+		sb.WriteString(fmt.Sprintf("\n  Daily  %s  %s tokens in total", spark.String(), formatCount(total)))
+		return commandResultMsg{content: sb.String()}
+	}
+}
+
+// formatCount shortens large token counts, e.g. 12.3k
+func formatCount(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 10_000:
+		return fmt.Sprintf("%.0fk", float64(n)/1000)
+	case n >= 1000:
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	}
+	return strconv.Itoa(n)
+}
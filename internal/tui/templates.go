@@ -0,0 +1,140 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/diiviikk5/dvkcli/internal/config"
+	"github.com/diiviikk5/dvkcli/internal/prompts"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+	"github.com/google/uuid"
+)
+
+// loadTemplates reads the global and project prompt templates. They are read
+// on every use so edits apply without a restart.
+func (m *Model) loadTemplates() ([]prompts.Template, error) {
+	return prompts.Load(config.PromptDirs(m.loader.Root)...)
+}
+
+// runTemplate handles /t, sending a prompt template filled in with the
+// arguments, or listing the templates without a name. The text after the
+// name is used as typed, so pasted text keeps its newlines.
+func (m *Model) runTemplate(text string) tea.Cmd {
+	templates, err := m.loadTemplates()
+	if err != nil {
+		m.addNotice(fmt.Sprintf("Error: %v", err))
+		return nil
+	}
+	if text == "" {
+		m.addNotice(formatTemplates(templates))
+		return nil
+	}
+	name, args := text, ""
+	if i := strings.IndexAny(text, " \t\r\n"); i >= 0 {
+		name, args = text[:i], text[i:]
+	}
+
+	t, err := prompts.Find(templates, name)
+	if err != nil {
+		if errors.Is(err, prompts.ErrNotFound) {
+			m.addNotice(fmt.Sprintf("Error: %v. Type /t to list templates.", err))
+			return nil
+		}
+		m.addNotice(fmt.Sprintf("Error: %v", err))
+		return nil
+	}
+	vars, input := prompts.ParseText(args)
+	prompt, err := t.Render(vars, input, m.loader)
+	if err != nil {
+		m.addNotice(fmt.Sprintf("Error: %v\nUsage: %s", err, templateUsage(t)))
+		return nil
+	}
+	system, err := m.engine.Persona(t.Persona)
+	if err != nil {
+		m.addNotice(fmt.Sprintf("Error: %v", err))
+		return nil
+	}
+
+	// Only /file attachments go along: @path in the rendered text may come
+	// from the template or pasted input, not the user, and isn't confined
+	// to the project the way {{file:...}} is
+	parentID := m.lastID()
+	atts := m.takeAttachments("")
+	m.messages = append(m.messages, ChatMessage{
+		ID:          uuid.New().String(),
+		ParentID:    parentID,
+		Role:        RoleUser,
+		Content:     prompt,
+		Time:        time.Now(),
+		Attachments: atts,
+	})
+	return m.requestResponseAs(system, provider.ChatOptions{Model: t.Model, Temperature: t.Temperature})
+}
+
+// refreshTemplateCommands registers each template as a command of its own,
+// so /review works like /t review. Templates named like another command stay
+// reachable through /t.
+func (m *Model) refreshTemplateCommands() {
+	templates, err := m.loadTemplates()
+	if err != nil {
+		return
+	}
+	cmds := make([]command, 0, len(templates))
+	for _, t := range templates {
+		if strings.ContainsAny(t.Name, " \t") {
+			continue
+		}
+		var args []commandArg
+		for _, name := range t.Variables() {
+			args = append(args, commandArg{name: name + "=…", optional: true})
+		}
+		if t.UsesInput() {
+			args = append(args, commandArg{name: "text"})
+		}
+		description := t.Description
+		if description == "" {
+			description = "Send the " + t.Name + " template"
+		}
+		name := t.Name
+		cmds = append(cmds, command{
+			name:        strings.ToLower(name),
+			args:        args,
+			description: description,
+			run: func(m *Model, _ []string, text string) tea.Cmd {
+				return m.runTemplate(name + " " + text)
+			},
+		})
+	}
+	m.commands.replace(sourceTemplate, cmds)
+}
+
+// formatTemplates lists templates with their usage and descriptions
+func formatTemplates(templates []prompts.Template) string {
+	if len(templates) == 0 {
+		return fmt.Sprintf("No templates yet. Add markdown files to ~/.dvkcli/prompts or %s.", config.ProjectPromptsDir)
+	}
+	var sb strings.Builder
+	sb.WriteString("Templates (/t <name> [name=value...] [text]):\n")
+	for _, t := range templates {
+		sb.WriteString("\n  " + templateUsage(t))
+		if t.Description != "" {
+			sb.WriteString("\n      " + t.Description)
+		}
+	}
+	return sb.String()
+}
+
+// templateUsage shows how to call a template
+func templateUsage(t prompts.Template) string {
+	usage := "/t " + t.Name
+	for _, name := range t.Variables() {
+		usage += " " + name + "=…"
+	}
+	if t.UsesInput() {
+		usage += " <text>"
+	}
+	return usage
+}
@@ -61,6 +61,82 @@ var (
 				Foreground(Muted).
 				Italic(true)
 
+	ErrorMessageStyle = lipgloss.NewStyle().
+				Foreground(Error)
+
+	// Selected message in selection mode
+	SelectedMessageStyle = lipgloss.NewStyle().
+				BorderStyle(lipgloss.ThickBorder()).
+				BorderLeft(true).
+				BorderForeground(Secondary).
+				PaddingLeft(1)
+
+	// Attachment chips
+	AttachmentChipStyle = lipgloss.NewStyle().
+				Background(SurfaceAlt).
+				Foreground(Secondary).
+				Padding(0, 1)
+
+	// Branch position indicator, e.g. ‹2/3›
+	BranchStyle = lipgloss.NewStyle().
+			Foreground(Info)
+
+	// Command approval modal
+	ApprovalStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(Warning).
+			Padding(0, 1)
+
+	// Token and speed stats under a reply
+	UsageStyle = lipgloss.NewStyle().
+			Foreground(Subtle).
+			Italic(true)
+
+	// Model download progress bar
+	ProgressFillStyle = lipgloss.NewStyle().
+				Foreground(Secondary)
+
+	ProgressEmptyStyle = lipgloss.NewStyle().
+				Foreground(Subtle)
+
+	// Diff lines in the review pane
+	DiffAddStyle = lipgloss.NewStyle().
+			Foreground(Success)
+
+	DiffDeleteStyle = lipgloss.NewStyle().
+			Foreground(Error)
+
+	DiffHunkStyle = lipgloss.NewStyle().
+			Foreground(Info)
+
+	// Expanded tool call output
+	ToolOutputStyle = lipgloss.NewStyle().
+			Foreground(Muted).
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderLeft(true).
+			BorderForeground(Subtle).
+			PaddingLeft(1)
+
+	// Model thinking shown above a reply
+	ThinkingStyle = lipgloss.NewStyle().
+			Foreground(Subtle).
+			Italic(true).
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderLeft(true).
+			BorderForeground(Subtle).
+			PaddingLeft(1)
+
+	// Command completion popup above the input
+	CompletionStyle = lipgloss.NewStyle().
+			Foreground(Text).
+			PaddingLeft(2)
+
+	CompletionSelectedStyle = lipgloss.NewStyle().
+				Background(SurfaceAlt).
+				Foreground(Secondary).
+				Bold(true).
+				PaddingLeft(2)
+
 	// Input area
 	InputStyle = lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder()).
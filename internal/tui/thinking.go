@@ -0,0 +1,46 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// toggleThinking shows or hides the thinking of the message at index i
+func (m *Model) toggleThinking(i int) {
+	if i < 0 || i >= len(m.messages) || m.messages[i].Thinking == "" {
+		return
+	}
+	m.messages[i].ThinkingShown = !m.messages[i].ThinkingShown
+	m.viewport.SetContent(m.renderMessages())
+}
+
+// toggleAllThinking shows the thinking of every message, or hides it all when
+// it is already shown
+func (m *Model) toggleAllThinking() {
+	show := false
+	for _, msg := range m.messages {
+		if msg.Thinking != "" && !msg.ThinkingShown {
+			show = true
+			break
+		}
+	}
+	for i := range m.messages {
+		if m.messages[i].Thinking != "" {
+			m.messages[i].ThinkingShown = show
+		}
+	}
+	m.viewport.SetContent(m.renderMessages())
+}
+
+// renderThinking renders a message's thinking as a dimmed block, collapsed to
+// one line unless shown
+func renderThinking(msg ChatMessage) string {
+	header := lipgloss.NewStyle().Foreground(Subtle).Italic(true)
+	if !msg.ThinkingShown {
+		words := len(strings.Fields(msg.Thinking))
+		return header.Render(fmt.Sprintf("▸ 💭 Thought · %d words · Ctrl+T to show", words))
+	}
+	return header.Render("▾ 💭 Thinking") + "\n" + ThinkingStyle.Render(msg.Thinking)
+}
@@ -0,0 +1,171 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/diiviikk5/dvkcli/internal/provider"
+	"github.com/diiviikk5/dvkcli/internal/tools"
+)
+
+// maxToolOutputLines limits how much of a tool result an expanded message shows
+const maxToolOutputLines = 200
+
+// toolCallMsg reports a tool call made while the model is answering. The
+// channel delivers the rest of the reply's events.
+type toolCallMsg struct {
+	call     provider.ToolCall
+	id       string
+	parentID string
+	events   <-chan tea.Msg
+}
+
+// waitForEvent delivers the next event of a reply in progress
+func waitForEvent(events <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-events
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
+// addToolCall shows a tool call as a collapsed message. It is saved with
+// the reply and sent back to the model with the rest of the conversation.
+func (m *Model) addToolCall(msg toolCallMsg) {
+	call := msg.call
+	content := call.Result
+	if call.Err != nil {
+		content = "Error: " + call.Err.Error()
+	}
+	m.messages = append(m.messages, ChatMessage{
+		ID:       msg.id,
+		ParentID: msg.parentID,
+		Role:     RoleTool,
+		Content:  content,
+		Time:     time.Now(),
+		Tool:     &call,
+	})
+	m.viewport.SetContent(m.renderMessages())
+	m.viewport.GotoBottom()
+}
+
+// toggleTool expands or collapses the tool message at index i
+func (m *Model) toggleTool(i int) {
+	if i < 0 || i >= len(m.messages) || m.messages[i].Tool == nil {
+		return
+	}
+	m.messages[i].Expanded = !m.messages[i].Expanded
+	m.viewport.SetContent(m.renderMessages())
+}
+
+// toggleAllTools expands every tool message, or collapses them all when they
+// are already expanded
+func (m *Model) toggleAllTools() {
+	expand := false
+	for _, msg := range m.messages {
+		if msg.Tool != nil && !msg.Expanded {
+			expand = true
+			break
+		}
+	}
+	for i := range m.messages {
+		if m.messages[i].Tool != nil {
+			m.messages[i].Expanded = expand
+		}
+	}
+	m.viewport.SetContent(m.renderMessages())
+}
+
+// renderToolMessage renders a tool call, with its arguments and result when
+// expanded
+func (m *Model) renderToolMessage(msg ChatMessage) string {
+	call := msg.Tool
+
+	status := StatusActiveStyle.Render("✓")
+	summary := toolSummary(call.Result)
+	if call.Err != nil {
+		status = StatusErrorStyle.Render("✗")
+		summary = call.Err.Error()
+	}
+
+	marker := "▸"
+	if msg.Expanded {
+		marker = "▾"
+	}
+
+	header := lipgloss.NewStyle().Bold(true).Foreground(SystemMessageStyle.GetForeground()).
+		Render(fmt.Sprintf("%s ⚙ %s", marker, call.Name))
+	detail := lipgloss.NewStyle().Foreground(Subtle).
+		Render(fmt.Sprintf("%s · %s", truncate(summary, 60), call.Duration.Round(time.Millisecond)))
+	line := fmt.Sprintf("%s %s %s", header, status, detail)
+
+	if !msg.Expanded {
+		return line
+	}
+
+	var b strings.Builder
+	b.WriteString(line)
+	if len(call.Arguments) > 0 {
+		b.WriteString("\n" + SystemMessageStyle.Render("args: "+tools.FormatArgs(call.Arguments)))
+	}
+
+	output := call.Result
+	if call.Err != nil {
+		output = "Error: " + call.Err.Error()
+	}
+	if output != "" {
+		lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+		if len(lines) > maxToolOutputLines {
+			hidden := len(lines) - maxToolOutputLines
+			lines = append(lines[:maxToolOutputLines], fmt.Sprintf("… %d more lines", hidden))
+		}
+		b.WriteString("\n" + ToolOutputStyle.Width(max(m.viewport.Width-4, 20)).Render(strings.Join(lines, "\n")))
+	}
+	return b.String()
+}
+
+// toolSummary describes a tool result in a few words
+func toolSummary(result string) string {
+	result = strings.TrimSpace(result)
+	if result == "" {
+		return "no output"
+	}
+	lines := strings.Count(result, "\n") + 1
+	if lines == 1 {
+		return result
+	}
+	return fmt.Sprintf("%d lines", lines)
+}
+
+// listTools handles /tools
+func (m *Model) listTools() {
+	if !m.cfg.ToolsEnabled {
+		m.addNotice("Tools are disabled. Set \"tools_enabled\": true in ~/.dvkcli/config.json to enable them.")
+		return
+	}
+	if m.tools.Registry.Len() == 0 {
+		m.addNotice("No tools are available.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Tools available to models that support tool calling:\n\n")
+	for _, t := range m.tools.Registry.List() {
+		sb.WriteString(fmt.Sprintf("  %-14s %s\n", t.Name(), firstLine(t.Description())))
+	}
+	sb.WriteString("\nCtrl+O expands or collapses tool calls in the chat.")
+	m.addNotice(sb.String())
+}
+
+// firstLine returns the first line of s
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}